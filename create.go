@@ -39,6 +39,14 @@ command(s) that get executed on start, edit the args parameter of the spec. See
 			Value: "",
 			Usage: "specify the file to write the process id to",
 		},
+		cli.StringFlag{
+			Name:  "pidfd-socket",
+			Usage: "path to an AF_UNIX socket which will receive a file descriptor referencing the container process (a pidfd)",
+		},
+		cli.StringFlag{
+			Name:  "start-pipe-socket",
+			Usage: "path to an AF_UNIX socket which will receive a file descriptor referencing the write end of a pipe used to start the container, instead of the exec fifo",
+		},
 		cli.BoolFlag{
 			Name:  "no-pivot",
 			Usage: "do not use pivot root to jail process inside rootfs.  This should be used whenever the rootfs is on top of a ramdisk",
@@ -8,13 +8,24 @@ import (
 	"time"
 
 	"github.com/opencontainers/runc/libcontainer"
+	"github.com/sirupsen/logrus"
 	"github.com/urfave/cli"
 
 	"golang.org/x/sys/unix"
 )
 
 func killContainer(container libcontainer.Container) error {
-	_ = container.Signal(unix.SIGKILL, false)
+	// Kill all the container processes, not just init, so that those
+	// forking in the meantime, or not in the container PID namespace,
+	// don't keep the cgroup busy.
+	if err := container.Signal(unix.SIGKILL, true); err != nil {
+		// Killing all the processes may fail (e.g. if the cgroup can't
+		// be frozen), in which case at least kill init.
+		logrus.Warn(err)
+		if err := container.Signal(unix.SIGKILL, false); err != nil {
+			logrus.Warn(err)
+		}
+	}
 	for i := 0; i < 100; i++ {
 		time.Sleep(100 * time.Millisecond)
 		if err := container.Signal(unix.Signal(0), false); err != nil {
@@ -1,10 +1,12 @@
 package main
 
 import (
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"os"
+	"strings"
 	"sync"
 	"time"
 
@@ -28,6 +30,8 @@ information is displayed once every 5 seconds.`,
 	Flags: []cli.Flag{
 		cli.DurationFlag{Name: "interval", Value: 5 * time.Second, Usage: "set the stats collection interval"},
 		cli.BoolFlag{Name: "stats", Usage: "display the container's stats then exit"},
+		cli.StringFlag{Name: "memory-pressure", Usage: "also display memory pressure notifications of the given level ('low', 'medium', or 'critical'; cgroup v1 only)"},
+		cli.StringSliceFlag{Name: "pressure", Usage: "also display PSI notifications for the given trigger, in the RESOURCE:some|full:THRESHOLD:WINDOW format (e.g. memory:some:150ms:1s; cgroup v2 only)"},
 	},
 	Action: func(context *cli.Context) error {
 		if err := checkArgs(context, 1, exactArgs); err != nil {
@@ -49,9 +53,10 @@ information is displayed once every 5 seconds.`,
 			return fmt.Errorf("container with id %s is not running", container.ID())
 		}
 		var (
-			stats  = make(chan *libcontainer.Stats, 1)
-			events = make(chan *types.Event, 1024)
-			group  = &sync.WaitGroup{}
+			stats    = make(chan *libcontainer.Stats, 1)
+			events   = make(chan *types.Event, 1024)
+			group    = &sync.WaitGroup{}
+			pressure *types.MemoryPressure
 		)
 		group.Add(1)
 		go func() {
@@ -83,25 +88,93 @@ information is displayed once every 5 seconds.`,
 				stats <- s
 			}
 		}()
-		n, err := container.NotifyOOM()
+		var triggers []libcontainer.PSITrigger
+		for _, s := range context.StringSlice("pressure") {
+			t, err := parsePSITrigger(s)
+			if err != nil {
+				return err
+			}
+			triggers = append(triggers, t)
+		}
+		victims := newOOMVictims(container)
+		defer victims.Close()
+		// Finding out the OOM victim may take a while, so it is done
+		// in a separate goroutine, in order not to delay other events.
+		var (
+			oomKills   = make(chan struct{}, 128)
+			oomKillsWg sync.WaitGroup
+		)
+		oomKillsWg.Add(1)
+		go func() {
+			defer oomKillsWg.Done()
+			for range oomKills {
+				events <- &types.Event{Type: "oom-kill", ID: container.ID(), Data: victims.next()}
+			}
+		}()
+		defer func() {
+			if oomKills != nil {
+				close(oomKills)
+				oomKillsWg.Wait()
+			}
+		}()
+		n, stop, err := notifyEvents(container, triggers...)
 		if err != nil {
 			return err
 		}
+		defer stop()
+		var mp <-chan struct{}
+		if name := context.String("memory-pressure"); name != "" {
+			if cgroups.IsCgroup2UnifiedMode() {
+				return errors.New("--memory-pressure is not supported on cgroup v2")
+			}
+			level, err := libcontainer.ParsePressureLevel(name)
+			if err != nil {
+				return err
+			}
+			if mp, err = container.NotifyMemoryPressure(level); err != nil {
+				return err
+			}
+			pressure = &types.MemoryPressure{Level: level.String()}
+		}
 		for {
 			select {
-			case _, ok := <-n:
-				if ok {
-					// this means an oom event was received, if it is !ok then
-					// the channel was closed because the container stopped and
-					// the cgroups no longer exist.
+			case ev, ok := <-n:
+				if !ok {
+					n = nil
+					break
+				}
+				switch ev.Type {
+				case libcontainer.EventOOMKill:
+					// "oom" is kept for compatibility.
 					events <- &types.Event{Type: "oom", ID: container.ID()}
-				} else {
+					select {
+					case oomKills <- struct{}{}:
+					default:
+						// Too many pending; report an unknown victim.
+						events <- &types.Event{Type: "oom-kill", ID: container.ID(), Data: &types.OOMKill{}}
+					}
+				case libcontainer.EventPressure:
+					events <- &types.Event{Type: "pressure", ID: container.ID(), Data: convertPSITrigger(ev.Trigger)}
+				case libcontainer.EventFrozen, libcontainer.EventThawed,
+					libcontainer.EventUnitStopped, libcontainer.EventUnitOOMKilled, libcontainer.EventUnitRemoved:
+					events <- &types.Event{Type: ev.Type.String(), ID: container.ID()}
+				case libcontainer.EventInitExit, libcontainer.EventCgroupRemoved:
+					// The container has stopped.
 					n = nil
 				}
+			case _, ok := <-mp:
+				if ok {
+					events <- &types.Event{Type: "memory_pressure", ID: container.ID(), Data: pressure}
+				} else {
+					mp = nil
+				}
 			case s := <-stats:
 				events <- &types.Event{Type: "stats", ID: container.ID(), Data: convertLibcontainerStats(s)}
 			}
 			if n == nil {
+				close(oomKills)
+				oomKillsWg.Wait()
+				oomKills = nil
 				close(events)
 				break
 			}
@@ -111,6 +184,56 @@ information is displayed once every 5 seconds.`,
 	},
 }
 
+// notifyEvents subscribes to the container events, until stop is called.
+func notifyEvents(container libcontainer.Container, triggers ...libcontainer.PSITrigger) (_ <-chan libcontainer.Event, stop func(), _ error) {
+	ctx, cancel := context.WithCancel(context.Background())
+	ch, err := container.Events(ctx, triggers...)
+	if err != nil {
+		cancel()
+		return nil, nil, err
+	}
+	return ch, cancel, nil
+}
+
+// parsePSITrigger parses a PSI trigger in the
+// RESOURCE:some|full:THRESHOLD:WINDOW format.
+func parsePSITrigger(s string) (libcontainer.PSITrigger, error) {
+	var t libcontainer.PSITrigger
+	parts := strings.Split(s, ":")
+	if len(parts) != 4 {
+		return t, fmt.Errorf("invalid PSI trigger %q: expected RESOURCE:some|full:THRESHOLD:WINDOW", s)
+	}
+	t.Resource = parts[0]
+	switch parts[1] {
+	case "some":
+	case "full":
+		t.Full = true
+	default:
+		return t, fmt.Errorf("invalid PSI trigger %q: expected some or full, got %q", s, parts[1])
+	}
+	var err error
+	if t.Threshold, err = time.ParseDuration(parts[2]); err != nil {
+		return t, fmt.Errorf("invalid PSI trigger %q: %w", s, err)
+	}
+	if t.Window, err = time.ParseDuration(parts[3]); err != nil {
+		return t, fmt.Errorf("invalid PSI trigger %q: %w", s, err)
+	}
+	return t, nil
+}
+
+func convertPSITrigger(t *libcontainer.PSITrigger) *types.Pressure {
+	kind := "some"
+	if t.Full {
+		kind = "full"
+	}
+	return &types.Pressure{
+		Resource:  t.Resource,
+		Kind:      kind,
+		Threshold: t.Threshold.String(),
+		Window:    t.Window.String(),
+	}
+}
+
 func convertLibcontainerStats(ls *libcontainer.Stats) *types.Stats {
 	cg := ls.CgroupStats
 	if cg == nil {
@@ -119,6 +242,8 @@ func convertLibcontainerStats(ls *libcontainer.Stats) *types.Stats {
 	var s types.Stats
 	s.Pids.Current = cg.PidsStats.Current
 	s.Pids.Limit = cg.PidsStats.Limit
+	s.Pids.Failcnt = cg.PidsStats.Failcnt
+	s.Pids.Peak = cg.PidsStats.Peak
 
 	s.CPU.Usage.Kernel = cg.CpuStats.CpuUsage.UsageInKernelmode
 	s.CPU.Usage.User = cg.CpuStats.CpuUsage.UsageInUsermode
@@ -129,6 +254,9 @@ func convertLibcontainerStats(ls *libcontainer.Stats) *types.Stats {
 	s.CPU.Throttling.Periods = cg.CpuStats.ThrottlingData.Periods
 	s.CPU.Throttling.ThrottledPeriods = cg.CpuStats.ThrottlingData.ThrottledPeriods
 	s.CPU.Throttling.ThrottledTime = cg.CpuStats.ThrottlingData.ThrottledTime
+	s.CPU.Throttling.BurstPeriods = cg.CpuStats.ThrottlingData.BurstPeriods
+	s.CPU.Throttling.BurstTime = cg.CpuStats.ThrottlingData.BurstTime
+	s.CPU.PSI = convertPSI(cg.CpuStats.PSI)
 
 	s.CPUSet = types.CPUSet(cg.CPUSetStats)
 
@@ -138,6 +266,8 @@ func convertLibcontainerStats(ls *libcontainer.Stats) *types.Stats {
 	s.Memory.Swap = convertMemoryEntry(cg.MemoryStats.SwapUsage)
 	s.Memory.Usage = convertMemoryEntry(cg.MemoryStats.Usage)
 	s.Memory.Raw = cg.MemoryStats.Stats
+	s.Memory.Events = cg.MemoryStats.Events
+	s.Memory.PSI = convertPSI(cg.MemoryStats.PSI)
 
 	s.Blkio.IoServiceBytesRecursive = convertBlkioEntry(cg.BlkioStats.IoServiceBytesRecursive)
 	s.Blkio.IoServicedRecursive = convertBlkioEntry(cg.BlkioStats.IoServicedRecursive)
@@ -147,12 +277,27 @@ func convertLibcontainerStats(ls *libcontainer.Stats) *types.Stats {
 	s.Blkio.IoMergedRecursive = convertBlkioEntry(cg.BlkioStats.IoMergedRecursive)
 	s.Blkio.IoTimeRecursive = convertBlkioEntry(cg.BlkioStats.IoTimeRecursive)
 	s.Blkio.SectorsRecursive = convertBlkioEntry(cg.BlkioStats.SectorsRecursive)
+	s.Blkio.ThrottleIoServiceBytes = convertBlkioEntry(cg.BlkioStats.ThrottleIoServiceBytes)
+	s.Blkio.ThrottleIoServiced = convertBlkioEntry(cg.BlkioStats.ThrottleIoServiced)
+	s.Blkio.PSI = convertPSI(cg.BlkioStats.PSI)
 
 	s.Hugetlb = make(map[string]types.Hugetlb)
 	for k, v := range cg.HugetlbStats {
 		s.Hugetlb[k] = convertHugtlb(v)
 	}
 
+	if len(cg.MiscStats) > 0 {
+		s.Misc = make(map[string]types.Misc, len(cg.MiscStats))
+		for k, v := range cg.MiscStats {
+			s.Misc[k] = types.Misc(v)
+		}
+	}
+
+	s.Cgroup = types.Cgroup(cg.CgroupStats)
+
+	s.Rdma.Limit = convertRdmaEntry(cg.RdmaStats.RdmaLimit)
+	s.Rdma.Current = convertRdmaEntry(cg.RdmaStats.RdmaCurrent)
+
 	if is := ls.IntelRdtStats; is != nil {
 		if intelrdt.IsCATEnabled() {
 			s.IntelRdt.L3CacheInfo = convertL3CacheInfo(is.L3CacheInfo)
@@ -201,6 +346,14 @@ func convertBlkioEntry(c []cgroups.BlkioStatEntry) []types.BlkioEntry {
 	return out
 }
 
+func convertRdmaEntry(c []cgroups.RdmaEntry) []types.RdmaEntry {
+	var out []types.RdmaEntry
+	for _, e := range c {
+		out = append(out, types.RdmaEntry(e))
+	}
+	return out
+}
+
 func convertL3CacheInfo(i *intelrdt.L3CacheInfo) *types.L3CacheInfo {
 	ci := types.L3CacheInfo(*i)
 	return &ci
@@ -210,3 +363,13 @@ func convertMemBwInfo(i *intelrdt.MemBwInfo) *types.MemBwInfo {
 	mi := types.MemBwInfo(*i)
 	return &mi
 }
+
+func convertPSI(p *cgroups.PSIStats) *types.PSIStats {
+	if p == nil {
+		return nil
+	}
+	return &types.PSIStats{
+		Some: types.PSIData(p.Some),
+		Full: types.PSIData(p.Full),
+	}
+}
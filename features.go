@@ -2,15 +2,23 @@ package main
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
+	"sort"
 
+	criu "github.com/checkpoint-restore/go-criu/v5"
+	"github.com/opencontainers/runc/libcontainer/apparmor"
 	"github.com/opencontainers/runc/libcontainer/capabilities"
+	"github.com/opencontainers/runc/libcontainer/cgroups"
+	"github.com/opencontainers/runc/libcontainer/cgroups/systemd"
 	"github.com/opencontainers/runc/libcontainer/configs"
 	"github.com/opencontainers/runc/libcontainer/seccomp"
 	"github.com/opencontainers/runc/libcontainer/specconv"
 	"github.com/opencontainers/runc/types/features"
 	"github.com/opencontainers/runtime-spec/specs-go"
+	"github.com/opencontainers/selinux/go-selinux"
 	"github.com/urfave/cli"
+	"golang.org/x/sys/unix"
 )
 
 var featuresCommand = cli.Command{
@@ -54,6 +62,7 @@ var featuresCommand = cli.Command{
 				Selinux: &features.Selinux{
 					Enabled: &tru,
 				},
+				Host: hostFeatures(),
 			},
 		}
 
@@ -73,3 +82,34 @@ var featuresCommand = cli.Command{
 		return enc.Encode(feat)
 	},
 }
+
+// hostFeatures returns the features detected on the host.
+func hostFeatures() *features.Host {
+	host := &features.Host{
+		Cgroup:   "v1",
+		Systemd:  boolPtr(systemd.IsRunningSystemd()),
+		Apparmor: boolPtr(apparmor.IsEnabled()),
+		Selinux:  boolPtr(selinux.GetEnabled()),
+	}
+	if cgroups.IsCgroup2UnifiedMode() {
+		host.Cgroup = "v2"
+	} else if cgroups.IsCgroup2HybridMode() {
+		host.Cgroup = "hybrid"
+	}
+	if controllers, err := cgroups.GetAllSubsystems(); err == nil {
+		sort.Strings(controllers)
+		host.CgroupControllers = controllers
+	}
+	// Probe for mount_setattr(2); any error other than ENOSYS means it is
+	// implemented.
+	err := unix.MountSetattr(-1, "", unix.AT_EMPTY_PATH, &unix.MountAttr{})
+	host.IDMappedMounts = boolPtr(!errors.Is(err, unix.ENOSYS))
+	if v, err := criu.MakeCriu().GetCriuVersion(); err == nil {
+		host.CriuVersion = fmt.Sprintf("%d.%d.%d", v/10000, v/100%100, v%100)
+	}
+	return host
+}
+
+func boolPtr(b bool) *bool {
+	return &b
+}
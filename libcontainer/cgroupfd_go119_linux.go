@@ -0,0 +1,14 @@
+//go:build !go1.20
+
+package libcontainer
+
+import (
+	"os"
+	"os/exec"
+)
+
+// useCgroupFD is a no-op, as os/exec supports CLONE_INTO_CGROUP
+// since Go 1.20 only.
+func useCgroupFD(_ *exec.Cmd, _ *os.File) {}
+
+func retryWithoutCgroupFD(_ *exec.Cmd, _ error) *exec.Cmd { return nil }
@@ -0,0 +1,61 @@
+//go:build go1.20
+
+package libcontainer
+
+import (
+	"errors"
+	"os"
+	"os/exec"
+	"sync/atomic"
+
+	"golang.org/x/sys/unix"
+)
+
+// cloneIntoCgroupFailed is set once clone3(CLONE_INTO_CGROUP) has failed,
+// so later commands don't try to use it again.
+var cloneIntoCgroupFailed atomic.Bool
+
+// useCgroupFD makes cmd use clone3(CLONE_INTO_CGROUP) to start the process
+// in the cgroup referred to by fd, unless it is known not to work.
+func useCgroupFD(cmd *exec.Cmd, fd *os.File) {
+	if cloneIntoCgroupFailed.Load() {
+		return
+	}
+	cmd.SysProcAttr.UseCgroupFD = true
+	cmd.SysProcAttr.CgroupFD = int(fd.Fd())
+}
+
+// retryWithoutCgroupFD checks whether err, returned by cmd.Start, may be
+// caused by clone3(CLONE_INTO_CGROUP) not being available. This is the case
+// if clone3 is not implemented or blocked by a seccomp filter (ENOSYS or
+// EPERM), or if the kernel is older than 5.7 and does not know about the
+// cgroup field of clone_args (E2BIG) or the CLONE_INTO_CGROUP flag (EINVAL).
+//
+// If so, it returns a copy of cmd, not yet started and not using
+// CLONE_INTO_CGROUP, to be started instead, since an exec.Cmd can't be
+// reused once Start has been called. Otherwise, it returns nil.
+func retryWithoutCgroupFD(cmd *exec.Cmd, err error) *exec.Cmd {
+	if !cmd.SysProcAttr.UseCgroupFD {
+		return nil
+	}
+	if !(errors.Is(err, unix.ENOSYS) || errors.Is(err, unix.EPERM) ||
+		errors.Is(err, unix.E2BIG) || errors.Is(err, unix.EINVAL)) {
+		return nil
+	}
+	cloneIntoCgroupFailed.Store(true)
+
+	attr := *cmd.SysProcAttr
+	attr.UseCgroupFD = false
+	attr.CgroupFD = 0
+	return &exec.Cmd{
+		Path:        cmd.Path,
+		Args:        cmd.Args,
+		Env:         cmd.Env,
+		Dir:         cmd.Dir,
+		Stdin:       cmd.Stdin,
+		Stdout:      cmd.Stdout,
+		Stderr:      cmd.Stderr,
+		ExtraFiles:  cmd.ExtraFiles,
+		SysProcAttr: &attr,
+	}
+}
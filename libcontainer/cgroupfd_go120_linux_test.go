@@ -0,0 +1,57 @@
+//go:build go1.20
+
+package libcontainer
+
+import (
+	"errors"
+	"os"
+	"os/exec"
+	"syscall"
+	"testing"
+
+	"golang.org/x/sys/unix"
+)
+
+func TestRetryWithoutCgroupFD(t *testing.T) {
+	defer cloneIntoCgroupFailed.Store(cloneIntoCgroupFailed.Load())
+
+	for _, tc := range []struct {
+		err   error
+		retry bool
+	}{
+		{err: &os.PathError{Op: "fork/exec", Path: "/proc/self/exe", Err: unix.ENOSYS}, retry: true},
+		{err: &os.PathError{Op: "fork/exec", Path: "/proc/self/exe", Err: unix.EPERM}, retry: true},
+		{err: &os.PathError{Op: "fork/exec", Path: "/proc/self/exe", Err: unix.E2BIG}, retry: true},
+		{err: &os.PathError{Op: "fork/exec", Path: "/proc/self/exe", Err: unix.EINVAL}, retry: true},
+		{err: &os.PathError{Op: "fork/exec", Path: "/proc/self/exe", Err: unix.ENOENT}},
+		{err: errors.New("some error")},
+	} {
+		cloneIntoCgroupFailed.Store(false)
+		cmd := exec.Command("true")
+		cmd.SysProcAttr = &syscall.SysProcAttr{UseCgroupFD: true, CgroupFD: 42, Setsid: true}
+
+		newCmd := retryWithoutCgroupFD(cmd, tc.err)
+		if retry := newCmd != nil; retry != tc.retry {
+			t.Errorf("%v: expected retry %v, got %v", tc.err, tc.retry, retry)
+		}
+		if cloneIntoCgroupFailed.Load() != tc.retry {
+			t.Errorf("%v: CLONE_INTO_CGROUP should be disabled only if retrying", tc.err)
+		}
+		if newCmd == nil {
+			continue
+		}
+		if newCmd == cmd || newCmd.SysProcAttr == cmd.SysProcAttr {
+			t.Errorf("%v: expected a new command", tc.err)
+		}
+		if newCmd.SysProcAttr.UseCgroupFD || !newCmd.SysProcAttr.Setsid || newCmd.Path != cmd.Path {
+			t.Errorf("%v: unexpected new command: %+v", tc.err, newCmd)
+		}
+	}
+
+	// Nothing to retry if CLONE_INTO_CGROUP was not used.
+	cmd := exec.Command("true")
+	cmd.SysProcAttr = &syscall.SysProcAttr{}
+	if retryWithoutCgroupFD(cmd, unix.ENOSYS) != nil {
+		t.Error("expected no retry without UseCgroupFD")
+	}
+}
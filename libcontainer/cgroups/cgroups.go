@@ -1,9 +1,23 @@
 package cgroups
 
 import (
+	"errors"
+	"os"
+
 	"github.com/opencontainers/runc/libcontainer/configs"
 )
 
+// ErrNoCgroupFD is returned by Manager.CgroupFD if the manager can't
+// create a cgroup without adding a process to it.
+var ErrNoCgroupFD = errors.New("cgroup fd is not supported by this cgroup manager")
+
+// ErrFreezeTimeout is returned (wrapped) by Manager.Freeze if the cgroup
+// could not be frozen in a reasonable time, which may happen with cgroup
+// v1 while new processes keep appearing in the cgroup, or if some tasks
+// can't be frozen (e.g. being in uninterruptible sleep). The cgroup is
+// thawed back in such a case, so the operation can be retried later.
+var ErrFreezeTimeout = errors.New("timeout waiting for the cgroup to freeze")
+
 type Manager interface {
 	// Apply creates a cgroup, if not yet created, and adds a process
 	// with the specified pid into that cgroup.  A special value of -1
@@ -56,4 +70,15 @@ type Manager interface {
 
 	// OOMKillCount reports OOM kill count for the cgroup.
 	OOMKillCount() (uint64, error)
+
+	// Reclaim asks the kernel to proactively reclaim the specified
+	// amount of memory (in bytes) from the cgroup. Only supported
+	// on cgroup v2 (since kernel 5.19).
+	Reclaim(bytes uint64) error
+
+	// CgroupFD creates a cgroup, if not yet created, without adding any
+	// process to it, and returns an O_PATH file descriptor of the cgroup
+	// directory, suitable for clone3(CLONE_INTO_CGROUP). Only supported
+	// on cgroup v2 by the fs manager, others return ErrNoCgroupFD.
+	CgroupFD() (*os.File, error)
 }
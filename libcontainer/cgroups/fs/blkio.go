@@ -163,7 +163,34 @@ func getBlkioStat(dir, file string) ([]cgroups.BlkioStatEntry, error) {
 	return blkioStats, nil
 }
 
+// getThrottleStats fills in the throttling policy stats, which are
+// available regardless of the I/O scheduler used.
+func getThrottleStats(path string, stats *cgroups.BlkioStats) error {
+	for _, st := range []struct {
+		filename string
+		entries  *[]cgroups.BlkioStatEntry
+	}{
+		{"blkio.throttle.io_service_bytes", &stats.ThrottleIoServiceBytes},
+		{"blkio.throttle.io_serviced", &stats.ThrottleIoServiced},
+	} {
+		// Prefer the recursive variant (available since kernel v4.11).
+		entries, err := getBlkioStat(path, st.filename+"_recursive")
+		if err == nil && entries == nil {
+			entries, err = getBlkioStat(path, st.filename)
+		}
+		if err != nil {
+			return err
+		}
+		*st.entries = entries
+	}
+	return nil
+}
+
 func (s *BlkioGroup) GetStats(path string, stats *cgroups.Stats) error {
+	if err := getThrottleStats(path, &stats.BlkioStats); err != nil {
+		return err
+	}
+
 	type blkioStatInfo struct {
 		filename            string
 		blkioStatEntriesPtr *[]cgroups.BlkioStatEntry
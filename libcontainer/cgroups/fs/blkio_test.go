@@ -686,6 +686,9 @@ func TestThrottleRecursiveBlkioStats(t *testing.T) {
 	appendBlkioStatEntry(&expectedStats.IoServicedRecursive, 252, 0, 1641, "Async")
 	appendBlkioStatEntry(&expectedStats.IoServicedRecursive, 252, 0, 1641, "Total")
 
+	expectedStats.ThrottleIoServiceBytes = expectedStats.IoServiceBytesRecursive
+	expectedStats.ThrottleIoServiced = expectedStats.IoServicedRecursive
+
 	expectBlkioStatsEquals(t, expectedStats, actualStats.BlkioStats)
 }
 
@@ -736,6 +739,9 @@ func TestThrottleBlkioStats(t *testing.T) {
 	appendBlkioStatEntry(&expectedStats.IoServicedRecursive, 252, 0, 164, "Async")
 	appendBlkioStatEntry(&expectedStats.IoServicedRecursive, 252, 0, 164, "Total")
 
+	expectedStats.ThrottleIoServiceBytes = expectedStats.IoServiceBytesRecursive
+	expectedStats.ThrottleIoServiced = expectedStats.IoServicedRecursive
+
 	expectBlkioStatsEquals(t, expectedStats, actualStats.BlkioStats)
 }
 
@@ -123,6 +123,12 @@ func (s *CpuGroup) GetStats(path string, stats *cgroups.Stats) error {
 
 		case "throttled_time":
 			stats.CpuStats.ThrottlingData.ThrottledTime = v
+
+		case "nr_bursts":
+			stats.CpuStats.ThrottlingData.BurstPeriods = v
+
+		case "burst_time":
+			stats.CpuStats.ThrottlingData.BurstTime = v
 		}
 	}
 	return nil
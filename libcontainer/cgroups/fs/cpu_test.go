@@ -111,10 +111,12 @@ func TestCpuStats(t *testing.T) {
 		nrPeriods     = 2000
 		nrThrottled   = 200
 		throttledTime = uint64(18446744073709551615)
+		nrBursts      = 20
+		burstTime     = 5000000
 	)
 
-	cpuStatContent := fmt.Sprintf("nr_periods %d\nnr_throttled %d\nthrottled_time %d\n",
-		nrPeriods, nrThrottled, throttledTime)
+	cpuStatContent := fmt.Sprintf("nr_periods %d\nnr_throttled %d\nthrottled_time %d\nnr_bursts %d\nburst_time %d\n",
+		nrPeriods, nrThrottled, throttledTime, nrBursts, burstTime)
 	writeFileContents(t, path, map[string]string{
 		"cpu.stat": cpuStatContent,
 	})
@@ -130,6 +132,8 @@ func TestCpuStats(t *testing.T) {
 		Periods:          nrPeriods,
 		ThrottledPeriods: nrThrottled,
 		ThrottledTime:    throttledTime,
+		BurstPeriods:     nrBursts,
+		BurstTime:        burstTime,
 	}
 
 	expectThrottlingDataEquals(t, expectedStats, actualStats.CpuStats.ThrottlingData)
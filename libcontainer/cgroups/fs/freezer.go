@@ -58,7 +58,8 @@ func (s *FreezerGroup) Set(path string, r *configs.Resources) (Err error) {
 		// Alas, this is still a game of chances, since the real fix
 		// belong to the kernel (cgroup v2 do not have this bug).
 
-		for i := 0; i < 1000; i++ {
+		const maxRetries = 1000
+		for i := 0; i < maxRetries; i++ {
 			if i%50 == 49 {
 				// Occasional thaw and sleep improves
 				// the chances to succeed in freezing
@@ -98,7 +99,7 @@ func (s *FreezerGroup) Set(path string, r *configs.Resources) (Err error) {
 			}
 		}
 		// Despite our best efforts, it got stuck in FREEZING.
-		return errors.New("unable to freeze")
+		return fmt.Errorf("%w (stuck in FREEZING after %d attempts)", cgroups.ErrFreezeTimeout, maxRetries)
 	case configs.Thawed:
 		return cgroups.WriteFile(path, "freezer.state", string(configs.Thawed))
 	case configs.Undefined:
@@ -113,7 +114,7 @@ func (s *FreezerGroup) GetStats(path string, stats *cgroups.Stats) error {
 }
 
 func (s *FreezerGroup) GetState(path string) (configs.FreezerState, error) {
-	for {
+	for i := 0; ; i++ {
 		state, err := cgroups.ReadFile(path, "freezer.state")
 		if err != nil {
 			// If the kernel is too old, then we just treat the freezer as
@@ -148,7 +149,14 @@ func (s *FreezerGroup) GetState(path string) (configs.FreezerState, error) {
 			}
 		case "FREEZING":
 			// Make sure we get a stable freezer state, so retry if the cgroup
-			// is still undergoing freezing. This should be a temporary delay.
+			// is still undergoing freezing. This should be a temporary delay,
+			// but the cgroup can get stuck in FREEZING (for example, if its
+			// freezing was interrupted), so don't wait forever. Report it as
+			// frozen, since some of its tasks are, and it needs to be thawed.
+			if i == 1000 {
+				logrus.Warnf("cgroup %s is stuck in FREEZING state", path)
+				return configs.Frozen, nil
+			}
 			time.Sleep(1 * time.Millisecond)
 			continue
 		default:
@@ -44,3 +44,20 @@ func TestFreezerSetInvalidState(t *testing.T) {
 		t.Fatal("Failed to return invalid argument error")
 	}
 }
+
+func TestFreezerGetStateStuckFreezing(t *testing.T) {
+	path := tempDir(t, "freezer")
+
+	writeFileContents(t, path, map[string]string{
+		"freezer.state": "FREEZING",
+	})
+
+	freezer := &FreezerGroup{}
+	state, err := freezer.GetState(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if state != configs.Frozen {
+		t.Fatalf("expected %q, got %q", configs.Frozen, state)
+	}
+}
@@ -253,6 +253,14 @@ func OOMKillCount(path string) (uint64, error) {
 	return fscommon.GetValueByKey(path, "memory.oom_control", "oom_kill")
 }
 
+func (m *manager) Reclaim(_ uint64) error {
+	return cgroups.ErrV1NoReclaim
+}
+
+func (m *manager) CgroupFD() (*os.File, error) {
+	return nil, cgroups.ErrNoCgroupFD
+}
+
 func (m *manager) OOMKillCount() (uint64, error) {
 	c, err := OOMKillCount(m.Path("memory"))
 	// Ignore ENOENT when rootless as it couldn't create cgroup.
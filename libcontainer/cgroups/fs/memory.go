@@ -195,6 +195,13 @@ func (s *MemoryGroup) GetStats(path string, stats *cgroups.Stats) error {
 	}
 	stats.MemoryStats.PageUsageByNUMA = pagesByNUMA
 
+	// oom_kill counter is available since kernel 4.13.
+	oomKills, err := OOMKillCount(path)
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	stats.MemoryStats.OOMKillCount = oomKills
+
 	return nil
 }
 
@@ -17,6 +17,7 @@ rss 1024`
 	memoryFailcnt              = "100\n"
 	memoryLimitContents        = "8192\n"
 	memoryUseHierarchyContents = "1\n"
+	memoryOOMControlContents   = "oom_kill_disable 0\nunder_oom 0\noom_kill 3\n"
 	memoryNUMAStatContents     = `total=44611 N0=32631 N1=7501 N2=1982 N3=2497
 file=44428 N0=32614 N1=7335 N2=1982 N3=2497
 anon=183 N0=17 N1=166 N2=0 N3=0
@@ -240,6 +241,7 @@ func TestMemoryStats(t *testing.T) {
 		"memory.kmem.limit_in_bytes":      memoryLimitContents,
 		"memory.use_hierarchy":            memoryUseHierarchyContents,
 		"memory.numa_stat":                memoryNUMAStatContents + memoryNUMAStatExtraContents,
+		"memory.oom_control":              memoryOOMControlContents,
 	})
 
 	memory := &MemoryGroup{}
@@ -250,6 +252,7 @@ func TestMemoryStats(t *testing.T) {
 	}
 	expectedStats := cgroups.MemoryStats{
 		Cache:        512,
+		OOMKillCount: 3,
 		Usage:        cgroups.MemoryData{Usage: 2048, MaxUsage: 4096, Failcnt: 100, Limit: 8192},
 		SwapUsage:    cgroups.MemoryData{Usage: 2048, MaxUsage: 4096, Failcnt: 100, Limit: 8192},
 		KernelUsage:  cgroups.MemoryData{Usage: 2048, MaxUsage: 4096, Failcnt: 100, Limit: 8192},
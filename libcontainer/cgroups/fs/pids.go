@@ -2,6 +2,7 @@ package fs
 
 import (
 	"math"
+	"os"
 	"strconv"
 
 	"github.com/opencontainers/runc/libcontainer/cgroups"
@@ -56,7 +57,19 @@ func (s *PidsGroup) GetStats(path string, stats *cgroups.Stats) error {
 		max = 0
 	}
 
+	// pids.events is available since kernel 4.5, pids.peak since kernel 6.1.
+	failcnt, err := fscommon.GetValueByKey(path, "pids.events", "max")
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	peak, err := fscommon.GetCgroupParamUint(path, "pids.peak")
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+
 	stats.PidsStats.Current = current
 	stats.PidsStats.Limit = max
+	stats.PidsStats.Failcnt = failcnt
+	stats.PidsStats.Peak = peak
 	return nil
 }
@@ -85,6 +85,31 @@ func TestPidsStats(t *testing.T) {
 	}
 }
 
+func TestPidsStatsEvents(t *testing.T) {
+	path := tempDir(t, "pids")
+
+	writeFileContents(t, path, map[string]string{
+		"pids.current": strconv.Itoa(1337),
+		"pids.max":     strconv.Itoa(maxLimited),
+		"pids.events":  "max 42\n",
+		"pids.peak":    strconv.Itoa(1400),
+	})
+
+	pids := &PidsGroup{}
+	stats := *cgroups.NewStats()
+	if err := pids.GetStats(path, &stats); err != nil {
+		t.Fatal(err)
+	}
+
+	if stats.PidsStats.Failcnt != 42 {
+		t.Fatalf("Expected %d, got %d for pids.events max", 42, stats.PidsStats.Failcnt)
+	}
+
+	if stats.PidsStats.Peak != 1400 {
+		t.Fatalf("Expected %d, got %d for pids.peak", 1400, stats.PidsStats.Peak)
+	}
+}
+
 func TestPidsStatsUnlimited(t *testing.T) {
 	path := tempDir(t, "pids")
 
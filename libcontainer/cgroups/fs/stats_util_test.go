@@ -55,6 +55,14 @@ func expectBlkioStatsEquals(t *testing.T, expected, actual cgroups.BlkioStats) {
 	if err := blkioStatEntryEquals(expected.IoTimeRecursive, actual.IoTimeRecursive); err != nil {
 		t.Errorf("blkio IoTimeRecursive do not match: %s", err)
 	}
+
+	if err := blkioStatEntryEquals(expected.ThrottleIoServiceBytes, actual.ThrottleIoServiceBytes); err != nil {
+		t.Errorf("blkio ThrottleIoServiceBytes do not match: %s", err)
+	}
+
+	if err := blkioStatEntryEquals(expected.ThrottleIoServiced, actual.ThrottleIoServiced); err != nil {
+		t.Errorf("blkio ThrottleIoServiced do not match: %s", err)
+	}
 }
 
 func expectThrottlingDataEquals(t *testing.T, expected, actual cgroups.ThrottlingData) {
@@ -82,6 +90,10 @@ func expectMemoryStatEquals(t *testing.T, expected, actual cgroups.MemoryStats)
 		t.Errorf("Expected memory use hierarchy: %v, actual: %v", expected.UseHierarchy, actual.UseHierarchy)
 	}
 
+	if expected.OOMKillCount != actual.OOMKillCount {
+		t.Errorf("Expected memory OOM kill count: %d, actual: %d", expected.OOMKillCount, actual.OOMKillCount)
+	}
+
 	for key, expValue := range expected.Stats {
 		actValue, ok := actual.Stats[key]
 		if !ok {
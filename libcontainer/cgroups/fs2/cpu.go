@@ -11,7 +11,8 @@ import (
 )
 
 func isCpuSet(r *configs.Resources) bool {
-	return r.CpuWeight != 0 || r.CpuQuota != 0 || r.CpuPeriod != 0
+	return r.CpuWeight != 0 || r.CpuQuota != 0 || r.CpuPeriod != 0 ||
+		r.CpuUclampMin != "" || r.CpuUclampMax != ""
 }
 
 func setCpu(dirPath string, r *configs.Resources) error {
@@ -43,6 +44,18 @@ func setCpu(dirPath string, r *configs.Resources) error {
 		}
 	}
 
+	// cpu.uclamp.{min,max} require CONFIG_UCLAMP_TASK_GROUP (since kernel 5.4).
+	if r.CpuUclampMin != "" {
+		if err := cgroups.WriteFile(dirPath, "cpu.uclamp.min", r.CpuUclampMin); err != nil {
+			return err
+		}
+	}
+	if r.CpuUclampMax != "" {
+		if err := cgroups.WriteFile(dirPath, "cpu.uclamp.max", r.CpuUclampMax); err != nil {
+			return err
+		}
+	}
+
 	return nil
 }
 
@@ -78,6 +91,13 @@ func statCpu(dirPath string, stats *cgroups.Stats) error {
 
 		case "throttled_usec":
 			stats.CpuStats.ThrottlingData.ThrottledTime = v * 1000
+
+		// nr_bursts and burst_usec are available since kernel 5.14.
+		case "nr_bursts":
+			stats.CpuStats.ThrottlingData.BurstPeriods = v
+
+		case "burst_usec":
+			stats.CpuStats.ThrottlingData.BurstTime = v * 1000
 		}
 	}
 	if err := sc.Err(); err != nil {
@@ -1,12 +1,15 @@
 package fs2
 
 import (
+	"fmt"
+	"strings"
+
 	"github.com/opencontainers/runc/libcontainer/cgroups"
 	"github.com/opencontainers/runc/libcontainer/configs"
 )
 
 func isCpusetSet(r *configs.Resources) bool {
-	return r.CpusetCpus != "" || r.CpusetMems != ""
+	return r.CpusetCpus != "" || r.CpusetMems != "" || r.CpusetPartition != ""
 }
 
 func setCpuset(dirPath string, r *configs.Resources) error {
@@ -24,5 +27,32 @@ func setCpuset(dirPath string, r *configs.Resources) error {
 			return err
 		}
 	}
+	// The partition must be set after cpuset.cpus, as the kernel
+	// validates the partition against the CPUs assigned to the cgroup.
+	if r.CpusetPartition != "" {
+		if err := setCpusetPartition(dirPath, r.CpusetPartition); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// setCpusetPartition writes cpuset.cpus.partition (since kernel 5.11,
+// "isolated" since kernel 5.15). The kernel accepts most writes but may
+// put the cgroup into an invalid partition state, which is only visible
+// when reading the file back, so check for that.
+func setCpusetPartition(dirPath, partition string) error {
+	const file = "cpuset.cpus.partition"
+	if err := cgroups.WriteFile(dirPath, file, partition); err != nil {
+		return err
+	}
+	state, err := cgroups.ReadFile(dirPath, file)
+	if err != nil {
+		return err
+	}
+	state = strings.TrimSpace(state)
+	if strings.Contains(state, "invalid") {
+		return fmt.Errorf("unable to set cpuset partition to %q: %s", partition, state)
+	}
 	return nil
 }
@@ -0,0 +1,36 @@
+package fs2
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/opencontainers/runc/libcontainer/cgroups"
+	"github.com/opencontainers/runc/libcontainer/configs"
+)
+
+func TestSetCpusetPartition(t *testing.T) {
+	// We're using a fake cgroupfs.
+	cgroups.TestMode = true
+
+	fakeCgroupDir := t.TempDir()
+	r := &configs.Resources{
+		CpusetCpus:      "2-3",
+		CpusetPartition: "isolated",
+	}
+	if err := setCpuset(fakeCgroupDir, r); err != nil {
+		t.Fatal(err)
+	}
+	for file, expected := range map[string]string{
+		"cpuset.cpus":           "2-3",
+		"cpuset.cpus.partition": "isolated",
+	} {
+		got, err := os.ReadFile(filepath.Join(fakeCgroupDir, file))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(got) != expected {
+			t.Errorf("expected %s to be %q, got %q", file, expected, got)
+		}
+	}
+}
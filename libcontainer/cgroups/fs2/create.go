@@ -1,17 +1,41 @@
 package fs2
 
 import (
+	"errors"
 	"fmt"
 	"os"
 	"path/filepath"
 	"strings"
+	"sync"
+
+	"github.com/sirupsen/logrus"
+	"golang.org/x/sys/unix"
 
 	"github.com/opencontainers/runc/libcontainer/cgroups"
 	"github.com/opencontainers/runc/libcontainer/configs"
 )
 
+var (
+	supportedMu   sync.Mutex
+	supportedCtrs string
+)
+
+// supportedControllers returns the contents of the root cgroup's
+// cgroup.controllers. It is cached since it is read on every container
+// creation and only changes if controllers are moved between hierarchies.
 func supportedControllers() (string, error) {
-	return cgroups.ReadFile(UnifiedMountpoint, "/cgroup.controllers")
+	supportedMu.Lock()
+	defer supportedMu.Unlock()
+
+	if supportedCtrs != "" {
+		return supportedCtrs, nil
+	}
+	content, err := cgroups.ReadFile(UnifiedMountpoint, "/cgroup.controllers")
+	if err != nil {
+		return "", err
+	}
+	supportedCtrs = content
+	return content, nil
 }
 
 // needAnyControllers returns whether we enable some supported controllers or not,
@@ -57,6 +81,12 @@ func needAnyControllers(r *configs.Resources) (bool, error) {
 	if isHugeTlbSet(r) && have("hugetlb") {
 		return true, nil
 	}
+	if len(r.Rdma) > 0 && have("rdma") {
+		return true, nil
+	}
+	if isMiscSet(r) && have("misc") {
+		return true, nil
+	}
 
 	return false, nil
 }
@@ -65,7 +95,7 @@ func needAnyControllers(r *configs.Resources) (bool, error) {
 // Refer to: http://man7.org/linux/man-pages/man7/cgroups.7.html
 // As at Linux 4.19, the following controllers are threaded: cpu, perf_event, and pids.
 func containsDomainController(r *configs.Resources) bool {
-	return isMemorySet(r) || isIoSet(r) || isCpuSet(r) || isHugeTlbSet(r)
+	return isMemorySet(r) || isIoSet(r) || isCpuSet(r) || isHugeTlbSet(r) || isMiscSet(r)
 }
 
 // CreateCgroupPath creates cgroupv2 path, enabling all the supported controllers.
@@ -133,10 +163,27 @@ func CreateCgroupPath(path string, c *configs.Cgroup) (Err error) {
 					return fmt.Errorf("cannot enter cgroupv2 %q with domain controllers -- it is in %s mode", current, cgType)
 				}
 			}
+			// Put the container cgroup into threaded mode, if requested.
+			if i == len(elements)-1 && c.Threaded && cgType != "threaded" {
+				if containsDomainController(c.Resources) {
+					return fmt.Errorf("cannot make cgroupv2 %q threaded -- domain controllers are configured", current)
+				}
+				if err := cgroups.WriteFile(current, cgTypeFile, "threaded"); err != nil {
+					return err
+				}
+			}
 		}
 		// enable all supported controllers
 		if i < len(elements)-1 {
 			if err := cgroups.WriteFile(current, cgStCtlFile, res); err != nil {
+				// With nsdelegate, the cgroup namespace root (which is
+				// usually the mountpoint) can't have its controllers
+				// changed from inside the namespace, so there is no
+				// point in retrying one by one.
+				if errors.Is(err, unix.EPERM) && isNsDelegate(UnifiedMountpoint) {
+					logrus.Debugf("can't enable controllers in %s (nsdelegate): %v", current, err)
+					continue
+				}
 				// try write one by one
 				allCtrs := strings.Split(res, " ")
 				for _, ctr := range allCtrs {
@@ -0,0 +1,43 @@
+package fs2
+
+import (
+	"testing"
+
+	"github.com/opencontainers/runc/libcontainer/configs"
+)
+
+func TestNeedAnyControllers(t *testing.T) {
+	handles := uint32(10)
+	rdma := map[string]configs.LinuxRdma{"mlx5_0": {HcaHandles: &handles}}
+	testCases := []struct {
+		avail string
+		r     *configs.Resources
+		need  bool
+	}{
+		{avail: "cpu io memory pids rdma", r: nil},
+		{avail: "cpu io memory pids rdma", r: &configs.Resources{}},
+		{avail: "cpu io memory pids rdma", r: &configs.Resources{Rdma: rdma}, need: true},
+		{avail: "cpu io memory pids", r: &configs.Resources{Rdma: rdma}},
+		{avail: "pids", r: &configs.Resources{PidsLimit: 10}, need: true},
+		{avail: "cpu", r: &configs.Resources{PidsLimit: 10}},
+	}
+
+	for _, tc := range testCases {
+		// Fake the root cgroup controllers.
+		supportedMu.Lock()
+		supportedCtrs = tc.avail
+		supportedMu.Unlock()
+
+		need, err := needAnyControllers(tc.r)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if need != tc.need {
+			t.Errorf("controllers %q, resources %+v: expected %v, got %v", tc.avail, tc.r, tc.need, need)
+		}
+	}
+
+	supportedMu.Lock()
+	supportedCtrs = ""
+	supportedMu.Unlock()
+}
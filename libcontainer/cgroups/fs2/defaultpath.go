@@ -25,6 +25,8 @@ import (
 	"path/filepath"
 	"strings"
 
+	"github.com/moby/sys/mountinfo"
+
 	"github.com/opencontainers/runc/libcontainer/configs"
 	"github.com/opencontainers/runc/libcontainer/utils"
 )
@@ -59,6 +61,14 @@ func _defaultDirPath(root, cgPath, cgParent, cgName string) (string, error) {
 	if err != nil {
 		return "", err
 	}
+	mountRoot, err := getMountRoot(root)
+	if err != nil {
+		return "", err
+	}
+	ownCgroup, err = relToMountRoot(ownCgroup, mountRoot)
+	if err != nil {
+		return "", err
+	}
 	// The current user scope most probably has tasks in it already,
 	// making it impossible to enable controllers for its sub-cgroup.
 	// A parent cgroup (with no tasks in it) is what we need.
@@ -67,6 +77,62 @@ func _defaultDirPath(root, cgPath, cgParent, cgName string) (string, error) {
 	return filepath.Join(root, ownCgroup, innerPath), nil
 }
 
+// getMountRoot returns the root of the cgroup2 mount at mountpoint, i.e.
+// the cgroup directory which is visible as mountpoint itself. This is "/"
+// unless cgroupfs is bind-mounted from a subtree, as is the case for nested
+// containers sharing the host cgroup namespace.
+func getMountRoot(mountpoint string) (string, error) {
+	mnt, err := getMount(mountpoint)
+	if err != nil {
+		return "", err
+	}
+	if mnt == nil {
+		// No cgroup2 mount found (e.g. a fake cgroupfs in tests);
+		// assume the mountpoint is the root of the hierarchy.
+		return "/", nil
+	}
+	return mnt.Root, nil
+}
+
+// getMount returns the mountinfo entry of the cgroup2 mount at mountpoint,
+// or nil if there is no such mount.
+func getMount(mountpoint string) (*mountinfo.Info, error) {
+	mounts, err := mountinfo.GetMounts(func(m *mountinfo.Info) (bool, bool) {
+		return m.Mountpoint != mountpoint || m.FSType != "cgroup2", false
+	})
+	if err != nil {
+		return nil, err
+	}
+	if len(mounts) == 0 {
+		return nil, nil
+	}
+	// The last matching mount is the one that is visible.
+	return mounts[len(mounts)-1], nil
+}
+
+// relToMountRoot converts a cgroup path as seen in /proc/self/cgroup to a
+// path relative to the cgroup2 mount whose root is mountRoot. This is needed
+// for nested containers because in /proc/self/cgroup we may see paths from
+// the host, which don't exist in the container.
+func relToMountRoot(cgroup, mountRoot string) (string, error) {
+	// Inside a cgroup namespace, a process which is not under the namespace
+	// root sees its cgroup as "/../...", which can't be reached from here.
+	if cgroup == "/.." || strings.HasPrefix(cgroup, "/../") {
+		return "", fmt.Errorf("own cgroup %q is outside of the cgroup namespace root", cgroup)
+	}
+	if mountRoot == "/" {
+		return cgroup, nil
+	}
+	rel, err := filepath.Rel(mountRoot, cgroup)
+	if err != nil {
+		return "", err
+	}
+	if rel == ".." || strings.HasPrefix(rel, "../") {
+		return "", fmt.Errorf("own cgroup %q is not under the cgroup mount root %q", cgroup, mountRoot)
+	}
+	return filepath.Join("/", rel), nil
+}
+
 // parseCgroupFile parses /proc/PID/cgroup file and return string
 func parseCgroupFile(path string) (string, error) {
 	f, err := os.Open(path)
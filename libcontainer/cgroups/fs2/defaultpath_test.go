@@ -58,6 +58,14 @@ func TestDefaultDirPath(t *testing.T) {
 		// environment so we can't run this test.
 		t.Skipf("can't get own cgroup: %v", err)
 	}
+	mountRoot, err := getMountRoot(UnifiedMountpoint)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ownCgroup, err = relToMountRoot(ownCgroup, mountRoot)
+	if err != nil {
+		t.Skipf("can't get own cgroup relative to mount: %v", err)
+	}
 	ownCgroup = filepath.Dir(ownCgroup)
 
 	cases := []struct {
@@ -85,3 +93,36 @@ func TestDefaultDirPath(t *testing.T) {
 		}
 	}
 }
+
+func TestRelToMountRoot(t *testing.T) {
+	cases := []struct {
+		cgroup    string
+		mountRoot string
+		expected  string
+		isErr     bool
+	}{
+		{cgroup: "/user.slice/session-1.scope", mountRoot: "/", expected: "/user.slice/session-1.scope"},
+		{cgroup: "/", mountRoot: "/", expected: "/"},
+		{cgroup: "/docker/abc/init.scope", mountRoot: "/docker/abc", expected: "/init.scope"},
+		{cgroup: "/docker/abc", mountRoot: "/docker/abc", expected: "/"},
+		{cgroup: "/docker/def", mountRoot: "/docker/abc", isErr: true},
+		{cgroup: "/../../system.slice", mountRoot: "/", isErr: true},
+		{cgroup: "/..", mountRoot: "/", isErr: true},
+	}
+	for _, c := range cases {
+		got, err := relToMountRoot(c.cgroup, c.mountRoot)
+		if c.isErr {
+			if err == nil {
+				t.Errorf("%q (root %q): expected error, got nil", c.cgroup, c.mountRoot)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%q (root %q): unexpected error: %v", c.cgroup, c.mountRoot, err)
+			continue
+		}
+		if got != c.expected {
+			t.Errorf("%q (root %q): expected %q, got %q", c.cgroup, c.mountRoot, c.expected, got)
+		}
+	}
+}
@@ -5,6 +5,7 @@ import (
 
 	"golang.org/x/sys/unix"
 
+	cgroupdevices "github.com/opencontainers/runc/libcontainer/cgroups/devices"
 	"github.com/opencontainers/runc/libcontainer/cgroups/ebpf"
 	"github.com/opencontainers/runc/libcontainer/cgroups/ebpf/devicefilter"
 	"github.com/opencontainers/runc/libcontainer/configs"
@@ -39,14 +40,25 @@ func canSkipEBPFError(r *configs.Resources) bool {
 		return true
 	}
 
-	// We cannot ignore an eBPF load error if any rule if is a block rule or it
-	// doesn't permit all access modes.
-	//
-	// NOTE: This will sometimes trigger in cases where access modes are split
-	//       between different rules but to handle this correctly would require
-	//       using ".../libcontainer/cgroup/devices".Emulator.
+	// We cannot ignore an eBPF load error if any rule is a block rule or the
+	// effective ruleset doesn't permit all access modes. The rules are
+	// folded through the emulator first so that access modes split between
+	// several rules for the same device are merged before being checked.
+	emu := new(cgroupdevices.Emulator)
 	for _, dev := range r.Devices {
-		if !dev.Allow || !isRWM(dev.Permissions) {
+		if !dev.Allow {
+			return false
+		}
+		if err := emu.Apply(*dev); err != nil {
+			return false
+		}
+	}
+	rules, err := emu.Rules()
+	if err != nil {
+		return false
+	}
+	for _, rule := range rules {
+		if !isRWM(rule.Permissions) {
 			return false
 		}
 	}
@@ -63,7 +75,7 @@ func setDevices(dirPath string, r *configs.Resources) error {
 	}
 	dirFD, err := unix.Open(dirPath, unix.O_DIRECTORY|unix.O_RDONLY, 0o600)
 	if err != nil {
-		return fmt.Errorf("cannot get dir FD for %s", dirPath)
+		return fmt.Errorf("cannot get dir FD for %s: %w", dirPath, err)
 	}
 	defer unix.Close(dirFD)
 	if _, err := ebpf.LoadAttachCgroupDeviceFilter(insts, license, dirFD); err != nil {
@@ -0,0 +1,70 @@
+package fs2
+
+import (
+	"testing"
+
+	"github.com/opencontainers/runc/libcontainer/configs"
+	"github.com/opencontainers/runc/libcontainer/devices"
+	"github.com/opencontainers/runc/libcontainer/userns"
+)
+
+func TestCanSkipEBPFError(t *testing.T) {
+	if userns.RunningInUserNS() {
+		t.Skip("eBPF errors are always skipped in a user namespace")
+	}
+
+	allow := func(perms devices.Permissions) *devices.Rule {
+		return &devices.Rule{
+			Type:        devices.CharDevice,
+			Major:       1,
+			Minor:       3,
+			Permissions: perms,
+			Allow:       true,
+		}
+	}
+	testCases := []struct {
+		name  string
+		rules []*devices.Rule
+		skip  bool
+	}{
+		{
+			name: "no rules",
+			skip: true,
+		},
+		{
+			name:  "allow rwm",
+			rules: []*devices.Rule{allow("rwm")},
+			skip:  true,
+		},
+		{
+			name:  "allow r",
+			rules: []*devices.Rule{allow("r")},
+			skip:  false,
+		},
+		{
+			name:  "allow split access modes",
+			rules: []*devices.Rule{allow("r"), allow("wm")},
+			skip:  true,
+		},
+		{
+			name: "deny rule",
+			rules: []*devices.Rule{
+				allow("rwm"),
+				{
+					Type:        devices.WildcardDevice,
+					Major:       devices.Wildcard,
+					Minor:       devices.Wildcard,
+					Permissions: "rwm",
+					Allow:       false,
+				},
+			},
+			skip: false,
+		},
+	}
+	for _, tc := range testCases {
+		r := &configs.Resources{Devices: tc.rules}
+		if got := canSkipEBPFError(r); got != tc.skip {
+			t.Errorf("%s: expected canSkipEBPFError to be %v, got %v", tc.name, tc.skip, got)
+		}
+	}
+}
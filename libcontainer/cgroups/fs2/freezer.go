@@ -12,9 +12,10 @@ import (
 
 	"github.com/opencontainers/runc/libcontainer/cgroups"
 	"github.com/opencontainers/runc/libcontainer/configs"
+	"github.com/opencontainers/runc/libcontainer/system"
 )
 
-func setFreezer(dirPath string, state configs.FreezerState) error {
+func setFreezer(dirPath string, state configs.FreezerState) (Err error) {
 	var stateStr string
 	switch state {
 	case configs.Undefined:
@@ -39,6 +40,17 @@ func setFreezer(dirPath string, state configs.FreezerState) error {
 	}
 	defer fd.Close()
 
+	if state == configs.Frozen {
+		defer func() {
+			if Err != nil {
+				// Freezing failed, and it is bad and dangerous to leave
+				// the cgroup in a partially frozen state, so (try to)
+				// thaw it back.
+				_, _ = fd.WriteString("0")
+			}
+		}()
+	}
+
 	if _, err := fd.WriteString(stateStr); err != nil {
 		return err
 	}
@@ -96,32 +108,64 @@ func waitFrozen(dirPath string) (configs.FreezerState, error) {
 	// based on poll(2) or inotify(7) is possible, but it makes the code
 	// much more complicated. Maybe address this later.
 	const (
-		// Perform maxIter with waitTime in between iterations.
-		waitTime = 10 * time.Millisecond
-		maxIter  = 1000
+		// Start with minWait between iterations, doubling it every
+		// time up to maxWait, until timeout is reached.
+		minWait = 100 * time.Microsecond
+		maxWait = 10 * time.Millisecond
+		timeout = 10 * time.Second
 	)
+	waitTime := minWait
+	deadline := time.Now().Add(timeout)
 	scanner := bufio.NewScanner(fd)
-	for i := 0; scanner.Scan(); {
-		if i == maxIter {
-			return configs.Undefined, fmt.Errorf("timeout of %s reached waiting for the cgroup to freeze", waitTime*maxIter)
-		}
+	for scanner.Scan() {
 		line := scanner.Text()
 		val := strings.TrimPrefix(line, "frozen ")
-		if val != line { // got prefix
-			if val[0] == '1' {
-				return configs.Frozen, nil
-			}
-
-			i++
-			// wait, then re-read
-			time.Sleep(waitTime)
-			_, err := fd.Seek(0, 0)
-			if err != nil {
-				return configs.Undefined, err
+		if val == line { // no prefix
+			continue
+		}
+		if val[0] == '1' {
+			return configs.Frozen, nil
+		}
+		if time.Now().After(deadline) {
+			err := fmt.Errorf("%w (%s)", cgroups.ErrFreezeTimeout, timeout)
+			if pids := diskSleepPids(dirPath); len(pids) > 0 {
+				err = fmt.Errorf("%w (tasks in uninterruptible sleep: %v)", err, pids)
 			}
+			return configs.Undefined, err
+		}
+		// wait, then re-read
+		time.Sleep(waitTime)
+		if waitTime < maxWait {
+			waitTime *= 2
 		}
+		if _, err := fd.Seek(0, 0); err != nil {
+			return configs.Undefined, err
+		}
+		scanner = bufio.NewScanner(fd)
 	}
 	// Should only reach here either on read error,
 	// or if the file does not contain "frozen " line.
 	return configs.Undefined, scanner.Err()
 }
+
+// diskSleepPids returns the pids of the processes in the cgroup (and its
+// sub-cgroups) which are in uninterruptible sleep. Such tasks can't be
+// frozen until they leave this state, which is the most common reason
+// for a freeze to time out.
+func diskSleepPids(dirPath string) []int {
+	pids, err := cgroups.GetAllPids(dirPath)
+	if err != nil {
+		return nil
+	}
+	var stuck []int
+	for _, pid := range pids {
+		stat, err := system.Stat(pid)
+		if err != nil {
+			continue
+		}
+		if stat.State == system.DiskSleep {
+			stuck = append(stuck, pid)
+		}
+	}
+	return stuck
+}
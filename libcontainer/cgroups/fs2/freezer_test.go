@@ -0,0 +1,42 @@
+package fs2
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/opencontainers/runc/libcontainer/cgroups"
+	"github.com/opencontainers/runc/libcontainer/configs"
+)
+
+func TestWaitFrozen(t *testing.T) {
+	// We're using a fake cgroupfs.
+	cgroups.TestMode = true
+
+	fakeCgroupDir := t.TempDir()
+	eventsFile := filepath.Join(fakeCgroupDir, "cgroup.events")
+	if err := os.WriteFile(eventsFile, []byte("populated 1\nfrozen 0\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	// Simulate the kernel finishing the freeze a bit later. The file is
+	// updated in place so that the reader never sees it truncated.
+	f, err := os.OpenFile(eventsFile, os.O_WRONLY, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	go func() {
+		time.Sleep(50 * time.Millisecond)
+		_, _ = f.WriteAt([]byte("1"), int64(len("populated 1\nfrozen ")))
+	}()
+
+	state, err := waitFrozen(fakeCgroupDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if state != configs.Frozen {
+		t.Fatalf("expected state %q, got %q", configs.Frozen, state)
+	}
+}
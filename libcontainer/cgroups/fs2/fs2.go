@@ -1,11 +1,15 @@
 package fs2
 
 import (
+	"bufio"
 	"errors"
 	"fmt"
 	"os"
 	"strings"
 
+	"github.com/sirupsen/logrus"
+	"golang.org/x/sys/unix"
+
 	"github.com/opencontainers/runc/libcontainer/cgroups"
 	"github.com/opencontainers/runc/libcontainer/cgroups/fscommon"
 	"github.com/opencontainers/runc/libcontainer/configs"
@@ -79,7 +83,7 @@ func (m *manager) Apply(pid int) error {
 		return err
 	}
 	if err := cgroups.WriteCgroupProc(m.dirPath, pid); err != nil {
-		return err
+		return nsDelegateError("move process into", m.dirPath, err)
 	}
 	return nil
 }
@@ -122,6 +126,19 @@ func (m *manager) GetStats() (*cgroups.Stats, error) {
 	if err := fscommon.RdmaGetStats(m.dirPath, st); err != nil && !os.IsNotExist(err) {
 		errs = append(errs, err)
 	}
+	// misc (since kernel 5.13)
+	if err := statMisc(m.dirPath, st); err != nil && !os.IsNotExist(err) {
+		errs = append(errs, err)
+	}
+	// cpu.pressure, memory.pressure and io.pressure (since kernel 4.20,
+	// and only if the kernel is configured with CONFIG_PSI)
+	if err := statPSI(m.dirPath, st); err != nil {
+		errs = append(errs, err)
+	}
+	// cgroup.stat (since kernel 4.14)
+	if err := statCgroup(m.dirPath, st); err != nil && !os.IsNotExist(err) {
+		errs = append(errs, err)
+	}
 	if len(errs) > 0 && !m.config.Rootless {
 		return st, fmt.Errorf("error while statting cgroup v2: %+v", errs)
 	}
@@ -140,7 +157,9 @@ func (m *manager) Freeze(state configs.FreezerState) error {
 }
 
 func (m *manager) Destroy() error {
-	return cgroups.RemovePath(m.dirPath)
+	// The removal is retried, as the cgroup can still be busy right
+	// after its processes are killed.
+	return cgroups.RemovePaths(map[string]string{"": m.dirPath})
 }
 
 func (m *manager) Path(_ string) string {
@@ -155,19 +174,19 @@ func (m *manager) Set(r *configs.Resources) error {
 		return err
 	}
 	// pids (since kernel 4.5)
-	if err := setPids(m.dirPath, r); err != nil {
+	if err := setPids(m.dirPath, r); err != nil && !m.ignoreRootlessError("pids", err) {
 		return err
 	}
 	// memory (since kernel 4.5)
-	if err := setMemory(m.dirPath, r); err != nil {
+	if err := setMemory(m.dirPath, r); err != nil && !m.ignoreRootlessError("memory", err) {
 		return err
 	}
 	// io (since kernel 4.5)
-	if err := setIo(m.dirPath, r); err != nil {
+	if err := setIo(m.dirPath, r); err != nil && !m.ignoreRootlessError("io", err) {
 		return err
 	}
 	// cpu (since kernel 4.15)
-	if err := setCpu(m.dirPath, r); err != nil {
+	if err := setCpu(m.dirPath, r); err != nil && !m.ignoreRootlessError("cpu", err) {
 		return err
 	}
 	// devices (since kernel 4.15, pseudo-controller)
@@ -179,15 +198,19 @@ func (m *manager) Set(r *configs.Resources) error {
 		return err
 	}
 	// cpuset (since kernel 5.0)
-	if err := setCpuset(m.dirPath, r); err != nil {
+	if err := setCpuset(m.dirPath, r); err != nil && !m.ignoreRootlessError("cpuset", err) {
 		return err
 	}
 	// hugetlb (since kernel 5.6)
-	if err := setHugeTlb(m.dirPath, r); err != nil {
+	if err := setHugeTlb(m.dirPath, r); err != nil && !m.ignoreRootlessError("hugetlb", err) {
 		return err
 	}
 	// rdma (since kernel 4.11)
-	if err := fscommon.RdmaSet(m.dirPath, r); err != nil {
+	if err := fscommon.RdmaSet(m.dirPath, r); err != nil && !m.ignoreRootlessError("rdma", err) {
+		return err
+	}
+	// misc (since kernel 5.13)
+	if err := setMisc(m.dirPath, r); err != nil && !m.ignoreRootlessError("misc", err) {
 		return err
 	}
 	// freezer (since kernel 5.2, pseudo-controller)
@@ -201,6 +224,22 @@ func (m *manager) Set(r *configs.Resources) error {
 	return nil
 }
 
+// ignoreRootlessError returns whether err, which happened while setting
+// resources of the given controller, can be ignored. This is the case for
+// rootless containers when the controller is not delegated to the user,
+// so that only the delegated controllers are used and a warning is logged
+// for the rest.
+func (m *manager) ignoreRootlessError(controller string, err error) bool {
+	if !m.config.Rootless {
+		return false
+	}
+	if _, ok := m.controllers[controller]; ok {
+		return false
+	}
+	logrus.Warnf("unable to set %s resources: controller not delegated to %s: %v", controller, m.dirPath, err)
+	return true
+}
+
 func (m *manager) setUnified(res map[string]string) error {
 	for k, v := range res {
 		if strings.Contains(k, "/") {
@@ -245,6 +284,33 @@ func (m *manager) Exists() bool {
 	return cgroups.PathExists(m.dirPath)
 }
 
+func statCgroup(dirPath string, stats *cgroups.Stats) error {
+	const file = "cgroup.stat"
+	f, err := cgroups.OpenFile(dirPath, file, os.O_RDONLY)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	sc := bufio.NewScanner(f)
+	for sc.Scan() {
+		k, v, err := fscommon.ParseKeyValue(sc.Text())
+		if err != nil {
+			return &parseError{Path: dirPath, File: file, Err: err}
+		}
+		switch k {
+		case "nr_descendants":
+			stats.CgroupStats.NrDescendants = v
+		case "nr_dying_descendants":
+			stats.CgroupStats.NrDyingDescendants = v
+		}
+	}
+	if err := sc.Err(); err != nil {
+		return &parseError{Path: dirPath, File: file, Err: err}
+	}
+	return nil
+}
+
 func OOMKillCount(path string) (uint64, error) {
 	return fscommon.GetValueByKey(path, "memory.events", "oom_kill")
 }
@@ -257,3 +323,14 @@ func (m *manager) OOMKillCount() (uint64, error) {
 
 	return c, err
 }
+
+func (m *manager) Reclaim(bytes uint64) error {
+	return reclaimMemory(m.dirPath, bytes)
+}
+
+func (m *manager) CgroupFD() (*os.File, error) {
+	if err := CreateCgroupPath(m.dirPath, m.config); err != nil {
+		return nil, err
+	}
+	return cgroups.OpenFile(m.dirPath, "", unix.O_PATH|unix.O_DIRECTORY)
+}
@@ -0,0 +1,85 @@
+package fs2
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/opencontainers/runc/libcontainer/cgroups"
+	"github.com/opencontainers/runc/libcontainer/configs"
+)
+
+func TestSetUnified(t *testing.T) {
+	// We're using a fake cgroupfs.
+	cgroups.TestMode = true
+
+	fakeCgroupDir := t.TempDir()
+	m := &manager{
+		config:      &configs.Cgroup{Resources: &configs.Resources{}},
+		dirPath:     fakeCgroupDir,
+		controllers: map[string]struct{}{"memory": {}},
+	}
+	res := map[string]string{
+		"memory.high":      "1048576",
+		"memory.oom.group": "1",
+	}
+	if err := m.setUnified(res); err != nil {
+		t.Fatal(err)
+	}
+	for k, v := range res {
+		got, err := os.ReadFile(filepath.Join(fakeCgroupDir, k))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(got) != v {
+			t.Errorf("expected %s to be %q, got %q", k, v, got)
+		}
+	}
+
+	for _, k := range []string{"../memory.max", "memory/max"} {
+		if err := m.setUnified(map[string]string{k: "1"}); err == nil {
+			t.Errorf("expected an error for unified key %q, got nil", k)
+		}
+	}
+}
+
+func TestStatCgroup(t *testing.T) {
+	// We're using a fake cgroupfs.
+	cgroups.TestMode = true
+
+	fakeCgroupDir := t.TempDir()
+	data := "nr_descendants 3\nnr_dying_descendants 17\n"
+	if err := os.WriteFile(filepath.Join(fakeCgroupDir, "cgroup.stat"), []byte(data), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	st := cgroups.NewStats()
+	if err := statCgroup(fakeCgroupDir, st); err != nil {
+		t.Fatal(err)
+	}
+	expected := cgroups.CgroupStats{NrDescendants: 3, NrDyingDescendants: 17}
+	if st.CgroupStats != expected {
+		t.Errorf("expected cgroup stats %+v, got %+v", expected, st.CgroupStats)
+	}
+}
+
+func TestIgnoreRootlessError(t *testing.T) {
+	m := &manager{
+		config:      &configs.Cgroup{Rootless: true},
+		dirPath:     t.TempDir(),
+		controllers: map[string]struct{}{"pids": {}},
+	}
+	err := os.ErrNotExist
+
+	if m.ignoreRootlessError("pids", err) {
+		t.Error("expected error for a delegated controller not to be ignored")
+	}
+	if !m.ignoreRootlessError("memory", err) {
+		t.Error("expected error for a non-delegated controller to be ignored")
+	}
+
+	m.config.Rootless = false
+	if m.ignoreRootlessError("memory", err) {
+		t.Error("expected error not to be ignored when not rootless")
+	}
+}
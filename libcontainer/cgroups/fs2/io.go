@@ -3,12 +3,14 @@ package fs2
 import (
 	"bufio"
 	"bytes"
+	"errors"
 	"fmt"
 	"os"
 	"strconv"
 	"strings"
 
 	"github.com/sirupsen/logrus"
+	"golang.org/x/sys/unix"
 
 	"github.com/opencontainers/runc/libcontainer/cgroups"
 	"github.com/opencontainers/runc/libcontainer/configs"
@@ -20,7 +22,8 @@ func isIoSet(r *configs.Resources) bool {
 		len(r.BlkioThrottleReadBpsDevice) > 0 ||
 		len(r.BlkioThrottleWriteBpsDevice) > 0 ||
 		len(r.BlkioThrottleReadIOPSDevice) > 0 ||
-		len(r.BlkioThrottleWriteIOPSDevice) > 0
+		len(r.BlkioThrottleWriteIOPSDevice) > 0 ||
+		len(r.BlkioLatencyDevice) > 0
 }
 
 // bfqDeviceWeightSupported checks for per-device BFQ weight support (added
@@ -37,60 +40,90 @@ func bfqDeviceWeightSupported(bfq *os.File) bool {
 	return err != nil
 }
 
-func setIo(dirPath string, r *configs.Resources) error {
-	if !isIoSet(r) {
-		return nil
+// setIoWeight sets the IO weights using both io.weight (used by the iocost
+// controller) and io.bfq.weight (used by the BFQ IO scheduler), whichever
+// of them are available, so that the weights take effect no matter which
+// of the two is in use. It is an error if neither is available.
+func setIoWeight(dirPath string, r *configs.Resources) error {
+	ioWeight, err := cgroups.OpenFile(dirPath, "io.weight", os.O_RDWR)
+	if err == nil {
+		defer ioWeight.Close()
+	} else if !os.IsNotExist(err) {
+		return err
 	}
-
-	// If BFQ IO scheduler is available, use it.
-	var bfq *os.File
-	if r.BlkioWeight != 0 || len(r.BlkioWeightDevice) > 0 {
-		var err error
-		bfq, err = cgroups.OpenFile(dirPath, "io.bfq.weight", os.O_RDWR)
-		if err == nil {
-			defer bfq.Close()
-		} else if !os.IsNotExist(err) {
-			return err
-		}
+	bfq, err := cgroups.OpenFile(dirPath, "io.bfq.weight", os.O_RDWR)
+	if err == nil {
+		defer bfq.Close()
+	} else if !os.IsNotExist(err) {
+		return err
+	}
+	if ioWeight == nil && bfq == nil {
+		return errors.New("io weight not supported: neither io.weight nor io.bfq.weight is available")
 	}
 
 	if r.BlkioWeight != 0 {
-		if bfq != nil { // Use BFQ.
-			if _, err := bfq.WriteString(strconv.FormatUint(uint64(r.BlkioWeight), 10)); err != nil {
+		if ioWeight != nil {
+			// io.weight range is [1-10000], use a conversion scheme.
+			v := cgroups.ConvertBlkIOToIOWeightValue(r.BlkioWeight)
+			if _, err := ioWeight.WriteString(strconv.FormatUint(v, 10)); err != nil {
 				return err
 			}
-		} else {
-			// Fallback to io.weight with a conversion scheme.
-			v := cgroups.ConvertBlkIOToIOWeightValue(r.BlkioWeight)
-			if err := cgroups.WriteFile(dirPath, "io.weight", strconv.FormatUint(v, 10)); err != nil {
+		}
+		if bfq != nil {
+			// io.bfq.weight range is [1-1000], same as blkio weight.
+			if _, err := bfq.WriteString(strconv.FormatUint(uint64(r.BlkioWeight), 10)); err != nil {
 				return err
 			}
 		}
 	}
-	if bfqDeviceWeightSupported(bfq) {
-		for _, wd := range r.BlkioWeightDevice {
-			if _, err := bfq.WriteString(wd.WeightString() + "\n"); err != nil {
-				return fmt.Errorf("setting device weight %q: %w", wd.WeightString(), err)
+	if len(r.BlkioWeightDevice) == 0 {
+		return nil
+	}
+	bfqPerDevice := bfqDeviceWeightSupported(bfq)
+	if ioWeight == nil && !bfqPerDevice {
+		logrus.Warn("per-device io weight is not supported by the kernel, ignoring")
+		return nil
+	}
+	// Unlike the default weight, a per-device weight is only written to
+	// one of the files: io.weight if the device accepts it (i.e. iocost
+	// is enabled for it), and io.bfq.weight otherwise.
+	for _, wd := range r.BlkioWeightDevice {
+		if ioWeight != nil {
+			v := cgroups.ConvertBlkIOToIOWeightValue(wd.Weight)
+			str := fmt.Sprintf("%d:%d %d", wd.Major, wd.Minor, v)
+			_, err := ioWeight.WriteString(str)
+			if err == nil {
+				continue
+			}
+			if !bfqPerDevice || !(errors.Is(err, unix.EOPNOTSUPP) || errors.Is(err, unix.ENOENT)) {
+				return fmt.Errorf("setting device weight %q: %w", str, err)
 			}
 		}
-	}
-	for _, td := range r.BlkioThrottleReadBpsDevice {
-		if err := cgroups.WriteFile(dirPath, "io.max", td.StringName("rbps")); err != nil {
-			return err
+		if _, err := bfq.WriteString(wd.WeightString() + "\n"); err != nil {
+			return fmt.Errorf("setting device weight %q: %w", wd.WeightString(), err)
 		}
 	}
-	for _, td := range r.BlkioThrottleWriteBpsDevice {
-		if err := cgroups.WriteFile(dirPath, "io.max", td.StringName("wbps")); err != nil {
+	return nil
+}
+
+func setIo(dirPath string, r *configs.Resources) error {
+	if !isIoSet(r) {
+		return nil
+	}
+
+	if r.BlkioWeight != 0 || len(r.BlkioWeightDevice) > 0 {
+		if err := setIoWeight(dirPath, r); err != nil {
 			return err
 		}
 	}
-	for _, td := range r.BlkioThrottleReadIOPSDevice {
-		if err := cgroups.WriteFile(dirPath, "io.max", td.StringName("riops")); err != nil {
+	for _, line := range ioMaxLines(r) {
+		if err := cgroups.WriteFile(dirPath, "io.max", line); err != nil {
 			return err
 		}
 	}
-	for _, td := range r.BlkioThrottleWriteIOPSDevice {
-		if err := cgroups.WriteFile(dirPath, "io.max", td.StringName("wiops")); err != nil {
+	// io.latency requires CONFIG_BLK_CGROUP_IOLATENCY (since kernel 4.19).
+	for _, ld := range r.BlkioLatencyDevice {
+		if err := cgroups.WriteFile(dirPath, "io.latency", ld.String()); err != nil {
 			return err
 		}
 	}
@@ -98,6 +131,40 @@ func setIo(dirPath string, r *configs.Resources) error {
 	return nil
 }
 
+// ioMaxLines converts the throttle device lists into io.max lines, one per
+// device, combining all the limits set for the device. A zero rate removes
+// the limit, which is done by writing "max".
+func ioMaxLines(r *configs.Resources) []string {
+	type device struct{ major, minor int64 }
+	var (
+		order  []device
+		limits = map[device][]string{}
+	)
+	add := func(key string, tds []*configs.ThrottleDevice) {
+		for _, td := range tds {
+			dev := device{td.Major, td.Minor}
+			if _, ok := limits[dev]; !ok {
+				order = append(order, dev)
+			}
+			val := "max"
+			if td.Rate != 0 {
+				val = strconv.FormatUint(td.Rate, 10)
+			}
+			limits[dev] = append(limits[dev], key+"="+val)
+		}
+	}
+	add("rbps", r.BlkioThrottleReadBpsDevice)
+	add("wbps", r.BlkioThrottleWriteBpsDevice)
+	add("riops", r.BlkioThrottleReadIOPSDevice)
+	add("wiops", r.BlkioThrottleWriteIOPSDevice)
+
+	lines := make([]string, 0, len(order))
+	for _, dev := range order {
+		lines = append(lines, fmt.Sprintf("%d:%d %s", dev.major, dev.minor, strings.Join(limits[dev], " ")))
+	}
+	return lines
+}
+
 func readCgroup2MapFile(dirPath string, name string) (map[string][]string, error) {
 	ret := map[string][]string{}
 	f, err := cgroups.OpenFile(dirPath, name, os.O_RDONLY)
@@ -159,6 +226,9 @@ func statIo(dirPath string, stats *cgroups.Stats) error {
 			case "wbytes":
 				op = "Write"
 				targetTable = &parsedStats.IoServiceBytesRecursive
+			case "dbytes":
+				op = "Discard"
+				targetTable = &parsedStats.IoServiceBytesRecursive
 			// Equivalent to cgroupv1's blkio.io_serviced.
 			case "rios":
 				op = "Read"
@@ -166,6 +236,9 @@ func statIo(dirPath string, stats *cgroups.Stats) error {
 			case "wios":
 				op = "Write"
 				targetTable = &parsedStats.IoServicedRecursive
+			case "dios":
+				op = "Discard"
+				targetTable = &parsedStats.IoServicedRecursive
 			default:
 				// Skip over entries we cannot map to cgroupv1 stats for now.
 				// In the future we should expand the stats struct to include
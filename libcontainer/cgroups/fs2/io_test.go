@@ -5,9 +5,12 @@ import (
 	"path/filepath"
 	"reflect"
 	"sort"
+	"strconv"
+	"strings"
 	"testing"
 
 	"github.com/opencontainers/runc/libcontainer/cgroups"
+	"github.com/opencontainers/runc/libcontainer/configs"
 )
 
 const exampleIoStatData = `254:1 rbytes=6901432320 wbytes=14245535744 rios=263278 wios=248603 dbytes=0 dios=0
@@ -18,18 +21,24 @@ var exampleIoStatsParsed = cgroups.BlkioStats{
 	IoServiceBytesRecursive: []cgroups.BlkioStatEntry{
 		{Major: 254, Minor: 1, Value: 6901432320, Op: "Read"},
 		{Major: 254, Minor: 1, Value: 14245535744, Op: "Write"},
+		{Major: 254, Minor: 1, Value: 0, Op: "Discard"},
 		{Major: 254, Minor: 0, Value: 2702336, Op: "Read"},
 		{Major: 254, Minor: 0, Value: 0, Op: "Write"},
+		{Major: 254, Minor: 0, Value: 0, Op: "Discard"},
 		{Major: 259, Minor: 0, Value: 6911345664, Op: "Read"},
 		{Major: 259, Minor: 0, Value: 14245536256, Op: "Write"},
+		{Major: 259, Minor: 0, Value: 530485248, Op: "Discard"},
 	},
 	IoServicedRecursive: []cgroups.BlkioStatEntry{
 		{Major: 254, Minor: 1, Value: 263278, Op: "Read"},
 		{Major: 254, Minor: 1, Value: 248603, Op: "Write"},
+		{Major: 254, Minor: 1, Value: 0, Op: "Discard"},
 		{Major: 254, Minor: 0, Value: 97, Op: "Read"},
 		{Major: 254, Minor: 0, Value: 0, Op: "Write"},
+		{Major: 254, Minor: 0, Value: 0, Op: "Discard"},
 		{Major: 259, Minor: 0, Value: 264538, Op: "Read"},
 		{Major: 259, Minor: 0, Value: 244914, Op: "Write"},
+		{Major: 259, Minor: 0, Value: 2, Op: "Discard"},
 	},
 }
 
@@ -79,3 +88,88 @@ func TestStatIo(t *testing.T) {
 		t.Errorf("parsed cgroupv2 io.stat doesn't match expected result: \ngot %#v\nexpected %#v\n", gotStats.BlkioStats, exampleIoStatsParsed)
 	}
 }
+
+func TestSetIoWeight(t *testing.T) {
+	// We're using a fake cgroupfs.
+	cgroups.TestMode = true
+
+	fakeCgroupDir := t.TempDir()
+	r := &configs.Resources{
+		BlkioWeight:       500,
+		BlkioWeightDevice: []*configs.WeightDevice{configs.NewWeightDevice(8, 0, 1000, 0)},
+	}
+
+	// Neither io.weight nor io.bfq.weight is available.
+	if err := setIoWeight(fakeCgroupDir, r); err == nil {
+		t.Fatal("expected error, got nil")
+	}
+
+	// Only io.weight is available.
+	if err := os.WriteFile(filepath.Join(fakeCgroupDir, "io.weight"), nil, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := setIoWeight(fakeCgroupDir, r); err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(filepath.Join(fakeCgroupDir, "io.weight"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	weight := strconv.FormatUint(cgroups.ConvertBlkIOToIOWeightValue(500), 10)
+	for _, expected := range []string{weight, "8:0 10000"} {
+		if !strings.Contains(string(data), expected) {
+			t.Errorf("expected io.weight to contain %q, got %q", expected, data)
+		}
+	}
+
+	// Both are available, and BFQ supports per-device weights. The
+	// per-device weight must only be written to io.weight.
+	if err := os.WriteFile(filepath.Join(fakeCgroupDir, "io.weight"), nil, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(fakeCgroupDir, "io.bfq.weight"), []byte("default 100\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := setIoWeight(fakeCgroupDir, r); err != nil {
+		t.Fatal(err)
+	}
+	data, err = os.ReadFile(filepath.Join(fakeCgroupDir, "io.weight"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(data), "8:0 10000") {
+		t.Errorf("expected io.weight to contain %q, got %q", "8:0 10000", data)
+	}
+	data, err = os.ReadFile(filepath.Join(fakeCgroupDir, "io.bfq.weight"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(data), "8:0") {
+		t.Errorf("expected no device weight in io.bfq.weight, got %q", data)
+	}
+}
+
+func TestIoMaxLines(t *testing.T) {
+	r := &configs.Resources{
+		BlkioThrottleReadBpsDevice: []*configs.ThrottleDevice{
+			configs.NewThrottleDevice(8, 0, 1048576),
+			configs.NewThrottleDevice(8, 16, 0),
+		},
+		BlkioThrottleWriteBpsDevice: []*configs.ThrottleDevice{
+			configs.NewThrottleDevice(8, 0, 2097152),
+		},
+		BlkioThrottleReadIOPSDevice: []*configs.ThrottleDevice{
+			configs.NewThrottleDevice(8, 16, 100),
+		},
+		BlkioThrottleWriteIOPSDevice: []*configs.ThrottleDevice{
+			configs.NewThrottleDevice(8, 0, 200),
+		},
+	}
+	expected := []string{
+		"8:0 rbps=1048576 wbps=2097152 wiops=200",
+		"8:16 rbps=max riops=100",
+	}
+	if got := ioMaxLines(r); !reflect.DeepEqual(got, expected) {
+		t.Errorf("expected io.max lines %q, got %q", expected, got)
+	}
+}
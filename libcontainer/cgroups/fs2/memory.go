@@ -3,11 +3,13 @@ package fs2
 import (
 	"bufio"
 	"errors"
+	"fmt"
 	"math"
 	"os"
 	"strconv"
 	"strings"
 
+	"github.com/sirupsen/logrus"
 	"golang.org/x/sys/unix"
 
 	"github.com/opencontainers/runc/libcontainer/cgroups"
@@ -33,7 +35,7 @@ func numToStr(value int64) (ret string) {
 }
 
 func isMemorySet(r *configs.Resources) bool {
-	return r.MemoryReservation != 0 || r.Memory != 0 || r.MemorySwap != 0
+	return r.MemoryReservation != 0 || r.Memory != 0 || r.MemorySwap != 0 || r.MemoryZswapMax != nil
 }
 
 func setMemory(dirPath string, r *configs.Resources) error {
@@ -62,6 +64,10 @@ func setMemory(dirPath string, r *configs.Resources) error {
 		}
 	}
 
+	if err := setMemoryZswap(dirPath, r); err != nil {
+		return err
+	}
+
 	// cgroup.Resources.KernelMemory is ignored
 
 	if val := numToStr(r.MemoryReservation); val != "" {
@@ -73,6 +79,46 @@ func setMemory(dirPath string, r *configs.Resources) error {
 	return nil
 }
 
+func setMemoryZswap(dirPath string, r *configs.Resources) error {
+	if r.MemoryZswapMax == nil {
+		return nil
+	}
+	val := "max"
+	if *r.MemoryZswapMax != -1 {
+		val = strconv.FormatInt(*r.MemoryZswapMax, 10)
+	}
+	if err := cgroups.WriteFile(dirPath, "memory.zswap.max", val); err != nil {
+		// memory.zswap.max is only available since kernel 5.19,
+		// and only if the kernel is built with CONFIG_ZSWAP.
+		if errors.Is(err, os.ErrNotExist) {
+			logrus.Debugf("memory.zswap.max is not supported by the kernel, ignoring zswap limit")
+			return nil
+		}
+		return err
+	}
+
+	return nil
+}
+
+// reclaimMemory writes to memory.reclaim to trigger proactive reclaim.
+// The kernel returns EAGAIN if it was unable to reclaim the requested
+// amount of memory.
+func reclaimMemory(dirPath string, bytes uint64) error {
+	if bytes == 0 {
+		return nil
+	}
+	if err := cgroups.WriteFile(dirPath, "memory.reclaim", strconv.FormatUint(bytes, 10)); err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return fmt.Errorf("memory reclaim not supported (requires kernel 5.19+): %w", err)
+		}
+		if errors.Is(err, unix.EAGAIN) {
+			return fmt.Errorf("unable to reclaim %d bytes of memory: %w", bytes, err)
+		}
+		return err
+	}
+	return nil
+}
+
 func statMemory(dirPath string, stats *cgroups.Stats) error {
 	const file = "memory.stat"
 	statsFile, err := cgroups.OpenFile(dirPath, file, os.O_RDONLY)
@@ -97,6 +143,14 @@ func statMemory(dirPath string, stats *cgroups.Stats) error {
 	// cgroup v2 is always hierarchical.
 	stats.MemoryStats.UseHierarchy = true
 
+	// The root cgroup does not have memory.events.
+	events, err := readMemoryEvents(dirPath)
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	stats.MemoryStats.Events = events
+	stats.MemoryStats.OOMKillCount = events["oom_kill"]
+
 	memoryUsage, err := getMemoryDataV2(dirPath, "")
 	if err != nil {
 		if errors.Is(err, unix.ENOENT) && dirPath == UnifiedMountpoint {
@@ -123,6 +177,30 @@ func statMemory(dirPath string, stats *cgroups.Stats) error {
 	return nil
 }
 
+// readMemoryEvents reads the memory.events counters.
+func readMemoryEvents(dirPath string) (map[string]uint64, error) {
+	const file = "memory.events"
+	f, err := cgroups.OpenFile(dirPath, file, os.O_RDONLY)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	events := make(map[string]uint64)
+	sc := bufio.NewScanner(f)
+	for sc.Scan() {
+		k, v, err := fscommon.ParseKeyValue(sc.Text())
+		if err != nil {
+			return nil, &parseError{Path: dirPath, File: file, Err: err}
+		}
+		events[k] = v
+	}
+	if err := sc.Err(); err != nil {
+		return nil, &parseError{Path: dirPath, File: file, Err: err}
+	}
+	return events, nil
+}
+
 func getMemoryDataV2(path, name string) (cgroups.MemoryData, error) {
 	memoryData := cgroups.MemoryData{}
 
@@ -151,6 +229,13 @@ func getMemoryDataV2(path, name string) (cgroups.MemoryData, error) {
 	}
 	memoryData.Limit = value
 
+	// memory.peak since kernel 5.19, memory.swap.peak since kernel 6.5.
+	value, err = fscommon.GetCgroupParamUint(path, moduleName+".peak")
+	if err != nil && !os.IsNotExist(err) {
+		return cgroups.MemoryData{}, err
+	}
+	memoryData.MaxUsage = value
+
 	return memoryData, nil
 }
 
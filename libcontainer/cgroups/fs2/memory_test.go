@@ -0,0 +1,74 @@
+package fs2
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/opencontainers/runc/libcontainer/cgroups"
+	"github.com/opencontainers/runc/libcontainer/configs"
+)
+
+func TestSetMemoryZswap(t *testing.T) {
+	// We're using a fake cgroupfs.
+	cgroups.TestMode = true
+
+	for _, tc := range []struct {
+		limit    int64
+		expected string
+	}{
+		{limit: -1, expected: "max"},
+		{limit: 0, expected: "0"},
+		{limit: 1048576, expected: "1048576"},
+	} {
+		fakeCgroupDir := t.TempDir()
+		limit := tc.limit
+		r := &configs.Resources{MemoryZswapMax: &limit}
+		if err := setMemory(fakeCgroupDir, r); err != nil {
+			t.Fatal(err)
+		}
+		got, err := os.ReadFile(filepath.Join(fakeCgroupDir, "memory.zswap.max"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(got) != tc.expected {
+			t.Errorf("expected memory.zswap.max to be %q, got %q", tc.expected, got)
+		}
+	}
+}
+
+func TestGetMemoryDataV2Peak(t *testing.T) {
+	// We're using a fake cgroupfs.
+	cgroups.TestMode = true
+
+	fakeCgroupDir := t.TempDir()
+	for file, data := range map[string]string{
+		"memory.current": "1024\n",
+		"memory.max":     "max\n",
+		"memory.peak":    "4096\n",
+	} {
+		if err := os.WriteFile(filepath.Join(fakeCgroupDir, file), []byte(data), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	data, err := getMemoryDataV2(fakeCgroupDir, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if data.MaxUsage != 4096 {
+		t.Errorf("expected max usage 4096, got %d", data.MaxUsage)
+	}
+
+	// Older kernels have no memory.peak.
+	if err := os.Remove(filepath.Join(fakeCgroupDir, "memory.peak")); err != nil {
+		t.Fatal(err)
+	}
+	data, err = getMemoryDataV2(fakeCgroupDir, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if data.MaxUsage != 0 {
+		t.Errorf("expected max usage 0, got %d", data.MaxUsage)
+	}
+}
@@ -0,0 +1,88 @@
+package fs2
+
+import (
+	"bufio"
+	"math"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/opencontainers/runc/libcontainer/cgroups"
+	"github.com/opencontainers/runc/libcontainer/cgroups/fscommon"
+	"github.com/opencontainers/runc/libcontainer/configs"
+)
+
+func isMiscSet(r *configs.Resources) bool {
+	return len(r.Misc) > 0
+}
+
+func setMisc(dirPath string, r *configs.Resources) error {
+	if !isMiscSet(r) {
+		return nil
+	}
+	for name, limit := range r.Misc {
+		if err := cgroups.WriteFile(dirPath, "misc.max", name+" "+strconv.FormatUint(limit, 10)); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// readMiscFile reads a misc controller file, such as misc.current or
+// misc.max, which has a "resource value" format, one resource per line.
+func readMiscFile(dirPath, file string) (map[string]uint64, error) {
+	f, err := cgroups.OpenFile(dirPath, file, os.O_RDONLY)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	ret := make(map[string]uint64)
+	sc := bufio.NewScanner(f)
+	for sc.Scan() {
+		parts := strings.Fields(sc.Text())
+		if len(parts) != 2 {
+			continue
+		}
+		var v uint64
+		if parts[1] == "max" {
+			v = math.MaxUint64
+		} else {
+			v, err = fscommon.ParseUint(parts[1], 10, 64)
+			if err != nil {
+				return nil, &parseError{Path: dirPath, File: file, Err: err}
+			}
+		}
+		ret[parts[0]] = v
+	}
+	if err := sc.Err(); err != nil {
+		return nil, &parseError{Path: dirPath, File: file, Err: err}
+	}
+	return ret, nil
+}
+
+func statMisc(dirPath string, stats *cgroups.Stats) error {
+	current, err := readMiscFile(dirPath, "misc.current")
+	if err != nil {
+		return err
+	}
+	limits, err := readMiscFile(dirPath, "misc.max")
+	if err != nil {
+		return err
+	}
+	for name, usage := range current {
+		s := cgroups.MiscStats{
+			Usage: usage,
+			Limit: limits[name],
+		}
+		// misc.events is available since kernel 5.15.
+		s.Events, err = fscommon.GetValueByKey(dirPath, "misc.events", name+".max")
+		if err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		stats.MiscStats[name] = s
+	}
+
+	return nil
+}
@@ -0,0 +1,60 @@
+package fs2
+
+import (
+	"math"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/opencontainers/runc/libcontainer/cgroups"
+	"github.com/opencontainers/runc/libcontainer/configs"
+)
+
+func TestSetMisc(t *testing.T) {
+	// We're using a fake cgroupfs.
+	cgroups.TestMode = true
+
+	fakeCgroupDir := t.TempDir()
+	r := &configs.Resources{
+		Misc: map[string]uint64{"sgx_epc": 4096},
+	}
+	if err := setMisc(fakeCgroupDir, r); err != nil {
+		t.Fatal(err)
+	}
+	got, err := os.ReadFile(filepath.Join(fakeCgroupDir, "misc.max"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(got) != "sgx_epc 4096" {
+		t.Fatalf("expected misc.max to be %q, got %q", "sgx_epc 4096", got)
+	}
+}
+
+func TestStatMisc(t *testing.T) {
+	// We're using a fake cgroupfs.
+	cgroups.TestMode = true
+
+	fakeCgroupDir := t.TempDir()
+	for file, data := range map[string]string{
+		"misc.current": "sgx_epc 8192\nsev 0\n",
+		"misc.max":     "sgx_epc 16384\nsev max\n",
+		"misc.events":  "sgx_epc.max 3\nsev.max 0\n",
+	} {
+		if err := os.WriteFile(filepath.Join(fakeCgroupDir, file), []byte(data), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	st := cgroups.NewStats()
+	if err := statMisc(fakeCgroupDir, st); err != nil {
+		t.Fatal(err)
+	}
+	expected := map[string]cgroups.MiscStats{
+		"sgx_epc": {Usage: 8192, Limit: 16384, Events: 3},
+		"sev":     {Usage: 0, Limit: math.MaxUint64, Events: 0},
+	}
+	if !reflect.DeepEqual(st.MiscStats, expected) {
+		t.Errorf("parsed misc stats don't match expected result: \ngot %#v\nexpected %#v\n", st.MiscStats, expected)
+	}
+}
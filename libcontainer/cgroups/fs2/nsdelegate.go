@@ -0,0 +1,40 @@
+package fs2
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"golang.org/x/sys/unix"
+)
+
+// isNsDelegate returns whether the cgroup2 hierarchy mounted at mountpoint
+// was mounted with the nsdelegate option. With nsdelegate, cgroup
+// namespaces are delegation boundaries: processes can't be migrated across
+// the root of the current cgroup namespace, and the namespace root's
+// cgroup.subtree_control can't be written from inside the namespace.
+func isNsDelegate(mountpoint string) bool {
+	mnt, err := getMount(mountpoint)
+	if err != nil || mnt == nil {
+		return false
+	}
+	for _, opt := range strings.Split(mnt.VFSOptions, ",") {
+		if opt == "nsdelegate" {
+			return true
+		}
+	}
+	return false
+}
+
+// nsDelegateError returns a more informative error if err was likely
+// caused by hitting a cgroup namespace delegation boundary while doing op
+// on path, and err unchanged otherwise.
+func nsDelegateError(op, path string, err error) error {
+	if !errors.Is(err, unix.EPERM) && !errors.Is(err, unix.ENOENT) {
+		return err
+	}
+	if !isNsDelegate(UnifiedMountpoint) {
+		return err
+	}
+	return fmt.Errorf("unable to %s %s: cgroup v2 is mounted with nsdelegate and the operation crosses a cgroup namespace delegation boundary: %w", op, path, err)
+}
@@ -66,7 +66,19 @@ func statPids(dirPath string, stats *cgroups.Stats) error {
 		max = 0
 	}
 
+	// pids.events is available since kernel 4.5, pids.peak since kernel 6.1.
+	failcnt, err := fscommon.GetValueByKey(dirPath, "pids.events", "max")
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	peak, err := fscommon.GetCgroupParamUint(dirPath, "pids.peak")
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+
 	stats.PidsStats.Current = current
 	stats.PidsStats.Limit = max
+	stats.PidsStats.Failcnt = failcnt
+	stats.PidsStats.Peak = peak
 	return nil
 }
@@ -0,0 +1,50 @@
+package fs2
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/opencontainers/runc/libcontainer/cgroups"
+)
+
+func TestStatPids(t *testing.T) {
+	// We're using a fake cgroupfs.
+	cgroups.TestMode = true
+
+	fakeCgroupDir := t.TempDir()
+	for file, data := range map[string]string{
+		"pids.current": "12\n",
+		"pids.max":     "max\n",
+		"pids.events":  "max 7\n",
+		"pids.peak":    "64\n",
+	} {
+		if err := os.WriteFile(filepath.Join(fakeCgroupDir, file), []byte(data), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	st := cgroups.NewStats()
+	if err := statPids(fakeCgroupDir, st); err != nil {
+		t.Fatal(err)
+	}
+	expected := cgroups.PidsStats{Current: 12, Limit: 0, Failcnt: 7, Peak: 64}
+	if st.PidsStats != expected {
+		t.Errorf("expected pids stats %+v, got %+v", expected, st.PidsStats)
+	}
+
+	// Older kernels have neither pids.events nor pids.peak.
+	for _, file := range []string{"pids.events", "pids.peak"} {
+		if err := os.Remove(filepath.Join(fakeCgroupDir, file)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	st = cgroups.NewStats()
+	if err := statPids(fakeCgroupDir, st); err != nil {
+		t.Fatal(err)
+	}
+	expected = cgroups.PidsStats{Current: 12, Limit: 0}
+	if st.PidsStats != expected {
+		t.Errorf("expected pids stats %+v, got %+v", expected, st.PidsStats)
+	}
+}
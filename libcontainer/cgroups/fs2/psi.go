@@ -0,0 +1,95 @@
+package fs2
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	"golang.org/x/sys/unix"
+
+	"github.com/opencontainers/runc/libcontainer/cgroups"
+)
+
+func statPSI(dirPath string, stats *cgroups.Stats) error {
+	var err error
+	if stats.CpuStats.PSI, err = readPSI(dirPath, "cpu.pressure"); err != nil {
+		return err
+	}
+	if stats.MemoryStats.PSI, err = readPSI(dirPath, "memory.pressure"); err != nil {
+		return err
+	}
+	if stats.BlkioStats.PSI, err = readPSI(dirPath, "io.pressure"); err != nil {
+		return err
+	}
+	return nil
+}
+
+// readPSI parses a <resource>.pressure file. It returns nil (and no error)
+// if PSI is not available.
+func readPSI(dirPath, file string) (*cgroups.PSIStats, error) {
+	f, err := cgroups.OpenFile(dirPath, file, os.O_RDONLY)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	defer f.Close()
+
+	var psi cgroups.PSIStats
+	sc := bufio.NewScanner(f)
+	for sc.Scan() {
+		parts := strings.Fields(sc.Text())
+		if len(parts) == 0 {
+			continue
+		}
+		var data *cgroups.PSIData
+		switch parts[0] {
+		case "some":
+			data = &psi.Some
+		case "full":
+			data = &psi.Full
+		default:
+			continue
+		}
+		if err := parsePSIData(parts[1:], data); err != nil {
+			return nil, &parseError{Path: dirPath, File: file, Err: err}
+		}
+	}
+	if err := sc.Err(); err != nil {
+		// With CONFIG_PSI_DEFAULT_DISABLED, the pressure files are
+		// present, but reading them fails until psi=1 is set.
+		if errors.Is(err, unix.ENOTSUP) {
+			return nil, nil
+		}
+		return nil, &parseError{Path: dirPath, File: file, Err: err}
+	}
+	return &psi, nil
+}
+
+func parsePSIData(fields []string, data *cgroups.PSIData) error {
+	for _, field := range fields {
+		kv := strings.SplitN(field, "=", 2)
+		if len(kv) != 2 {
+			return fmt.Errorf("invalid PSI data: %q", field)
+		}
+		var err error
+		switch kv[0] {
+		case "avg10":
+			data.Avg10, err = strconv.ParseFloat(kv[1], 64)
+		case "avg60":
+			data.Avg60, err = strconv.ParseFloat(kv[1], 64)
+		case "avg300":
+			data.Avg300, err = strconv.ParseFloat(kv[1], 64)
+		case "total":
+			data.Total, err = strconv.ParseUint(kv[1], 10, 64)
+		}
+		if err != nil {
+			return fmt.Errorf("invalid %s PSI value: %w", kv[0], err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,60 @@
+package fs2
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/opencontainers/runc/libcontainer/cgroups"
+)
+
+func TestStatPSI(t *testing.T) {
+	// We're using a fake cgroupfs.
+	cgroups.TestMode = true
+
+	fakeCgroupDir := t.TempDir()
+	for file, data := range map[string]string{
+		"cpu.pressure":    "some avg10=1.50 avg60=0.25 avg300=0.00 total=12345\n",
+		"memory.pressure": "some avg10=0.00 avg60=0.00 avg300=0.00 total=10\nfull avg10=0.00 avg60=0.00 avg300=0.00 total=5\n",
+	} {
+		if err := os.WriteFile(filepath.Join(fakeCgroupDir, file), []byte(data), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	st := cgroups.NewStats()
+	if err := statPSI(fakeCgroupDir, st); err != nil {
+		t.Fatal(err)
+	}
+	expectedCPU := &cgroups.PSIStats{
+		Some: cgroups.PSIData{Avg10: 1.5, Avg60: 0.25, Total: 12345},
+	}
+	if !reflect.DeepEqual(st.CpuStats.PSI, expectedCPU) {
+		t.Errorf("expected cpu PSI %+v, got %+v", expectedCPU, st.CpuStats.PSI)
+	}
+	expectedMemory := &cgroups.PSIStats{
+		Some: cgroups.PSIData{Total: 10},
+		Full: cgroups.PSIData{Total: 5},
+	}
+	if !reflect.DeepEqual(st.MemoryStats.PSI, expectedMemory) {
+		t.Errorf("expected memory PSI %+v, got %+v", expectedMemory, st.MemoryStats.PSI)
+	}
+	// No io.pressure file means no PSI data.
+	if st.BlkioStats.PSI != nil {
+		t.Errorf("expected no io PSI, got %+v", st.BlkioStats.PSI)
+	}
+}
+
+func TestStatPSIInvalid(t *testing.T) {
+	// We're using a fake cgroupfs.
+	cgroups.TestMode = true
+
+	fakeCgroupDir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(fakeCgroupDir, "cpu.pressure"), []byte("some avg10=x total=1\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := statPSI(fakeCgroupDir, cgroups.NewStats()); err == nil {
+		t.Fatal("expected error, got nil")
+	}
+}
@@ -0,0 +1,70 @@
+package manager
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/opencontainers/runc/libcontainer/cgroups"
+	"github.com/opencontainers/runc/libcontainer/cgroups/systemd"
+)
+
+// Driver is a cgroup driver, i.e. a way to manage container cgroups.
+type Driver string
+
+const (
+	// DriverCgroupfs means cgroups are managed by writing to cgroupfs.
+	DriverCgroupfs Driver = "cgroupfs"
+	// DriverSystemd means cgroups are managed by systemd, which creates
+	// a transient unit for every container.
+	DriverSystemd Driver = "systemd"
+	// DriverAuto means the driver is chosen based on the host
+	// configuration, see DetectDriver.
+	DriverAuto Driver = "auto"
+)
+
+// ParseDriver parses a cgroup driver name.
+func ParseDriver(name string) (Driver, error) {
+	switch d := Driver(name); d {
+	case DriverCgroupfs, DriverSystemd, DriverAuto:
+		return d, nil
+	}
+	return "", fmt.Errorf("invalid cgroup driver %q (expected %q, %q, or %q)", name, DriverCgroupfs, DriverSystemd, DriverAuto)
+}
+
+// DetectDriver returns the cgroup driver most suitable for this host.
+//
+// The systemd driver is used if the host is booted with systemd. For
+// rootless containers, it also requires cgroup v2 (as systemd does not
+// delegate cgroup v1 hierarchies to unprivileged users), and the caller
+// to be running in the cgroup subtree delegated to its own systemd user
+// instance. Otherwise, the cgroupfs driver is used.
+//
+// Whether cgroup v1 or v2 is used is decided by the mounted hierarchy,
+// regardless of the driver, see New.
+func DetectDriver(rootless bool) Driver {
+	if !systemd.IsRunningSystemd() {
+		return DriverCgroupfs
+	}
+	if !rootless {
+		return DriverSystemd
+	}
+	if !cgroups.IsCgroup2UnifiedMode() {
+		return DriverCgroupfs
+	}
+	paths, err := cgroups.ParseCgroupFile("/proc/self/cgroup")
+	if err != nil {
+		return DriverCgroupfs
+	}
+	if isUserManagerDelegated(paths[""], os.Geteuid()) {
+		return DriverSystemd
+	}
+	return DriverCgroupfs
+}
+
+// isUserManagerDelegated checks whether path is within the cgroup
+// subtree which systemd delegates to the user instance of uid.
+func isUserManagerDelegated(path string, uid int) bool {
+	svc := fmt.Sprintf("/user@%d.service", uid)
+	return strings.HasSuffix(path, svc) || strings.Contains(path, svc+"/")
+}
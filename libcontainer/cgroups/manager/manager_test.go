@@ -42,3 +42,39 @@ func TestNilResources(t *testing.T) {
 		_ = mgr.Destroy()
 	}
 }
+
+func TestParseDriver(t *testing.T) {
+	for _, name := range []string{"cgroupfs", "systemd", "auto"} {
+		d, err := ParseDriver(name)
+		if err != nil {
+			t.Errorf("%s: unexpected error: %v", name, err)
+		}
+		if string(d) != name {
+			t.Errorf("expected %q, got %q", name, d)
+		}
+	}
+	for _, name := range []string{"", "fs", "Systemd"} {
+		if _, err := ParseDriver(name); err == nil {
+			t.Errorf("%q: expected error, got nil", name)
+		}
+	}
+}
+
+func TestIsUserManagerDelegated(t *testing.T) {
+	testCases := []struct {
+		path string
+		uid  int
+		ok   bool
+	}{
+		{"/user.slice/user-1000.slice/user@1000.service", 1000, true},
+		{"/user.slice/user-1000.slice/user@1000.service/app.slice/foo.scope", 1000, true},
+		{"/user.slice/user-1000.slice/user@1000.service/app.slice/foo.scope", 100, false},
+		{"/user.slice/user-1000.slice/session-3.scope", 1000, false},
+		{"/", 1000, false},
+	}
+	for _, tc := range testCases {
+		if got := isUserManagerDelegated(tc.path, tc.uid); got != tc.ok {
+			t.Errorf("isUserManagerDelegated(%q, %d): expected %v, got %v", tc.path, tc.uid, tc.ok, got)
+		}
+	}
+}
@@ -7,6 +7,10 @@ type ThrottlingData struct {
 	ThrottledPeriods uint64 `json:"throttled_periods,omitempty"`
 	// Aggregate time the container was throttled for in nanoseconds.
 	ThrottledTime uint64 `json:"throttled_time,omitempty"`
+	// Number of periods in which the container used its burst allowance.
+	BurstPeriods uint64 `json:"burst_periods,omitempty"`
+	// Aggregate time the container ran using its burst allowance in nanoseconds.
+	BurstTime uint64 `json:"burst_time,omitempty"`
 }
 
 // CpuUsage denotes the usage of a CPU.
@@ -32,9 +36,26 @@ type CpuUsage struct {
 	UsageInUsermode uint64 `json:"usage_in_usermode"`
 }
 
+type PSIData struct {
+	Avg10  float64 `json:"avg10"`
+	Avg60  float64 `json:"avg60"`
+	Avg300 float64 `json:"avg300"`
+	// Units: microseconds.
+	Total uint64 `json:"total"`
+}
+
+// PSIStats is the pressure stall information (cgroup v2 only).
+type PSIStats struct {
+	Some PSIData `json:"some,omitempty"`
+	// Full is not reported by the kernel for the cpu resource
+	// before kernel 5.13.
+	Full PSIData `json:"full,omitempty"`
+}
+
 type CpuStats struct {
 	CpuUsage       CpuUsage       `json:"cpu_usage,omitempty"`
 	ThrottlingData ThrottlingData `json:"throttling_data,omitempty"`
+	PSI            *PSIStats      `json:"psi,omitempty"`
 }
 
 type CPUSetStats struct {
@@ -87,6 +108,11 @@ type MemoryStats struct {
 	PageUsageByNUMA PageUsageByNUMA `json:"page_usage_by_numa,omitempty"`
 	// if true, memory usage is accounted for throughout a hierarchy of cgroups.
 	UseHierarchy bool `json:"use_hierarchy"`
+	// number of processes killed by the OOM killer in this cgroup.
+	OOMKillCount uint64 `json:"oom_kill_count,omitempty"`
+	// memory.events counters, such as "high" or "oom_kill" (cgroup v2 only).
+	Events map[string]uint64 `json:"events,omitempty"`
+	PSI    *PSIStats         `json:"psi,omitempty"`
 
 	Stats map[string]uint64 `json:"stats,omitempty"`
 }
@@ -114,6 +140,19 @@ type PidsStats struct {
 	Current uint64 `json:"current,omitempty"`
 	// active pids hard limit
 	Limit uint64 `json:"limit,omitempty"`
+	// number of times fork failed because the limit was hit
+	Failcnt uint64 `json:"failcnt,omitempty"`
+	// maximum number of pids ever recorded in the cgroup
+	Peak uint64 `json:"peak,omitempty"`
+}
+
+// CgroupStats are the counters from cgroup v2 cgroup.stat.
+type CgroupStats struct {
+	// number of visible descendant cgroups
+	NrDescendants uint64 `json:"nr_descendants,omitempty"`
+	// number of dying descendant cgroups, i.e. the ones which were
+	// removed but are still pinned by lingering kernel objects
+	NrDyingDescendants uint64 `json:"nr_dying_descendants,omitempty"`
 }
 
 type BlkioStatEntry struct {
@@ -133,6 +172,12 @@ type BlkioStats struct {
 	IoMergedRecursive       []BlkioStatEntry `json:"io_merged_recursive,omitempty"`
 	IoTimeRecursive         []BlkioStatEntry `json:"io_time_recursive,omitempty"`
 	SectorsRecursive        []BlkioStatEntry `json:"sectors_recursive,omitempty"`
+	// number of bytes and I/O operations, per device and operation, as
+	// accounted by the throttling policy (cgroup v1 only; recursive if
+	// supported by the kernel)
+	ThrottleIoServiceBytes []BlkioStatEntry `json:"throttle_io_service_bytes,omitempty"`
+	ThrottleIoServiced     []BlkioStatEntry `json:"throttle_io_serviced,omitempty"`
+	PSI                    *PSIStats        `json:"psi,omitempty"`
 }
 
 type HugetlbStats struct {
@@ -155,6 +200,15 @@ type RdmaStats struct {
 	RdmaCurrent []RdmaEntry `json:"rdma_current,omitempty"`
 }
 
+type MiscStats struct {
+	// current resource usage for a key in misc
+	Usage uint64 `json:"usage,omitempty"`
+	// maximum usage allowed for a key in misc
+	Limit uint64 `json:"limit,omitempty"`
+	// number of times the usage tried to go over the limit
+	Events uint64 `json:"events,omitempty"`
+}
+
 type Stats struct {
 	CpuStats    CpuStats    `json:"cpu_stats,omitempty"`
 	CPUSetStats CPUSetStats `json:"cpuset_stats,omitempty"`
@@ -164,10 +218,15 @@ type Stats struct {
 	// the map is in the format "size of hugepage: stats of the hugepage"
 	HugetlbStats map[string]HugetlbStats `json:"hugetlb_stats,omitempty"`
 	RdmaStats    RdmaStats               `json:"rdma_stats,omitempty"`
+	// the map is in the format "misc resource name: stats of the key"
+	MiscStats map[string]MiscStats `json:"misc_stats,omitempty"`
+	// cgroup v2 only
+	CgroupStats CgroupStats `json:"cgroup_stats,omitempty"`
 }
 
 func NewStats() *Stats {
 	memoryStats := MemoryStats{Stats: make(map[string]uint64)}
 	hugetlbStats := make(map[string]HugetlbStats)
-	return &Stats{MemoryStats: memoryStats, HugetlbStats: hugetlbStats}
+	miscStats := make(map[string]MiscStats)
+	return &Stats{MemoryStats: memoryStats, HugetlbStats: hugetlbStats, MiscStats: miscStats}
 }
@@ -7,6 +7,7 @@ import (
 	"fmt"
 	"math"
 	"os"
+	"reflect"
 	"regexp"
 	"strconv"
 	"strings"
@@ -35,8 +36,30 @@ var (
 
 	isRunningSystemdOnce sync.Once
 	isRunningSystemd     bool
+
+	// unsupportedWarned records the features for which a warning
+	// about an old systemd version has already been logged.
+	unsupportedWarned sync.Map
 )
 
+// minSystemdVersion lists the minimum systemd version required for
+// unit properties (and methods) which are not supported by all the
+// systemd versions we work with.
+var minSystemdVersion = map[string]int{
+	"CPUQuotaPeriodUSec":    242,
+	"AllowedCPUs":           244,
+	"AllowedMemoryNodes":    244,
+	"DelegateControllers":   236,
+	"AttachProcessesToUnit": 238,
+	"FreezeUnit":            246,
+
+	"IODeviceLatencyTargetUSec": 240,
+
+	"ManagedOOMSwap":                247,
+	"ManagedOOMMemoryPressure":      247,
+	"ManagedOOMMemoryPressureLimit": 248,
+}
+
 // NOTE: This function comes from package github.com/coreos/go-systemd/util
 // It was borrowed here to avoid a dependency on cgo.
 //
@@ -52,6 +75,36 @@ func IsRunningSystemd() bool {
 	return isRunningSystemd
 }
 
+// getParentSlice returns the name of the slice to put the unit into.
+func getParentSlice(c *configs.Cgroup) string {
+	switch {
+	case c.Parent != "":
+		return c.Parent
+	case c.Rootless:
+		return "user.slice"
+	}
+	return "system.slice"
+}
+
+// EscapeSliceComponent escapes s so that it can be used as a single
+// component of a (possibly nested) slice name. Like systemd-escape(1),
+// it replaces all characters other than ASCII alphanumerics, ':', '_'
+// and '.' (and a leading '.') with C-style \xNN escapes; in particular,
+// '-' is escaped since it separates slice components.
+func EscapeSliceComponent(s string) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') || (c >= '0' && c <= '9') ||
+			c == ':' || c == '_' || (c == '.' && i > 0) {
+			b.WriteByte(c)
+			continue
+		}
+		fmt.Fprintf(&b, "\\x%02x", c)
+	}
+	return b.String()
+}
+
 // systemd represents slice hierarchy using `-`, so we need to follow suit when
 // generating the path of slice. Essentially, test-a-b.slice becomes
 // /test.slice/test-a.slice/test-a-b.slice.
@@ -303,6 +356,104 @@ func newProp(name string, units interface{}) systemdDbus.Property {
 	}
 }
 
+// extraProperties converts the additional systemd properties from the
+// config to dbus properties. If runtimeOnly is set, only the properties
+// which can be changed for a running unit are returned.
+func extraProperties(c *configs.Cgroup, runtimeOnly bool) []systemdDbus.Property {
+	var props []systemdDbus.Property
+	for _, p := range c.SystemdProps {
+		if runtimeOnly && !p.Runtime {
+			continue
+		}
+		props = append(props, systemdDbus.Property{Name: p.Name, Value: p.Value})
+	}
+	return props
+}
+
+// genUnitProperties generates the properties for a new container unit,
+// other than the resource ones. ioAccounting is the name of the property
+// to enable IO accounting, which differs between cgroup v1 and v2.
+func genUnitProperties(cm *dbusConnManager, c *configs.Cgroup, unitName, slice string, pid int, ioAccounting string) []systemdDbus.Property {
+	properties := []systemdDbus.Property{systemdDbus.PropDescription("libcontainer container " + c.Name)}
+
+	if strings.HasSuffix(unitName, ".slice") {
+		// If we create a slice, the parent is defined via a Wants=.
+		properties = append(properties, systemdDbus.PropWants(slice))
+	} else {
+		// Otherwise it's a scope, which we put into a Slice=.
+		properties = append(properties, systemdDbus.PropSlice(slice))
+		addDelegate(cm, &properties, c.DelegateControllers)
+	}
+
+	// only add pid if its valid, -1 is used w/ general slice creation.
+	if pid != -1 {
+		properties = append(properties, newProp("PIDs", []uint32{uint32(pid)}))
+	}
+
+	// Always enable accounting, this gets us the same behaviour as the fs implementation,
+	// plus the kernel has some problems with joining the memory cgroup at a later time.
+	properties = append(properties,
+		newProp("MemoryAccounting", true),
+		newProp("CPUAccounting", true),
+		newProp(ioAccounting, true),
+		newProp("TasksAccounting", true),
+	)
+
+	// Assume DefaultDependencies= will always work (the check for it was previously broken.)
+	properties = append(properties,
+		newProp("DefaultDependencies", false))
+
+	return properties
+}
+
+// genCommonResourcesProperties generates the unit properties for the
+// resources which are handled the same way for cgroup v1 and v2.
+func genCommonResourcesProperties(r *configs.Resources, cm *dbusConnManager) ([]systemdDbus.Property, error) {
+	properties, err := generateDeviceProperties(r)
+	if err != nil {
+		return nil, err
+	}
+
+	addCpuQuota(cm, &properties, r.CpuQuota, r.CpuPeriod)
+
+	if r.PidsLimit > 0 || r.PidsLimit == -1 {
+		properties = append(properties,
+			newProp("TasksMax", uint64(r.PidsLimit)))
+	}
+
+	err = addCpuset(cm, &properties, r.CpusetCpus, r.CpusetMems)
+	if err != nil {
+		return nil, err
+	}
+
+	return properties, nil
+}
+
+// addDelegate adds the properties to delegate the cgroup subtree of a scope.
+func addDelegate(cm *dbusConnManager, props *[]systemdDbus.Property, controllers []string) {
+	// Assume scopes always support delegation (supported since systemd v218).
+	*props = append(*props, newProp("Delegate", true))
+	if len(controllers) > 0 && systemdSupports(cm, "DelegateControllers") {
+		*props = append(*props, newProp("DelegateControllers", controllers))
+	}
+}
+
+// addUnitStop adds the properties describing how to stop the unit.
+func addUnitStop(props *[]systemdDbus.Property, stop *configs.UnitStop) {
+	if stop == nil {
+		return
+	}
+	if stop.TimeoutUSec != 0 {
+		*props = append(*props, newProp("TimeoutStopUSec", stop.TimeoutUSec))
+	}
+	if stop.KillMode != "" {
+		*props = append(*props, newProp("KillMode", stop.KillMode))
+	}
+	if stop.SendSIGKILL != nil {
+		*props = append(*props, newProp("SendSIGKILL", *stop.SendSIGKILL))
+	}
+}
+
 func getUnitName(c *configs.Cgroup) string {
 	// by default, we create a scope unless the user explicitly asks for a slice.
 	if !strings.HasSuffix(c.Name, ".slice") {
@@ -335,32 +486,92 @@ func isUnitExists(err error) bool {
 	return isDbusError(err, "org.freedesktop.systemd1.UnitExists")
 }
 
-func startUnit(cm *dbusConnManager, unitName string, properties []systemdDbus.Property) error {
+// startUnit starts a transient unit. Unless async is set, it waits for
+// the start job to complete. Otherwise, the returned channel receives the
+// job result (nil on success) once the job completes.
+func startUnit(cm *dbusConnManager, unitName string, properties []systemdDbus.Property, async bool) (<-chan error, error) {
 	statusChan := make(chan string, 1)
-	err := cm.retryOnDisconnect(func(c *systemdDbus.Conn) error {
-		_, err := c.StartTransientUnitContext(context.TODO(), unitName, "replace", properties, statusChan)
-		return err
-	})
-	if err == nil {
-		timeout := time.NewTimer(30 * time.Second)
-		defer timeout.Stop()
+	for i := 0; ; i++ {
+		err := cm.retryOnDisconnect(func(c *systemdDbus.Conn) error {
+			_, err := c.StartTransientUnitContext(context.TODO(), unitName, "replace", properties, statusChan)
+			return err
+		})
+		if err == nil {
+			break
+		}
+		if !isUnitExists(err) {
+			return nil, err
+		}
+		// The unit exists. Unless it is a failed leftover (e.g. from
+		// a container which crashed), there is nothing to do.
+		if i > 0 || !isUnitFailed(cm, unitName) {
+			return nil, nil
+		}
+		logrus.Debugf("unit %s exists in failed state, resetting", unitName)
+		resetFailedUnit(cm, unitName)
+	}
 
-		select {
-		case s := <-statusChan:
-			close(statusChan)
-			// Please refer to https://pkg.go.dev/github.com/coreos/go-systemd/v22/dbus#Conn.StartUnit
-			if s != "done" {
-				resetFailedUnit(cm, unitName)
-				return fmt.Errorf("error creating systemd unit `%s`: got `%s`", unitName, s)
-			}
-		case <-timeout.C:
+	if async {
+		job := make(chan error, 1)
+		go func() {
+			job <- waitUnitJob(cm, unitName, statusChan)
+		}()
+		return job, nil
+	}
+	return nil, waitUnitJob(cm, unitName, statusChan)
+}
+
+// waitUnitJob waits for the unit start job result from statusChan.
+func waitUnitJob(cm *dbusConnManager, unitName string, statusChan chan string) error {
+	timeout := time.NewTimer(30 * time.Second)
+	defer timeout.Stop()
+
+	select {
+	case s := <-statusChan:
+		close(statusChan)
+		// Please refer to https://pkg.go.dev/github.com/coreos/go-systemd/v22/dbus#Conn.StartUnit
+		if s != "done" {
 			resetFailedUnit(cm, unitName)
-			return errors.New("Timeout waiting for systemd to create " + unitName)
+			return fmt.Errorf("error creating systemd unit `%s`: got `%s`", unitName, s)
 		}
-	} else if !isUnitExists(err) {
-		return err
+	case <-timeout.C:
+		resetFailedUnit(cm, unitName)
+		return errors.New("Timeout waiting for systemd to create " + unitName)
+	}
+
+	return nil
+}
+
+// isUnitFailed checks whether the unit is in failed state.
+func isUnitFailed(cm *dbusConnManager, unitName string) bool {
+	var prop *systemdDbus.Property
+	err := cm.retryOnDisconnect(func(c *systemdDbus.Conn) (Err error) {
+		prop, Err = c.GetUnitPropertyContext(context.TODO(), unitName, "ActiveState")
+		return Err
+	})
+	if err != nil {
+		logrus.Debugf("unable to get unit %s state: %v", unitName, err)
+		return false
 	}
+	state, _ := prop.Value.Value().(string)
+	return state == "failed"
+}
 
+// attachToUnit moves the process pid into an existing unit, which
+// was created (and is managed) by someone else.
+func attachToUnit(cm *dbusConnManager, unitName string, pid int) error {
+	const method = "AttachProcessesToUnit"
+	if sdVer := systemdVersion(cm); sdVer < minSystemdVersion[method] {
+		return fmt.Errorf("unable to join existing unit %q: systemd v%d does not support %s (requires v%d or later)",
+			unitName, sdVer, method, minSystemdVersion[method])
+	}
+	// -1 is used w/ general slice creation, there is nothing to attach.
+	if pid == -1 {
+		return nil
+	}
+	if err := cm.callManager(method, unitName, "", []uint32{uint32(pid)}); err != nil {
+		return fmt.Errorf("unable to attach pid %d to existing unit %q: %w", pid, unitName, err)
+	}
 	return nil
 }
 
@@ -406,6 +617,124 @@ func getUnitTypeProperty(cm *dbusConnManager, unitName string, unitType string,
 	return prop, err
 }
 
+func getUnitTypeProperties(cm *dbusConnManager, unitName string, unitType string) (map[string]interface{}, error) {
+	var props map[string]interface{}
+	err := cm.retryOnDisconnect(func(c *systemdDbus.Conn) (Err error) {
+		props, Err = c.GetUnitTypePropertiesContext(context.TODO(), unitName, unitType)
+		return Err
+	})
+	return props, err
+}
+
+// resourcePropDefaults are the default values of the unit properties which
+// are generated from the container resources. If such a property was set
+// by runc earlier, but is no longer generated on update, it is reset to its
+// default value.
+var resourcePropDefaults = map[string]interface{}{
+	"MemoryLimit":               uint64(math.MaxUint64),
+	"MemoryMax":                 uint64(math.MaxUint64),
+	"MemoryHigh":                uint64(math.MaxUint64),
+	"MemorySwapMax":             uint64(math.MaxUint64),
+	"MemoryLow":                 uint64(0),
+	"MemoryMin":                 uint64(0),
+	"CPUShares":                 uint64(math.MaxUint64),
+	"CPUWeight":                 uint64(math.MaxUint64),
+	"CPUQuotaPerSecUSec":        uint64(math.MaxUint64),
+	"CPUQuotaPeriodUSec":        uint64(math.MaxUint64),
+	"AllowedCPUs":               []byte{},
+	"AllowedMemoryNodes":        []byte{},
+	"TasksMax":                  uint64(math.MaxUint64),
+	"BlockIOWeight":             uint64(math.MaxUint64),
+	"BlockIODeviceWeight":       []ioDeviceWeight{},
+	"BlockIOReadBandwidth":      []ioDeviceLimit{},
+	"BlockIOWriteBandwidth":     []ioDeviceLimit{},
+	"IOWeight":                  uint64(math.MaxUint64),
+	"IODeviceWeight":            []ioDeviceWeight{},
+	"IOReadBandwidthMax":        []ioDeviceLimit{},
+	"IOWriteBandwidthMax":       []ioDeviceLimit{},
+	"IOReadIOPSMax":             []ioDeviceLimit{},
+	"IOWriteIOPSMax":            []ioDeviceLimit{},
+	"IODeviceLatencyTargetUSec": []ioDeviceLimit{},
+}
+
+// changedUnitProperties returns the properties which need to be set for
+// the unit to have props, given its current property values. Unchanged
+// properties are omitted, and resource properties which were set earlier
+// (i.e. are in prev) but are neither in props nor in keep are reset to
+// their defaults. Properties not set by runc are never reset.
+func changedUnitProperties(current map[string]interface{}, props, prev, keep []systemdDbus.Property) []systemdDbus.Property {
+	var changed []systemdDbus.Property
+	wanted := make(map[string]bool, len(props)+len(keep))
+	for _, p := range keep {
+		wanted[p.Name] = true
+	}
+	for _, p := range props {
+		wanted[p.Name] = true
+		if cur, ok := current[p.Name]; ok && dbusValueEqual(cur, p.Value.Value()) {
+			continue
+		}
+		changed = append(changed, p)
+	}
+	for _, p := range prev {
+		def, ok := resourcePropDefaults[p.Name]
+		if !ok || wanted[p.Name] {
+			continue
+		}
+		wanted[p.Name] = true // Only reset once.
+		if cur, ok := current[p.Name]; ok && !dbusValueEqual(cur, def) {
+			changed = append(changed, newProp(p.Name, def))
+		}
+	}
+	return changed
+}
+
+// dbusValueEqual compares two dbus values, which may be represented by
+// different Go types (for example, a dbus struct can be either a Go
+// struct or an []interface{} of its fields).
+func dbusValueEqual(a, b interface{}) bool {
+	return reflect.DeepEqual(normalizeDbusValue(reflect.ValueOf(a)), normalizeDbusValue(reflect.ValueOf(b)))
+}
+
+func normalizeDbusValue(v reflect.Value) interface{} {
+	switch v.Kind() {
+	case reflect.Interface:
+		return normalizeDbusValue(v.Elem())
+	case reflect.Struct:
+		var fields []interface{}
+		for i := 0; i < v.NumField(); i++ {
+			if v.Type().Field(i).PkgPath == "" { // exported
+				fields = append(fields, normalizeDbusValue(v.Field(i)))
+			}
+		}
+		return fields
+	case reflect.Slice, reflect.Array:
+		elems := make([]interface{}, v.Len())
+		for i := range elems {
+			elems[i] = normalizeDbusValue(v.Index(i))
+		}
+		return elems
+	case reflect.Invalid:
+		return nil
+	}
+	return v.Interface()
+}
+
+// updateUnitProperties sets the unit properties, skipping the ones which
+// are already set, and resetting the resource properties previously set
+// (prev) which are no longer wanted. Properties in keep are left alone.
+// If the current unit properties can't be obtained, all props are set.
+func updateUnitProperties(cm *dbusConnManager, name string, props, prev, keep []systemdDbus.Property) error {
+	if current, err := getUnitTypeProperties(cm, name, getUnitType(name)); err != nil {
+		logrus.Debugf("unable to get unit %q properties, setting all: %v", name, err)
+	} else {
+		props = changedUnitProperties(current, props, prev, keep)
+	}
+	if len(props) == 0 {
+		return nil
+	}
+	return setUnitProperties(cm, name, props...)
+}
+
 func setUnitProperties(cm *dbusConnManager, name string, properties ...systemdDbus.Property) error {
 	return cm.retryOnDisconnect(func(c *systemdDbus.Conn) error {
 		return c.SetUnitPropertiesContext(context.TODO(), name, true, properties...)
@@ -441,6 +770,32 @@ func systemdVersion(cm *dbusConnManager) int {
 	return version
 }
 
+// systemdSupports reports whether the running systemd supports the named
+// property or method (one of the keys of minSystemdVersion). If it does
+// not, a warning is logged (once per feature).
+func systemdSupports(cm *dbusConnManager, feature string) bool {
+	return versionSupports(systemdVersion(cm), feature)
+}
+
+func versionSupports(sdVer int, feature string) bool {
+	minVer, ok := minSystemdVersion[feature]
+	if !ok || sdVer >= minVer {
+		return true
+	}
+	if _, warned := unsupportedWarned.LoadOrStore(feature, true); !warned {
+		if sdVer < 0 {
+			logrus.Warnf("unable to determine systemd version, not using %s "+
+				"(requires systemd v%d or later); the setting will only be applied to cgroupfs",
+				feature, minVer)
+		} else {
+			logrus.Warnf("systemd v%d is too old to support %s (requires v%d or later); "+
+				"the setting will only be applied to cgroupfs, upgrade systemd to have it managed by the unit",
+				sdVer, feature, minVer)
+		}
+	}
+	return false
+}
+
 func systemdVersionAtoi(verStr string) (int, error) {
 	// verStr should be of the form:
 	// "v245.4-1.fc32", "245", "v245-1.fc32", "245-1.fc32" (without quotes).
@@ -461,14 +816,9 @@ func systemdVersionAtoi(verStr string) (int, error) {
 
 func addCpuQuota(cm *dbusConnManager, properties *[]systemdDbus.Property, quota int64, period uint64) {
 	if period != 0 {
-		// systemd only supports CPUQuotaPeriodUSec since v242
-		sdVer := systemdVersion(cm)
-		if sdVer >= 242 {
+		if systemdSupports(cm, "CPUQuotaPeriodUSec") {
 			*properties = append(*properties,
 				newProp("CPUQuotaPeriodUSec", period))
-		} else {
-			logrus.Debugf("systemd v%d is too old to support CPUQuotaPeriodSec "+
-				" (setting will still be applied to cgroupfs)", sdVer)
 		}
 	}
 	if quota != 0 || period != 0 {
@@ -494,19 +844,7 @@ func addCpuQuota(cm *dbusConnManager, properties *[]systemdDbus.Property, quota
 }
 
 func addCpuset(cm *dbusConnManager, props *[]systemdDbus.Property, cpus, mems string) error {
-	if cpus == "" && mems == "" {
-		return nil
-	}
-
-	// systemd only supports AllowedCPUs/AllowedMemoryNodes since v244
-	sdVer := systemdVersion(cm)
-	if sdVer < 244 {
-		logrus.Debugf("systemd v%d is too old to support AllowedCPUs/AllowedMemoryNodes"+
-			" (settings will still be applied to cgroupfs)", sdVer)
-		return nil
-	}
-
-	if cpus != "" {
+	if cpus != "" && systemdSupports(cm, "AllowedCPUs") {
 		bits, err := RangeToBits(cpus)
 		if err != nil {
 			return fmt.Errorf("resources.CPU.Cpus=%q conversion error: %w",
@@ -515,7 +853,7 @@ func addCpuset(cm *dbusConnManager, props *[]systemdDbus.Property, cpus, mems st
 		*props = append(*props,
 			newProp("AllowedCPUs", bits))
 	}
-	if mems != "" {
+	if mems != "" && systemdSupports(cm, "AllowedMemoryNodes") {
 		bits, err := RangeToBits(mems)
 		if err != nil {
 			return fmt.Errorf("resources.CPU.Mems=%q conversion error: %w",
@@ -2,15 +2,21 @@ package systemd
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"os"
+	"strconv"
 	"sync"
+	"time"
 
 	systemdDbus "github.com/coreos/go-systemd/v22/dbus"
 	dbus "github.com/godbus/dbus/v5"
+	"golang.org/x/sys/unix"
 )
 
 var (
 	dbusC        *systemdDbus.Conn
+	dbusRawC     *dbus.Conn
 	dbusMu       sync.RWMutex
 	dbusInited   bool
 	dbusRootless bool
@@ -51,7 +57,7 @@ func (d *dbusConnManager) getConnection() (*systemdDbus.Conn, error) {
 		return conn, nil
 	}
 
-	conn, err := d.newConnection()
+	conn, raw, err := d.newConnection()
 	if err != nil {
 		// When dbus-user-session is not installed, we can't detect whether we should try to connect to user dbus or system dbus, so d.dbusRootless is set to false.
 		// This may fail with a cryptic error "read unix @->/run/systemd/private: read: connection reset by peer: unknown."
@@ -59,14 +65,78 @@ func (d *dbusConnManager) getConnection() (*systemdDbus.Conn, error) {
 		return nil, fmt.Errorf("failed to connect to dbus (hint: for rootless containers, maybe you need to install dbus-user-session package, see https://github.com/opencontainers/runc/blob/master/docs/cgroup-v2.md): %w", err)
 	}
 	dbusC = conn
+	dbusRawC = raw
 	return conn, nil
 }
 
-func (d *dbusConnManager) newConnection() (*systemdDbus.Conn, error) {
+// newConnection establishes a new systemd dbus connection. In addition to
+// the go-systemd connection, it returns one of its underlying dbus
+// connections, which can be used to call systemd methods not (yet)
+// provided by go-systemd.
+func (d *dbusConnManager) newConnection() (*systemdDbus.Conn, *dbus.Conn, error) {
+	dial, err := d.dialer()
+	if err != nil {
+		return nil, nil, err
+	}
+	return connectSystemd(dial)
+}
+
+// dialer returns a function which creates new dbus connections to systemd.
+func (d *dbusConnManager) dialer() (func() (*dbus.Conn, error), error) {
 	if dbusRootless {
-		return newUserSystemdDbus()
+		return userSystemdDbusDialer()
+	}
+	return func() (*dbus.Conn, error) {
+		// Same as systemdDbus.NewWithContext: try the system bus first,
+		// and fall back to the private systemd socket if running as root.
+		conn, err := dbusAuthConnection(dbus.SystemBusPrivate, true)
+		if err != nil && os.Geteuid() == 0 {
+			return dbusAuthConnection(func(opts ...dbus.ConnOption) (*dbus.Conn, error) {
+				return dbus.Dial("unix:path=/run/systemd/private", opts...)
+			}, false)
+		}
+		return conn, err
+	}, nil
+}
+
+// connectSystemd creates a go-systemd connection using dial, and returns
+// it together with one of the underlying dbus connections.
+func connectSystemd(dial func() (*dbus.Conn, error)) (*systemdDbus.Conn, *dbus.Conn, error) {
+	var raw *dbus.Conn
+	conn, err := systemdDbus.NewConnection(func() (*dbus.Conn, error) {
+		c, err := dial()
+		if err == nil && raw == nil {
+			raw = c
+		}
+		return c, err
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	return conn, raw, nil
+}
+
+// dbusAuthConnection creates a bus connection using createBus, and
+// authenticates using the EXTERNAL method. If hello is set, it also sends
+// the Hello message (not needed when talking to systemd directly).
+func dbusAuthConnection(createBus func(opts ...dbus.ConnOption) (*dbus.Conn, error), hello bool) (*dbus.Conn, error) {
+	conn, err := createBus(dbus.WithContext(context.TODO()))
+	if err != nil {
+		return nil, err
 	}
-	return systemdDbus.NewWithContext(context.TODO())
+	// Hardcode the uid (not username) to avoid a username lookup.
+	methods := []dbus.Auth{dbus.AuthExternal(strconv.Itoa(os.Getuid()))}
+	if err := conn.Auth(methods); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	if hello {
+		if err := conn.Hello(); err != nil {
+			conn.Close()
+			return nil, err
+		}
+	}
+	return conn, nil
 }
 
 // resetConnection resets the connection to its initial state
@@ -77,24 +147,101 @@ func (d *dbusConnManager) resetConnection(conn *systemdDbus.Conn) {
 	if dbusC != nil && dbusC == conn {
 		dbusC.Close()
 		dbusC = nil
+		dbusRawC = nil
 	}
 }
 
+// callManager calls a method of the systemd manager dbus interface which
+// is not provided by go-systemd.
+func (d *dbusConnManager) callManager(method string, args ...interface{}) error {
+	return d.retryOnDisconnect(func(c *systemdDbus.Conn) error {
+		dbusMu.RLock()
+		raw := dbusRawC
+		if dbusC != c {
+			raw = nil
+		}
+		dbusMu.RUnlock()
+		if raw == nil {
+			return dbus.ErrClosed
+		}
+		return raw.Object(systemdBusName, systemdPath).
+			Call("org.freedesktop.systemd1.Manager."+method, 0, args...).Err
+	})
+}
+
 var errDbusConnClosed = dbus.ErrClosed.Error()
 
-// retryOnDisconnect calls op, and if the error it returns is about closed dbus
-// connection, the connection is re-established and the op is retried. This helps
-// with the situation when dbus is restarted and we have a stale connection.
+const (
+	// dbusRetries is the max number of times an operation is retried
+	// after the connection is found to be broken or can't be established.
+	dbusRetries = 5
+	// dbusRetryDelay is the initial delay before reconnecting to dbus
+	// after a failed connection attempt. It is doubled on every retry.
+	dbusRetryDelay = 50 * time.Millisecond
+)
+
+// isDbusConnBroken returns true if err means the dbus connection is closed
+// or broken (e.g. because dbus-daemon or systemd was restarted), meaning
+// the connection has to be re-established.
+func isDbusConnBroken(err error) bool {
+	return errors.Is(err, dbus.ErrClosed) || isDbusError(err, errDbusConnClosed) ||
+		errors.Is(err, unix.EPIPE) || errors.Is(err, unix.ECONNRESET)
+}
+
+// transientDbusErrors are the names of dbus errors which are likely to go
+// away if the call is retried a bit later, such as when systemd or dbus
+// daemon is busy, or is being restarted or reloaded.
+var transientDbusErrors = []string{
+	"org.freedesktop.DBus.Error.NoReply",
+	"org.freedesktop.DBus.Error.Timeout",
+	"org.freedesktop.DBus.Error.TimedOut",
+	"org.freedesktop.DBus.Error.ServiceUnknown",
+	"org.freedesktop.DBus.Error.NameHasNoOwner",
+}
+
+// isDbusErrorTransient returns true if err is a dbus error which is likely
+// to be temporary, so the call which failed is worth retrying.
+func isDbusErrorTransient(err error) bool {
+	var derr dbus.Error
+	if !errors.As(err, &derr) {
+		return false
+	}
+	for _, name := range transientDbusErrors {
+		if derr.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+// retryOnDisconnect calls op, and if the error it returns is about closed or
+// broken dbus connection, the connection is re-established and the op is
+// retried. This helps with the situation when dbus is restarted and we have
+// a stale connection. Failures to (re)connect, as well as transient errors
+// (see isDbusErrorTransient), are retried with a backoff, since systemd may
+// need a moment to come back after a restart or reload.
 func (d *dbusConnManager) retryOnDisconnect(op func(*systemdDbus.Conn) error) error {
-	for {
+	delay := dbusRetryDelay
+	for i := 0; ; i++ {
+		backoff := true
 		conn, err := d.getConnection()
-		if err != nil {
-			return err
+		if err == nil {
+			err = op(conn)
+			switch {
+			case isDbusConnBroken(err):
+				d.resetConnection(conn)
+				// Reconnect right away.
+				backoff = false
+			case !isDbusErrorTransient(err):
+				return err
+			}
 		}
-		err = op(conn)
-		if !isDbusError(err, errDbusConnClosed) {
+		if i == dbusRetries {
 			return err
 		}
-		d.resetConnection(conn)
+		if backoff {
+			time.Sleep(delay)
+			delay *= 2
+		}
 	}
 }
@@ -3,11 +3,18 @@ package systemd
 import (
 	"bufio"
 	"bytes"
+	"fmt"
+	"math"
 	"os"
 	"os/exec"
+	"reflect"
 	"strings"
 	"testing"
 
+	systemdDbus "github.com/coreos/go-systemd/v22/dbus"
+	dbus "github.com/godbus/dbus/v5"
+	"golang.org/x/sys/unix"
+
 	"github.com/opencontainers/runc/libcontainer/cgroups"
 	"github.com/opencontainers/runc/libcontainer/configs"
 	"github.com/opencontainers/runc/libcontainer/devices"
@@ -57,6 +64,27 @@ func TestSystemdVersion(t *testing.T) {
 	}
 }
 
+func TestVersionSupports(t *testing.T) {
+	tests := []struct {
+		ver      int
+		feature  string
+		expected bool
+	}{
+		{241, "CPUQuotaPeriodUSec", false},
+		{242, "CPUQuotaPeriodUSec", true},
+		{243, "AllowedCPUs", false},
+		{244, "AllowedMemoryNodes", true},
+		{245, "FreezeUnit", false},
+		{-1, "FreezeUnit", false},
+		{219, "MemoryMax", true}, // not gated
+	}
+	for _, tc := range tests {
+		if got := versionSupports(tc.ver, tc.feature); got != tc.expected {
+			t.Errorf("versionSupports(%d, %q): want %v, got %v", tc.ver, tc.feature, tc.expected, got)
+		}
+	}
+}
+
 func TestValidUnitTypes(t *testing.T) {
 	testCases := []struct {
 		unitName         string
@@ -454,3 +482,382 @@ func TestFreezePodCgroup(t *testing.T) {
 		t.Fatalf("expected %q, got %q", marker, reply)
 	}
 }
+
+func TestIsDbusConnBroken(t *testing.T) {
+	testCases := []struct {
+		err    error
+		broken bool
+	}{
+		{err: nil, broken: false},
+		{err: dbus.ErrClosed, broken: true},
+		{err: fmt.Errorf("call failed: %w", dbus.ErrClosed), broken: true},
+		{err: &os.SyscallError{Syscall: "write", Err: unix.EPIPE}, broken: true},
+		{err: dbus.Error{Name: "org.freedesktop.systemd1.UnitExists"}, broken: false},
+	}
+	for _, tc := range testCases {
+		if got := isDbusConnBroken(tc.err); got != tc.broken {
+			t.Errorf("isDbusConnBroken(%v): expected %v, got %v", tc.err, tc.broken, got)
+		}
+	}
+}
+
+func TestIsDbusErrorTransient(t *testing.T) {
+	testCases := []struct {
+		err       error
+		transient bool
+	}{
+		{err: nil, transient: false},
+		{err: dbus.ErrClosed, transient: false},
+		{err: dbus.Error{Name: "org.freedesktop.DBus.Error.NoReply"}, transient: true},
+		{err: fmt.Errorf("call failed: %w", dbus.Error{Name: "org.freedesktop.DBus.Error.NameHasNoOwner"}), transient: true},
+		{err: dbus.Error{Name: "org.freedesktop.systemd1.UnitExists"}, transient: false},
+	}
+	for _, tc := range testCases {
+		if got := isDbusErrorTransient(tc.err); got != tc.transient {
+			t.Errorf("isDbusErrorTransient(%v): expected %v, got %v", tc.err, tc.transient, got)
+		}
+	}
+}
+
+func TestFsOnlyResources(t *testing.T) {
+	r := &configs.Resources{
+		Memory:     1 << 30,
+		MemorySwap: 2 << 30,
+		CpuWeight:  100,
+		CpuQuota:   12345,
+		PidsLimit:  42,
+	}
+	props := []systemdDbus.Property{
+		newProp("MemoryMax", uint64(r.Memory)),
+		newProp("MemorySwapMax", uint64(r.MemorySwap-r.Memory)),
+		newProp("CPUWeight", r.CpuWeight),
+		newProp("CPUQuotaPerSecUSec", uint64(130000)),
+	}
+
+	fsRes := fsOnlyResources(r, props)
+	if fsRes.Memory != 0 || fsRes.MemorySwap != 0 || fsRes.CpuWeight != 0 {
+		t.Errorf("expected resources set via unit properties to be dropped, got %+v", fsRes)
+	}
+	// CPU quota is rounded by systemd, pids limit was not set via systemd.
+	if fsRes.CpuQuota != r.CpuQuota || fsRes.PidsLimit != r.PidsLimit {
+		t.Errorf("expected other resources to be kept, got %+v", fsRes)
+	}
+	if r.Memory == 0 {
+		t.Error("original resources must not be modified")
+	}
+}
+
+func TestAddMemorySwap(t *testing.T) {
+	for _, tc := range []struct {
+		memory, memorySwap int64
+		expected           *uint64
+		expErr             bool
+	}{
+		{memory: 0, memorySwap: 0},
+		{memory: 1 << 20, memorySwap: 0},
+		{memory: 1 << 20, memorySwap: 3 << 20, expected: u64(2 << 20)},
+		// no swap
+		{memory: 1 << 20, memorySwap: 1 << 20, expected: u64(0)},
+		// unlimited swap
+		{memory: 1 << 20, memorySwap: -1, expected: u64(math.MaxUint64)},
+		{memory: 0, memorySwap: -1, expected: u64(math.MaxUint64)},
+		// unlimited memory and swap
+		{memory: -1, memorySwap: 0, expected: u64(math.MaxUint64)},
+		{memory: 0, memorySwap: 1 << 20, expErr: true},
+		{memory: 2 << 20, memorySwap: 1 << 20, expErr: true},
+	} {
+		var props []systemdDbus.Property
+		err := addMemorySwap(&props, &configs.Resources{Memory: tc.memory, MemorySwap: tc.memorySwap})
+		if tc.expErr {
+			if err == nil {
+				t.Errorf("%+v: expected error, got nil", tc)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%+v: unexpected error: %v", tc, err)
+			continue
+		}
+		if tc.expected == nil {
+			if len(props) != 0 {
+				t.Errorf("%+v: expected no properties, got %+v", tc, props)
+			}
+			continue
+		}
+		if len(props) != 1 || props[0].Name != "MemorySwapMax" || props[0].Value.Value() != *tc.expected {
+			t.Errorf("%+v: expected MemorySwapMax=%d, got %+v", tc, *tc.expected, props)
+		}
+	}
+}
+
+func u64(v uint64) *uint64 {
+	return &v
+}
+
+func TestAddIo(t *testing.T) {
+	r := &configs.Resources{
+		BlkioWeight: 500,
+		BlkioWeightDevice: []*configs.WeightDevice{
+			configs.NewWeightDevice(8, 0, 1000, 0),
+			// Leaf weight only, not supported.
+			configs.NewWeightDevice(8, 16, 0, 500),
+		},
+		BlkioThrottleReadBpsDevice: []*configs.ThrottleDevice{configs.NewThrottleDevice(8, 0, 1048576)},
+		BlkioThrottleWriteIOPSDevice: []*configs.ThrottleDevice{
+			configs.NewThrottleDevice(8, 16, 0),
+		},
+		BlkioLatencyDevice: []*configs.LatencyDevice{configs.NewLatencyDevice(8, 0, 5000)},
+	}
+	var props []systemdDbus.Property
+	addIo(&props, r, 240)
+
+	expected := map[string]interface{}{
+		"IOWeight":                  cgroups.ConvertBlkIOToIOWeightValue(500),
+		"IODeviceWeight":            []ioDeviceWeight{{Path: "/dev/block/8:0", Weight: 10000}},
+		"IOReadBandwidthMax":        []ioDeviceLimit{{Path: "/dev/block/8:0", Limit: 1048576}},
+		"IOWriteIOPSMax":            []ioDeviceLimit{{Path: "/dev/block/8:16", Limit: math.MaxUint64}},
+		"IODeviceLatencyTargetUSec": []ioDeviceLimit{{Path: "/dev/block/8:0", Limit: 5000}},
+	}
+	if len(props) != len(expected) {
+		t.Fatalf("expected %d properties, got %+v", len(expected), props)
+	}
+	for _, p := range props {
+		exp, ok := expected[p.Name]
+		if !ok {
+			t.Errorf("unexpected property %s", p.Name)
+			continue
+		}
+		if !reflect.DeepEqual(p.Value.Value(), exp) {
+			t.Errorf("property %s: expected %v, got %v", p.Name, exp, p.Value.Value())
+		}
+	}
+
+	// IODeviceLatencyTargetUSec requires systemd v240.
+	props = nil
+	addIo(&props, r, 239)
+	for _, p := range props {
+		if p.Name == "IODeviceLatencyTargetUSec" {
+			t.Errorf("unexpected property %s with systemd v239", p.Name)
+		}
+	}
+	if len(props) != len(expected)-1 {
+		t.Errorf("expected %d properties with systemd v239, got %+v", len(expected)-1, props)
+	}
+
+	// No IODeviceWeight if there are only leaf weights.
+	props = nil
+	addIo(&props, &configs.Resources{
+		BlkioWeightDevice: []*configs.WeightDevice{configs.NewWeightDevice(8, 0, 0, 500)},
+	}, 240)
+	if len(props) != 0 {
+		t.Errorf("expected no properties for leaf weights, got %+v", props)
+	}
+}
+
+func TestAddBlockIO(t *testing.T) {
+	r := &configs.Resources{
+		BlkioWeight: 500,
+		BlkioWeightDevice: []*configs.WeightDevice{
+			configs.NewWeightDevice(8, 0, 1000, 0),
+			// Leaf weight only, not supported.
+			configs.NewWeightDevice(8, 16, 0, 500),
+		},
+		BlkioThrottleWriteBpsDevice: []*configs.ThrottleDevice{configs.NewThrottleDevice(8, 16, 0)},
+		BlkioThrottleReadIOPSDevice: []*configs.ThrottleDevice{configs.NewThrottleDevice(8, 0, 100)},
+	}
+	var props []systemdDbus.Property
+	addBlockIO(&props, r)
+
+	expected := map[string]interface{}{
+		"BlockIOWeight":         uint64(500),
+		"BlockIODeviceWeight":   []ioDeviceWeight{{Path: "/dev/block/8:0", Weight: 1000}},
+		"BlockIOWriteBandwidth": []ioDeviceLimit{{Path: "/dev/block/8:16", Limit: math.MaxUint64}},
+	}
+	if len(props) != len(expected) {
+		t.Fatalf("expected %d properties, got %+v", len(expected), props)
+	}
+	for _, p := range props {
+		exp, ok := expected[p.Name]
+		if !ok {
+			t.Errorf("unexpected property %s", p.Name)
+			continue
+		}
+		if !reflect.DeepEqual(p.Value.Value(), exp) {
+			t.Errorf("property %s: expected %v, got %v", p.Name, exp, p.Value.Value())
+		}
+	}
+}
+
+func TestChangedUnitProperties(t *testing.T) {
+	current := map[string]interface{}{
+		"MemoryMax":          uint64(1 << 30),
+		"CPUWeight":          uint64(100),
+		"CPUQuotaPerSecUSec": uint64(200000),
+		"TasksMax":           uint64(42),
+		"IOWeight":           uint64(500),
+		"DeviceAllow":        [][]interface{}{{"/dev/null", "rwm"}},
+		"Delegate":           true,
+	}
+	props := []systemdDbus.Property{
+		newProp("MemoryMax", uint64(1<<30)),
+		newProp("CPUWeight", uint64(200)),
+		newProp("DeviceAllow", []deviceAllowEntry{{Path: "/dev/null", Perms: "rwm"}}),
+	}
+	// IOWeight was not set by runc, so it must not be reset.
+	prev := []systemdDbus.Property{
+		newProp("MemoryMax", uint64(1<<30)),
+		newProp("CPUWeight", uint64(100)),
+		newProp("CPUQuotaPerSecUSec", uint64(200000)),
+		newProp("TasksMax", uint64(42)),
+	}
+	keep := []systemdDbus.Property{newProp("TasksMax", uint64(42))}
+
+	changed := changedUnitProperties(current, props, prev, keep)
+	got := make(map[string]interface{})
+	for _, p := range changed {
+		got[p.Name] = p.Value.Value()
+	}
+	expected := map[string]interface{}{
+		// Changed.
+		"CPUWeight": uint64(200),
+		// No longer wanted, reset to default.
+		"CPUQuotaPerSecUSec": uint64(math.MaxUint64),
+	}
+	if !reflect.DeepEqual(got, expected) {
+		t.Errorf("expected %v, got %v", expected, got)
+	}
+}
+
+func TestUnitObjectPath(t *testing.T) {
+	for in, exp := range map[string]dbus.ObjectPath{
+		"runc-abc.scope":       "/org/freedesktop/systemd1/unit/runc_2dabc_2escope",
+		"1a.slice":             "/org/freedesktop/systemd1/unit/_31a_2eslice",
+		"system-foo_bar.slice": "/org/freedesktop/systemd1/unit/system_2dfoo_5fbar_2eslice",
+		"":                     "/org/freedesktop/systemd1/unit/_",
+	} {
+		if got := unitObjectPath(in); got != exp {
+			t.Errorf("unitObjectPath(%q): expected %q, got %q", in, exp, got)
+		}
+	}
+}
+
+func TestUnitEventFromSignal(t *testing.T) {
+	const unit = "runc-abc.scope"
+	path := unitObjectPath(unit)
+	propsChanged := func(p dbus.ObjectPath, iface, name, value string) *dbus.Signal {
+		return &dbus.Signal{
+			Path: p,
+			Name: "org.freedesktop.DBus.Properties.PropertiesChanged",
+			Body: []interface{}{iface, map[string]dbus.Variant{name: dbus.MakeVariant(value)}, []string{}},
+		}
+	}
+
+	testCases := []struct {
+		sig *dbus.Signal
+		exp UnitEventType // 0 means no event
+	}{
+		{propsChanged(path, "org.freedesktop.systemd1.Unit", "ActiveState", "inactive"), UnitStopped},
+		{propsChanged(path, "org.freedesktop.systemd1.Unit", "ActiveState", "deactivating"), UnitStopped},
+		{propsChanged(path, "org.freedesktop.systemd1.Unit", "ActiveState", "failed"), UnitStopped},
+		{propsChanged(path, "org.freedesktop.systemd1.Unit", "ActiveState", "active"), 0},
+		{propsChanged(path, "org.freedesktop.systemd1.Scope", "Result", "oom-kill"), UnitOOMKilled},
+		{propsChanged(path, "org.freedesktop.systemd1.Scope", "Result", "success"), 0},
+		{propsChanged(unitObjectPath("other.scope"), "org.freedesktop.systemd1.Unit", "ActiveState", "failed"), 0},
+		{&dbus.Signal{
+			Name: "org.freedesktop.systemd1.Manager.UnitRemoved",
+			Body: []interface{}{unit, path},
+		}, UnitRemoved},
+		{&dbus.Signal{
+			Name: "org.freedesktop.systemd1.Manager.UnitRemoved",
+			Body: []interface{}{"other.scope", unitObjectPath("other.scope")},
+		}, 0},
+	}
+	for i, tc := range testCases {
+		ev, ok := unitEventFromSignal(tc.sig, unit, path)
+		if ok != (tc.exp != 0) || (ok && ev.Type != tc.exp) {
+			t.Errorf("case %d: expected %v, got %v (ok: %v)", i, tc.exp, ev.Type, ok)
+		}
+		if ok && ev.Unit != unit {
+			t.Errorf("case %d: expected unit %q, got %q", i, unit, ev.Unit)
+		}
+	}
+}
+
+func TestAddUnitStop(t *testing.T) {
+	sigkill := false
+	var props []systemdDbus.Property
+	addUnitStop(&props, &configs.UnitStop{TimeoutUSec: 5000000, KillMode: "mixed", SendSIGKILL: &sigkill})
+
+	expected := map[string]interface{}{
+		"TimeoutStopUSec": uint64(5000000),
+		"KillMode":        "mixed",
+		"SendSIGKILL":     false,
+	}
+	got := make(map[string]interface{})
+	for _, p := range props {
+		got[p.Name] = p.Value.Value()
+	}
+	if !reflect.DeepEqual(got, expected) {
+		t.Errorf("expected %v, got %v", expected, got)
+	}
+
+	props = nil
+	addUnitStop(&props, &configs.UnitStop{})
+	if len(props) != 0 {
+		t.Errorf("expected no properties, got %+v", props)
+	}
+}
+
+func TestExpandSlice(t *testing.T) {
+	testCases := []struct {
+		in, exp string
+		isErr   bool
+	}{
+		{in: "-.slice", exp: "/"},
+		{in: "system.slice", exp: "/system.slice"},
+		{in: "machine-vm-a.slice", exp: "/machine.slice/machine-vm.slice/machine-vm-a.slice"},
+		{in: "test-" + EscapeSliceComponent("a-b") + ".slice", exp: `/test.slice/test-a\x2db.slice`},
+		{in: "test--a.slice", isErr: true},
+		{in: "-test.slice", isErr: true},
+		{in: "test/a.slice", isErr: true},
+		{in: "test.scope", isErr: true},
+	}
+	for _, tc := range testCases {
+		got, err := ExpandSlice(tc.in)
+		if tc.isErr {
+			if err == nil {
+				t.Errorf("ExpandSlice(%q): expected error, got %q", tc.in, got)
+			}
+			continue
+		}
+		if err != nil || got != tc.exp {
+			t.Errorf("ExpandSlice(%q): expected %q, got %q (err: %v)", tc.in, tc.exp, got, err)
+		}
+	}
+}
+
+func TestEscapeSliceComponent(t *testing.T) {
+	for in, exp := range map[string]string{
+		"abc":      "abc",
+		"a-b":      `a\x2db`,
+		".hidden":  `\x2ehidden`,
+		"a/b c":    `a\x2fb\x20c`,
+		"v1.2_x:y": "v1.2_x:y",
+	} {
+		if got := EscapeSliceComponent(in); got != exp {
+			t.Errorf("EscapeSliceComponent(%q): expected %q, got %q", in, exp, got)
+		}
+	}
+}
+
+func TestGetParentSlice(t *testing.T) {
+	if got := getParentSlice(&configs.Cgroup{Rootless: true}); got != "user.slice" {
+		t.Errorf("expected user.slice, got %q", got)
+	}
+	if got := getParentSlice(&configs.Cgroup{}); got != "system.slice" {
+		t.Errorf("expected system.slice, got %q", got)
+	}
+	if got := getParentSlice(&configs.Cgroup{Parent: "my.slice", Rootless: true}); got != "my.slice" {
+		t.Errorf("expected my.slice, got %q", got)
+	}
+}
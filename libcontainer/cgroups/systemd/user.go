@@ -11,14 +11,14 @@ import (
 	"strconv"
 	"strings"
 
-	systemdDbus "github.com/coreos/go-systemd/v22/dbus"
 	dbus "github.com/godbus/dbus/v5"
 
 	"github.com/opencontainers/runc/libcontainer/userns"
 )
 
-// newUserSystemdDbus creates a connection for systemd user-instance.
-func newUserSystemdDbus() (*systemdDbus.Conn, error) {
+// userSystemdDbusDialer returns a function to create connections
+// for systemd user-instance.
+func userSystemdDbusDialer() (func() (*dbus.Conn, error), error) {
 	addr, err := DetectUserDbusSessionBusAddress()
 	if err != nil {
 		return nil, err
@@ -28,7 +28,7 @@ func newUserSystemdDbus() (*systemdDbus.Conn, error) {
 		return nil, err
 	}
 
-	return systemdDbus.NewConnection(func() (*dbus.Conn, error) {
+	return func() (*dbus.Conn, error) {
 		conn, err := dbus.Dial(addr)
 		if err != nil {
 			return nil, fmt.Errorf("error while dialing %q: %w", addr, err)
@@ -44,7 +44,7 @@ func newUserSystemdDbus() (*systemdDbus.Conn, error) {
 			return nil, fmt.Errorf("error while sending Hello message (address=%q, UID=%d): %w", addr, uid, err)
 		}
 		return conn, nil
-	})
+	}, nil
 }
 
 // DetectUID detects UID from the OwnerUID field of `busctl --user status`
@@ -1,7 +1,9 @@
 package systemd
 
 import (
+	"context"
 	"errors"
+	"math"
 	"os"
 	"path/filepath"
 	"reflect"
@@ -74,13 +76,10 @@ var legacySubsystems = []subsystem{
 }
 
 func genV1ResourcesProperties(r *configs.Resources, cm *dbusConnManager) ([]systemdDbus.Property, error) {
-	var properties []systemdDbus.Property
-
-	deviceProperties, err := generateDeviceProperties(r)
+	properties, err := genCommonResourcesProperties(r, cm)
 	if err != nil {
 		return nil, err
 	}
-	properties = append(properties, deviceProperties...)
 
 	if r.Memory != 0 {
 		properties = append(properties,
@@ -92,35 +91,63 @@ func genV1ResourcesProperties(r *configs.Resources, cm *dbusConnManager) ([]syst
 			newProp("CPUShares", r.CpuShares))
 	}
 
-	addCpuQuota(cm, &properties, r.CpuQuota, r.CpuPeriod)
+	addBlockIO(&properties, r)
 
+	return properties, nil
+}
+
+// addBlockIO adds the blkio unit properties. There are no systemd
+// properties for IOPS limits, so these are only set via cgroupfs.
+func addBlockIO(props *[]systemdDbus.Property, r *configs.Resources) {
 	if r.BlkioWeight != 0 {
-		properties = append(properties,
+		*props = append(*props,
 			newProp("BlockIOWeight", uint64(r.BlkioWeight)))
 	}
-
-	if r.PidsLimit > 0 || r.PidsLimit == -1 {
-		properties = append(properties,
-			newProp("TasksMax", uint64(r.PidsLimit)))
+	if len(r.BlkioWeightDevice) > 0 {
+		weights := make([]ioDeviceWeight, 0, len(r.BlkioWeightDevice))
+		for _, wd := range r.BlkioWeightDevice {
+			// Skip the entries only setting the leaf weight,
+			// which systemd does not support.
+			if wd.Weight == 0 {
+				continue
+			}
+			weights = append(weights, ioDeviceWeight{
+				Path:   ioDevicePath(wd.Major, wd.Minor),
+				Weight: uint64(wd.Weight),
+			})
+		}
+		if len(weights) > 0 {
+			*props = append(*props, newProp("BlockIODeviceWeight", weights))
+		}
 	}
-
-	err = addCpuset(cm, &properties, r.CpusetCpus, r.CpusetMems)
-	if err != nil {
-		return nil, err
+	for _, l := range []struct {
+		name string
+		tds  []*configs.ThrottleDevice
+	}{
+		{"BlockIOReadBandwidth", r.BlkioThrottleReadBpsDevice},
+		{"BlockIOWriteBandwidth", r.BlkioThrottleWriteBpsDevice},
+	} {
+		if len(l.tds) == 0 {
+			continue
+		}
+		limits := make([]ioDeviceLimit, 0, len(l.tds))
+		for _, td := range l.tds {
+			// A zero rate means no limit, which is "infinity" for systemd.
+			limit := uint64(math.MaxUint64)
+			if td.Rate != 0 {
+				limit = td.Rate
+			}
+			limits = append(limits, ioDeviceLimit{Path: ioDevicePath(td.Major, td.Minor), Limit: limit})
+		}
+		*props = append(*props, newProp(l.name, limits))
 	}
-
-	return properties, nil
 }
 
 // initPaths figures out and returns paths to cgroups.
 func initPaths(c *configs.Cgroup) (map[string]string, error) {
-	slice := "system.slice"
-	if c.Parent != "" {
-		var err error
-		slice, err = ExpandSlice(c.Parent)
-		if err != nil {
-			return nil, err
-		}
+	slice, err := ExpandSlice(getParentSlice(c))
+	if err != nil {
+		return nil, err
 	}
 
 	unit := getUnitName(c)
@@ -163,50 +190,23 @@ func (m *legacyManager) Apply(pid int) error {
 	var (
 		c          = m.cgroups
 		unitName   = getUnitName(c)
-		slice      = "system.slice"
+		slice      = getParentSlice(c)
 		properties []systemdDbus.Property
 	)
 
 	m.mu.Lock()
 	defer m.mu.Unlock()
 
-	if c.Parent != "" {
-		slice = c.Parent
-	}
-
-	properties = append(properties, systemdDbus.PropDescription("libcontainer container "+c.Name))
-
-	if strings.HasSuffix(unitName, ".slice") {
-		// If we create a slice, the parent is defined via a Wants=.
-		properties = append(properties, systemdDbus.PropWants(slice))
-	} else {
-		// Otherwise it's a scope, which we put into a Slice=.
-		properties = append(properties, systemdDbus.PropSlice(slice))
-		// Assume scopes always support delegation (supported since systemd v218).
-		properties = append(properties, newProp("Delegate", true))
-	}
-
-	// only add pid if its valid, -1 is used w/ general slice creation.
-	if pid != -1 {
-		properties = append(properties, newProp("PIDs", []uint32{uint32(pid)}))
-	}
-
-	// Always enable accounting, this gets us the same behaviour as the fs implementation,
-	// plus the kernel has some problems with joining the memory cgroup at a later time.
-	properties = append(properties,
-		newProp("MemoryAccounting", true),
-		newProp("CPUAccounting", true),
-		newProp("BlockIOAccounting", true),
-		newProp("TasksAccounting", true),
-	)
-
-	// Assume DefaultDependencies= will always work (the check for it was previously broken.)
-	properties = append(properties,
-		newProp("DefaultDependencies", false))
+	properties = genUnitProperties(m.dbus, c, unitName, slice, pid, "BlockIOAccounting")
+	addUnitStop(&properties, c.UnitStop)
 
-	properties = append(properties, c.SystemdProps...)
+	properties = append(properties, extraProperties(c, false)...)
 
-	if err := startUnit(m.dbus, unitName, properties); err != nil {
+	if c.ExistingUnit {
+		if err := attachToUnit(m.dbus, unitName, pid); err != nil {
+			return err
+		}
+	} else if _, err := startUnit(m.dbus, unitName, properties, false); err != nil {
 		return err
 	}
 
@@ -221,6 +221,11 @@ func (m *legacyManager) Destroy() error {
 	m.mu.Lock()
 	defer m.mu.Unlock()
 
+	// The unit (and its cgroups) is owned by someone else.
+	if m.cgroups.ExistingUnit {
+		return nil
+	}
+
 	stopErr := stopUnit(m.dbus, getUnitName(m.cgroups))
 
 	// Both on success and on error, cleanup all the cgroups
@@ -408,10 +413,43 @@ func (m *legacyManager) Set(r *configs.Resources) error {
 	if r.Unified != nil {
 		return cgroups.ErrV1NoUnified
 	}
+	// The unit is owned by someone else, so leave its properties alone
+	// and only set the resources via cgroupfs.
+	if !m.cgroups.ExistingUnit {
+		if err := m.setUnitProperties(r); err != nil {
+			return err
+		}
+	}
+
+	for _, sys := range legacySubsystems {
+		// Get the subsystem path, but don't error out for not found cgroups.
+		path, ok := m.paths[sys.Name()]
+		if !ok {
+			continue
+		}
+		if err := sys.Set(path, r); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// setUnitProperties sets the unit properties corresponding to r, resetting
+// those set earlier from the current resources, if needed.
+func (m *legacyManager) setUnitProperties(r *configs.Resources) error {
 	properties, err := genV1ResourcesProperties(r, m.dbus)
 	if err != nil {
 		return err
 	}
+	properties = append(properties, extraProperties(m.cgroups, true)...)
+
+	// The properties generated from the current resources are the ones
+	// set earlier, which may need to be reset.
+	var prev []systemdDbus.Property
+	if m.cgroups.Resources != nil {
+		prev, _ = genV1ResourcesProperties(m.cgroups.Resources, m.dbus)
+	}
 
 	unitName := getUnitName(m.cgroups)
 	needsFreeze, needsThaw, err := m.freezeBeforeSet(unitName, r)
@@ -425,28 +463,13 @@ func (m *legacyManager) Set(r *configs.Resources) error {
 			logrus.Infof("freeze container before SetUnitProperties failed: %v", err)
 		}
 	}
-	setErr := setUnitProperties(m.dbus, unitName, properties...)
+	setErr := updateUnitProperties(m.dbus, unitName, properties, prev, extraProperties(m.cgroups, false))
 	if needsThaw {
 		if err := m.doFreeze(configs.Thawed); err != nil {
 			logrus.Infof("thaw container after SetUnitProperties failed: %v", err)
 		}
 	}
-	if setErr != nil {
-		return setErr
-	}
-
-	for _, sys := range legacySubsystems {
-		// Get the subsystem path, but don't error out for not found cgroups.
-		path, ok := m.paths[sys.Name()]
-		if !ok {
-			continue
-		}
-		if err := sys.Set(path, r); err != nil {
-			return err
-		}
-	}
-
-	return nil
+	return setErr
 }
 
 func (m *legacyManager) GetPaths() map[string]string {
@@ -472,6 +495,19 @@ func (m *legacyManager) Exists() bool {
 	return cgroups.PathExists(m.Path("devices"))
 }
 
+// WatchUnit implements UnitWatcher.
+func (m *legacyManager) WatchUnit(ctx context.Context) (<-chan UnitEvent, error) {
+	return watchUnit(ctx, m.dbus, getUnitName(m.cgroups))
+}
+
 func (m *legacyManager) OOMKillCount() (uint64, error) {
 	return fs.OOMKillCount(m.Path("memory"))
 }
+
+func (m *legacyManager) CgroupFD() (*os.File, error) {
+	return nil, cgroups.ErrNoCgroupFD
+}
+
+func (m *legacyManager) Reclaim(_ uint64) error {
+	return cgroups.ErrV1NoReclaim
+}
@@ -2,6 +2,7 @@ package systemd
 
 import (
 	"bufio"
+	"context"
 	"errors"
 	"fmt"
 	"math"
@@ -27,6 +28,9 @@ type unifiedManager struct {
 	path  string
 	dbus  *dbusConnManager
 	fsMgr cgroups.Manager
+	// startJob receives the result of the unit start job, if the unit
+	// was started by Apply with StartAsync.
+	startJob <-chan error
 }
 
 func NewUnifiedManager(config *configs.Cgroup, path string) (cgroups.Manager, error) {
@@ -114,15 +118,9 @@ func unifiedResToSystemdProps(cm *dbusConnManager, res map[string]string) (props
 				"cpuset.cpus": "AllowedCPUs",
 				"cpuset.mems": "AllowedMemoryNodes",
 			}
-			// systemd only supports these properties since v244
-			sdVer := systemdVersion(cm)
-			if sdVer >= 244 {
+			if systemdSupports(cm, m[k]) {
 				props = append(props,
 					newProp(m[k], bits))
-			} else {
-				logrus.Debugf("systemd v%d is too old to support %s"+
-					" (setting will still be applied to cgroupfs)",
-					sdVer, m[k])
 			}
 
 		case "memory.high", "memory.low", "memory.min", "memory.max", "memory.swap.max":
@@ -175,18 +173,15 @@ func unifiedResToSystemdProps(cm *dbusConnManager, res map[string]string) (props
 }
 
 func genV2ResourcesProperties(r *configs.Resources, cm *dbusConnManager) ([]systemdDbus.Property, error) {
-	var properties []systemdDbus.Property
-
 	// NOTE: This is of questionable correctness because we insert our own
 	//       devices eBPF program later. Two programs with identical rules
 	//       aren't the end of the world, but it is a bit concerning. However
 	//       it's unclear if systemd removes all eBPF programs attached when
 	//       doing SetUnitProperties...
-	deviceProperties, err := generateDeviceProperties(r)
+	properties, err := genCommonResourcesProperties(r, cm)
 	if err != nil {
 		return nil, err
 	}
-	properties = append(properties, deviceProperties...)
 
 	if r.Memory != 0 {
 		properties = append(properties,
@@ -197,31 +192,16 @@ func genV2ResourcesProperties(r *configs.Resources, cm *dbusConnManager) ([]syst
 			newProp("MemoryLow", uint64(r.MemoryReservation)))
 	}
 
-	swap, err := cgroups.ConvertMemorySwapToCgroupV2Value(r.MemorySwap, r.Memory)
-	if err != nil {
+	if err := addMemorySwap(&properties, r); err != nil {
 		return nil, err
 	}
-	if swap != 0 {
-		properties = append(properties,
-			newProp("MemorySwapMax", uint64(swap)))
-	}
 
 	if r.CpuWeight != 0 {
 		properties = append(properties,
 			newProp("CPUWeight", r.CpuWeight))
 	}
 
-	addCpuQuota(cm, &properties, r.CpuQuota, r.CpuPeriod)
-
-	if r.PidsLimit > 0 || r.PidsLimit == -1 {
-		properties = append(properties,
-			newProp("TasksMax", uint64(r.PidsLimit)))
-	}
-
-	err = addCpuset(cm, &properties, r.CpusetCpus, r.CpusetMems)
-	if err != nil {
-		return nil, err
-	}
+	addIo(&properties, r, systemdVersion(cm))
 
 	// ignore r.KernelMemory
 
@@ -237,61 +217,158 @@ func genV2ResourcesProperties(r *configs.Resources, cm *dbusConnManager) ([]syst
 	return properties, nil
 }
 
-func (m *unifiedManager) Apply(pid int) error {
-	var (
-		c          = m.cgroups
-		unitName   = getUnitName(c)
-		properties []systemdDbus.Property
-	)
+// ioDeviceWeight and ioDeviceLimit are the elements of IODeviceWeight and
+// IO{Read,Write}{Bandwidth,IOPS}Max unit properties, respectively.
+type ioDeviceWeight struct {
+	Path   string
+	Weight uint64
+}
 
-	slice := "system.slice"
-	if m.cgroups.Rootless {
-		slice = "user.slice"
+type ioDeviceLimit struct {
+	Path  string
+	Limit uint64
+}
+
+// ioDevicePath returns a path systemd can use to refer to a block device.
+func ioDevicePath(major, minor int64) string {
+	return fmt.Sprintf("/dev/block/%d:%d", major, minor)
+}
+
+// addMemorySwap translates the OCI memory+swap limit into the systemd
+// MemorySwapMax unit property, which is the swap limit alone.
+func addMemorySwap(props *[]systemdDbus.Property, r *configs.Resources) error {
+	swap, err := cgroups.ConvertMemorySwapToCgroupV2Value(r.MemorySwap, r.Memory)
+	if err != nil {
+		return err
 	}
-	if c.Parent != "" {
-		slice = c.Parent
+	// Zero swap is either "unset", or "memory+swap is equal to memory",
+	// which means no swap. Note -1 ("max") becomes infinity.
+	if swap != 0 || r.MemorySwap > 0 {
+		*props = append(*props,
+			newProp("MemorySwapMax", uint64(swap)))
 	}
+	return nil
+}
 
-	properties = append(properties, systemdDbus.PropDescription("libcontainer container "+c.Name))
-
-	if strings.HasSuffix(unitName, ".slice") {
-		// If we create a slice, the parent is defined via a Wants=.
-		properties = append(properties, systemdDbus.PropWants(slice))
-	} else {
-		// Otherwise it's a scope, which we put into a Slice=.
-		properties = append(properties, systemdDbus.PropSlice(slice))
-		// Assume scopes always support delegation (supported since systemd v218).
-		properties = append(properties, newProp("Delegate", true))
+// addIo translates the blkio weights and throttling limits into systemd
+// IO* unit properties (see systemd.resource-control(5)).
+func addIo(props *[]systemdDbus.Property, r *configs.Resources, sdVer int) {
+	if r.BlkioWeight != 0 {
+		*props = append(*props,
+			newProp("IOWeight", cgroups.ConvertBlkIOToIOWeightValue(r.BlkioWeight)))
+	}
+	if len(r.BlkioWeightDevice) > 0 {
+		weights := make([]ioDeviceWeight, 0, len(r.BlkioWeightDevice))
+		for _, wd := range r.BlkioWeightDevice {
+			// Skip the entries only setting the leaf weight,
+			// which has no cgroup v2 equivalent.
+			if wd.Weight == 0 {
+				continue
+			}
+			weights = append(weights, ioDeviceWeight{
+				Path:   ioDevicePath(wd.Major, wd.Minor),
+				Weight: cgroups.ConvertBlkIOToIOWeightValue(wd.Weight),
+			})
+		}
+		if len(weights) > 0 {
+			*props = append(*props, newProp("IODeviceWeight", weights))
+		}
 	}
+	for _, l := range []struct {
+		name string
+		tds  []*configs.ThrottleDevice
+	}{
+		{"IOReadBandwidthMax", r.BlkioThrottleReadBpsDevice},
+		{"IOWriteBandwidthMax", r.BlkioThrottleWriteBpsDevice},
+		{"IOReadIOPSMax", r.BlkioThrottleReadIOPSDevice},
+		{"IOWriteIOPSMax", r.BlkioThrottleWriteIOPSDevice},
+	} {
+		if len(l.tds) == 0 {
+			continue
+		}
+		limits := make([]ioDeviceLimit, 0, len(l.tds))
+		for _, td := range l.tds {
+			// A zero rate means no limit, which is "infinity" for systemd.
+			limit := uint64(math.MaxUint64)
+			if td.Rate != 0 {
+				limit = td.Rate
+			}
+			limits = append(limits, ioDeviceLimit{Path: ioDevicePath(td.Major, td.Minor), Limit: limit})
+		}
+		*props = append(*props, newProp(l.name, limits))
+	}
+	if len(r.BlkioLatencyDevice) > 0 && versionSupports(sdVer, "IODeviceLatencyTargetUSec") {
+		targets := make([]ioDeviceLimit, 0, len(r.BlkioLatencyDevice))
+		for _, ld := range r.BlkioLatencyDevice {
+			// A zero target means none, which is "infinity" for systemd.
+			target := uint64(math.MaxUint64)
+			if ld.Target != 0 {
+				target = ld.Target
+			}
+			targets = append(targets, ioDeviceLimit{Path: ioDevicePath(ld.Major, ld.Minor), Limit: target})
+		}
+		*props = append(*props, newProp("IODeviceLatencyTargetUSec", targets))
+	}
+}
 
-	// only add pid if its valid, -1 is used w/ general slice creation.
-	if pid != -1 {
-		properties = append(properties, newProp("PIDs", []uint32{uint32(pid)}))
+// addManagedOOM adds systemd-oomd related unit properties.
+func addManagedOOM(cm *dbusConnManager, props *[]systemdDbus.Property, oom *configs.ManagedOOM) {
+	if oom == nil {
+		return
+	}
+	if oom.Swap != "" && systemdSupports(cm, "ManagedOOMSwap") {
+		*props = append(*props, newProp("ManagedOOMSwap", oom.Swap))
+	}
+	if oom.MemoryPressure != "" && systemdSupports(cm, "ManagedOOMMemoryPressure") {
+		*props = append(*props, newProp("ManagedOOMMemoryPressure", oom.MemoryPressure))
+	}
+	if oom.MemoryPressureLimit != 0 && systemdSupports(cm, "ManagedOOMMemoryPressureLimit") {
+		// systemd expects the permyriad value scaled to uint32
+		// (see UINT32_SCALE_FROM_PERMYRIAD in systemd sources).
+		limit := (uint64(oom.MemoryPressureLimit)*math.MaxUint32 + 5000) / 10000
+		*props = append(*props, newProp("ManagedOOMMemoryPressureLimit", uint32(limit)))
 	}
+}
 
-	// Always enable accounting, this gets us the same behaviour as the fs implementation,
-	// plus the kernel has some problems with joining the memory cgroup at a later time.
-	properties = append(properties,
-		newProp("MemoryAccounting", true),
-		newProp("CPUAccounting", true),
-		newProp("IOAccounting", true),
-		newProp("TasksAccounting", true),
+func (m *unifiedManager) Apply(pid int) error {
+	var (
+		c          = m.cgroups
+		unitName   = getUnitName(c)
+		properties []systemdDbus.Property
 	)
 
-	// Assume DefaultDependencies= will always work (the check for it was previously broken.)
-	properties = append(properties,
-		newProp("DefaultDependencies", false))
+	slice := getParentSlice(c)
 
-	properties = append(properties, c.SystemdProps...)
+	properties = genUnitProperties(m.dbus, c, unitName, slice, pid, "IOAccounting")
+	addManagedOOM(m.dbus, &properties, c.ManagedOOM)
+	addUnitStop(&properties, c.UnitStop)
 
-	if err := startUnit(m.dbus, unitName, properties); err != nil {
-		return fmt.Errorf("unable to start unit %q (properties %+v): %w", unitName, properties, err)
+	properties = append(properties, extraProperties(c, false)...)
+
+	if c.ExistingUnit {
+		if err := attachToUnit(m.dbus, unitName, pid); err != nil {
+			return err
+		}
+	} else {
+		job, err := startUnit(m.dbus, unitName, properties, c.StartAsync)
+		if err != nil {
+			return fmt.Errorf("unable to start unit %q (properties %+v): %w", unitName, properties, err)
+		}
+		m.startJob = job
 	}
 
 	if err := fs2.CreateCgroupPath(m.path, m.cgroups); err != nil {
 		return err
 	}
 
+	if m.startJob != nil {
+		// The unit may not have been started yet, so move the process
+		// into the cgroup right away, as systemd would do it later.
+		if err := cgroups.WriteCgroupProc(m.path, pid); err != nil {
+			return err
+		}
+	}
+
 	if c.OwnerUID != nil {
 		// The directory itself must be chowned.
 		err := os.Chown(m.path, *c.OwnerUID, -1)
@@ -344,6 +421,11 @@ func (m *unifiedManager) Destroy() error {
 	m.mu.Lock()
 	defer m.mu.Unlock()
 
+	// The unit (and its cgroup) is owned by someone else.
+	if m.cgroups.ExistingUnit {
+		return nil
+	}
+
 	unitName := getUnitName(m.cgroups)
 	if err := stopUnit(m.dbus, unitName); err != nil {
 		return err
@@ -367,16 +449,9 @@ func (m *unifiedManager) Path(_ string) string {
 // The value is incompatible with systemdDbus.PropSlice.
 func (m *unifiedManager) getSliceFull() (string, error) {
 	c := m.cgroups
-	slice := "system.slice"
-	if c.Rootless {
-		slice = "user.slice"
-	}
-	if c.Parent != "" {
-		var err error
-		slice, err = ExpandSlice(c.Parent)
-		if err != nil {
-			return "", err
-		}
+	slice, err := ExpandSlice(getParentSlice(c))
+	if err != nil {
+		return "", err
 	}
 
 	if c.Rootless {
@@ -418,6 +493,26 @@ func (m *unifiedManager) initPath() error {
 }
 
 func (m *unifiedManager) Freeze(state configs.FreezerState) error {
+	// Let systemd know about the unit being frozen or thawed, so that
+	// its view of the unit state is consistent with ours. An existing
+	// unit may contain more than the container, so leave it alone.
+	if !m.cgroups.ExistingUnit && systemdVersion(m.dbus) >= minSystemdVersion["FreezeUnit"] {
+		var method string
+		switch state {
+		case configs.Frozen:
+			method = "FreezeUnit"
+		case configs.Thawed:
+			method = "ThawUnit"
+		}
+		if method != "" {
+			unitName := getUnitName(m.cgroups)
+			if err := m.dbus.callManager(method, unitName); err != nil {
+				logrus.Debugf("systemd %s %q failed, falling back to cgroup.freeze: %v", method, unitName, err)
+			}
+		}
+	}
+	// Even if systemd has already done it, this is a no-op which also
+	// makes sure the cgroup has reached the requested state.
 	return m.fsMgr.Freeze(state)
 }
 
@@ -434,19 +529,93 @@ func (m *unifiedManager) GetStats() (*cgroups.Stats, error) {
 }
 
 func (m *unifiedManager) Set(r *configs.Resources) error {
+	if err := m.waitStartJob(); err != nil {
+		return err
+	}
 	if r == nil {
 		return nil
 	}
-	properties, err := genV2ResourcesProperties(r, m.dbus)
-	if err != nil {
+	fsRes := r
+	// The unit is owned by someone else, so leave its properties alone
+	// and only set the resources via cgroupfs.
+	if !m.cgroups.ExistingUnit {
+		properties, err := genV2ResourcesProperties(r, m.dbus)
+		if err != nil {
+			return err
+		}
+		properties = append(properties, extraProperties(m.cgroups, true)...)
+
+		// The properties generated from the current resources are the ones
+		// set earlier, which may need to be reset.
+		var prev []systemdDbus.Property
+		if m.cgroups.Resources != nil {
+			prev, _ = genV2ResourcesProperties(m.cgroups.Resources, m.dbus)
+		}
+
+		if err := updateUnitProperties(m.dbus, getUnitName(m.cgroups), properties, prev, extraProperties(m.cgroups, false)); err != nil {
+			return fmt.Errorf("unable to set unit properties: %w", err)
+		}
+		fsRes = fsOnlyResources(r, properties)
+	}
+
+	// fsMgr shares the config with us, and stores the resources it is
+	// given there on success, so replace these with the full ones. On
+	// error, keep the previous ones, as the new ones were not all set.
+	prevRes := m.cgroups.Resources
+	if err := m.fsMgr.Set(fsRes); err != nil {
+		m.cgroups.Resources = prevRes
 		return err
 	}
+	m.cgroups.Resources = r
+	return nil
+}
 
-	if err := setUnitProperties(m.dbus, getUnitName(m.cgroups), properties...); err != nil {
-		return fmt.Errorf("unable to set unit properties: %w", err)
+// waitStartJob waits for the unit start job started by Apply if StartAsync
+// is set, and returns its error, if any.
+func (m *unifiedManager) waitStartJob() error {
+	if m.startJob == nil {
+		return nil
 	}
+	err := <-m.startJob
+	m.startJob = nil
+	return err
+}
 
-	return m.fsMgr.Set(r)
+// fsOnlyResources returns a copy of r without the resources which were
+// set by systemd via the given unit properties and are exactly expressed
+// by them, so that these are not written to cgroupfs behind systemd's back
+// (and reverted on daemon-reload). Everything else (including CPU quota,
+// which systemd rounds, and the devices and unified resources) is still
+// written to cgroupfs.
+func fsOnlyResources(r *configs.Resources, properties []systemdDbus.Property) *configs.Resources {
+	set := make(map[string]bool, len(properties))
+	for _, p := range properties {
+		set[p.Name] = true
+	}
+	fsRes := *r
+	if set["MemoryMax"] {
+		fsRes.Memory = 0
+		// MemorySwap is a memory+swap limit (as on cgroup v1),
+		// and fsMgr needs Memory to convert it to memory.swap.max,
+		// so Memory can only be dropped along with MemorySwap.
+		if set["MemorySwapMax"] || r.MemorySwap == 0 {
+			fsRes.MemorySwap = 0
+		} else {
+			fsRes.Memory = r.Memory
+		}
+	} else if set["MemorySwapMax"] && r.Memory == 0 {
+		fsRes.MemorySwap = 0
+	}
+	if set["MemoryLow"] {
+		fsRes.MemoryReservation = 0
+	}
+	if set["CPUWeight"] {
+		fsRes.CpuWeight = 0
+	}
+	if set["TasksMax"] {
+		fsRes.PidsLimit = 0
+	}
+	return &fsRes
 }
 
 func (m *unifiedManager) GetPaths() map[string]string {
@@ -467,6 +636,21 @@ func (m *unifiedManager) Exists() bool {
 	return cgroups.PathExists(m.path)
 }
 
+// WatchUnit implements UnitWatcher.
+func (m *unifiedManager) WatchUnit(ctx context.Context) (<-chan UnitEvent, error) {
+	return watchUnit(ctx, m.dbus, getUnitName(m.cgroups))
+}
+
 func (m *unifiedManager) OOMKillCount() (uint64, error) {
 	return m.fsMgr.OOMKillCount()
 }
+
+func (m *unifiedManager) Reclaim(bytes uint64) error {
+	return m.fsMgr.Reclaim(bytes)
+}
+
+// CgroupFD is not supported since systemd can't start a scope unit
+// without any processes in it.
+func (m *unifiedManager) CgroupFD() (*os.File, error) {
+	return nil, cgroups.ErrNoCgroupFD
+}
@@ -0,0 +1,176 @@
+package systemd
+
+import (
+	"context"
+	"fmt"
+
+	dbus "github.com/godbus/dbus/v5"
+)
+
+// UnitEventType is the type of UnitEvent.
+type UnitEventType int
+
+const (
+	// UnitStopped means the unit is being stopped (for example, with
+	// systemctl stop), or has failed. It is only reported once.
+	UnitStopped UnitEventType = iota + 1
+	// UnitOOMKilled means the unit was terminated because of OOM.
+	UnitOOMKilled
+	// UnitRemoved means the unit was unloaded (garbage-collected) by
+	// systemd.
+	UnitRemoved
+)
+
+func (t UnitEventType) String() string {
+	switch t {
+	case UnitStopped:
+		return "stopped"
+	case UnitOOMKilled:
+		return "oom-killed"
+	case UnitRemoved:
+		return "removed"
+	}
+	return fmt.Sprintf("UnitEventType(%d)", int(t))
+}
+
+// UnitEvent is a change of the container unit state, as reported by systemd.
+type UnitEvent struct {
+	Type UnitEventType
+	// Unit is the unit name.
+	Unit string
+}
+
+// UnitWatcher is implemented by the systemd cgroup managers.
+type UnitWatcher interface {
+	// WatchUnit subscribes to systemd signals about the container unit,
+	// returning a channel to receive the unit events from. The channel
+	// is closed once ctx is done, or the connection to systemd is lost.
+	WatchUnit(ctx context.Context) (<-chan UnitEvent, error)
+}
+
+const (
+	systemdBusName = "org.freedesktop.systemd1"
+	systemdPath    = "/org/freedesktop/systemd1"
+)
+
+// unitObjectPath returns the dbus object path of the unit.
+// This mirrors bus_label_escape() from systemd.
+func unitObjectPath(unitName string) dbus.ObjectPath {
+	if unitName == "" {
+		return systemdPath + "/unit/_"
+	}
+	path := []byte(systemdPath + "/unit/")
+	for i := 0; i < len(unitName); i++ {
+		c := unitName[i]
+		if (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') || (i > 0 && c >= '0' && c <= '9') {
+			path = append(path, c)
+			continue
+		}
+		path = append(path, fmt.Sprintf("_%02x", c)...)
+	}
+	return dbus.ObjectPath(path)
+}
+
+// watchUnit implements UnitWatcher.WatchUnit for a unit. It uses a
+// separate dbus connection, which is closed when the watch ends.
+func watchUnit(ctx context.Context, cm *dbusConnManager, unitName string) (<-chan UnitEvent, error) {
+	dial, err := cm.dialer()
+	if err != nil {
+		return nil, err
+	}
+	conn, err := dial()
+	if err != nil {
+		return nil, fmt.Errorf("unable to connect to dbus: %w", err)
+	}
+	unitPath := unitObjectPath(unitName)
+
+	// Match rules are only needed when connected via a bus (rather
+	// than directly to systemd), so errors are ignored here.
+	_ = conn.AddMatchSignal(
+		dbus.WithMatchObjectPath(unitPath),
+		dbus.WithMatchInterface("org.freedesktop.DBus.Properties"),
+		dbus.WithMatchMember("PropertiesChanged"))
+	_ = conn.AddMatchSignal(
+		dbus.WithMatchObjectPath(systemdPath),
+		dbus.WithMatchInterface("org.freedesktop.systemd1.Manager"),
+		dbus.WithMatchMember("UnitRemoved"))
+
+	signals := make(chan *dbus.Signal, 16)
+	conn.Signal(signals)
+	// Ask systemd to emit the signals.
+	if err := conn.Object(systemdBusName, systemdPath).Call("org.freedesktop.systemd1.Manager.Subscribe", 0).Err; err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("unable to subscribe to systemd signals: %w", err)
+	}
+
+	events := make(chan UnitEvent)
+	go func() {
+		defer close(events)
+		defer conn.Close()
+		stopped := false
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case sig, ok := <-signals:
+				if !ok {
+					return
+				}
+				ev, ok := unitEventFromSignal(sig, unitName, unitPath)
+				if !ok || (ev.Type == UnitStopped && stopped) {
+					continue
+				}
+				if ev.Type == UnitStopped {
+					stopped = true
+				}
+				select {
+				case events <- ev:
+				case <-ctx.Done():
+					return
+				}
+			}
+		}
+	}()
+
+	return events, nil
+}
+
+// unitEventFromSignal converts a dbus signal to an event for the unit, if
+// the signal is about the unit and is of interest.
+func unitEventFromSignal(sig *dbus.Signal, unitName string, unitPath dbus.ObjectPath) (UnitEvent, bool) {
+	ev := UnitEvent{Unit: unitName}
+	switch sig.Name {
+	case "org.freedesktop.systemd1.Manager.UnitRemoved":
+		if len(sig.Body) < 1 {
+			break
+		}
+		if id, _ := sig.Body[0].(string); id == unitName {
+			ev.Type = UnitRemoved
+			return ev, true
+		}
+	case "org.freedesktop.DBus.Properties.PropertiesChanged":
+		if sig.Path != unitPath || len(sig.Body) < 2 {
+			break
+		}
+		iface, _ := sig.Body[0].(string)
+		changed, _ := sig.Body[1].(map[string]dbus.Variant)
+		switch iface {
+		case "org.freedesktop.systemd1.Unit":
+			if v, ok := changed["ActiveState"]; ok {
+				switch state, _ := v.Value().(string); state {
+				case "deactivating", "inactive", "failed":
+					ev.Type = UnitStopped
+					return ev, true
+				}
+			}
+		case "org.freedesktop.systemd1.Scope", "org.freedesktop.systemd1.Service":
+			if v, ok := changed["Result"]; ok {
+				if result, _ := v.Value().(string); result == "oom-kill" {
+					ev.Type = UnitOOMKilled
+					return ev, true
+				}
+			}
+		}
+	}
+	return ev, false
+}
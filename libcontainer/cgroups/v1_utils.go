@@ -25,6 +25,7 @@ const (
 var (
 	errUnified     = errors.New("not implemented for cgroup v2 unified hierarchy")
 	ErrV1NoUnified = errors.New("invalid configuration: cannot use unified on cgroup v1")
+	ErrV1NoReclaim = errors.New("memory reclaim is not supported on cgroup v1")
 
 	readMountinfoOnce sync.Once
 	readMountinfoErr  error
@@ -1,7 +1,6 @@
 package configs
 
 import (
-	systemdDbus "github.com/coreos/go-systemd/v22/dbus"
 	"github.com/opencontainers/runc/libcontainer/devices"
 )
 
@@ -37,7 +36,41 @@ type Cgroup struct {
 	// SystemdProps are any additional properties for systemd,
 	// derived from org.systemd.property.xxx annotations.
 	// Ignored unless systemd is used for managing cgroups.
-	SystemdProps []systemdDbus.Property `json:"-"`
+	SystemdProps []SystemdProperty `json:"systemd_props,omitempty"`
+
+	// DelegateControllers is the list of controllers to delegate to the
+	// container scope (for example, "cpu", "memory" and "pids"). If empty,
+	// all the controllers are delegated. If set, resources can only be set
+	// for the delegated controllers. Only applicable to systemd cgroup
+	// driver.
+	DelegateControllers []string `json:"delegate_controllers,omitempty"`
+
+	// ManagedOOM configures systemd-oomd policies for the container unit.
+	// Only applicable to systemd cgroup driver on cgroup v2.
+	ManagedOOM *ManagedOOM `json:"managed_oom,omitempty"`
+
+	// UnitStop configures how systemd stops the container unit (for
+	// example, on host shutdown or systemctl stop). Ignored unless
+	// systemd is used for managing cgroups.
+	UnitStop *UnitStop `json:"unit_stop,omitempty"`
+
+	// StartAsync tells not to wait for systemd to finish starting the
+	// container unit in Apply, which reduces the container start latency.
+	// The process is still moved into the container cgroup by Apply.
+	// A failure to start the unit is returned by the next Set, which waits
+	// for the unit start job to finish. Only applicable to systemd cgroup
+	// driver on cgroup v2.
+	StartAsync bool `json:"start_async,omitempty"`
+
+	// ExistingUnit tells that the systemd unit for the container (as
+	// derived from Parent, ScopePrefix and Name) is created and owned by
+	// an external manager. Instead of starting a transient unit, the
+	// container processes are attached to the existing unit, and the unit
+	// is neither stopped nor removed by Destroy. SystemdProps are ignored,
+	// and resources are only set via cgroupfs, not as unit properties.
+	// Requires systemd v238 or later. Ignored unless systemd is used for
+	// managing cgroups.
+	ExistingUnit bool `json:"existing_unit,omitempty"`
 
 	// Rootless tells if rootless cgroups should be used.
 	Rootless bool
@@ -48,6 +81,41 @@ type Cgroup struct {
 	// Not all cgroup manager implementations support changing
 	// the ownership.
 	OwnerUID *int `json:"owner_uid,omitempty"`
+
+	// Threaded tells if the container cgroup should be put into threaded
+	// mode (cgroup.type=threaded). Only thread-aware controllers (such as
+	// cpu, cpuset and pids) can be used in a threaded cgroup. Only supported
+	// on cgroup v2 with the fs driver.
+	Threaded bool `json:"threaded,omitempty"`
+}
+
+// ManagedOOM holds systemd-oomd settings, see systemd.resource-control(5).
+// Empty values mean the systemd defaults are used.
+type ManagedOOM struct {
+	// MemoryPressure is the action taken when the memory pressure of the
+	// unit exceeds the limit ("auto" or "kill").
+	MemoryPressure string `json:"memory_pressure,omitempty"`
+	// MemoryPressureLimit is the memory pressure limit, in permyriad
+	// (hundredths of a percent, 0 to 10000). Zero means systemd-oomd
+	// default limit.
+	MemoryPressureLimit uint32 `json:"memory_pressure_limit,omitempty"`
+	// Swap is the action taken when the system swap usage exceeds the
+	// systemd-oomd limit ("auto" or "kill").
+	Swap string `json:"swap,omitempty"`
+}
+
+// UnitStop holds settings for stopping a systemd unit, see systemd.kill(5)
+// and systemd.scope(5). Empty values mean the systemd defaults are used.
+type UnitStop struct {
+	// TimeoutUSec is the time (in microseconds) to wait for the unit
+	// processes to exit after sending them SIGTERM.
+	TimeoutUSec uint64 `json:"timeout_usec,omitempty"`
+	// KillMode specifies which processes are killed ("control-group",
+	// "mixed", "process" or "none").
+	KillMode string `json:"kill_mode,omitempty"`
+	// SendSIGKILL tells whether to send SIGKILL to the remaining
+	// processes after the timeout.
+	SendSIGKILL *bool `json:"send_sigkill,omitempty"`
 }
 
 type Resources struct {
@@ -84,6 +152,10 @@ type Resources struct {
 	// MEM to use
 	CpusetMems string `json:"cpuset_mems"`
 
+	// Cpuset partition type ("member", "root", or "isolated"),
+	// written to cpuset.cpus.partition. Used on cgroup v2 only.
+	CpusetPartition string `json:"cpuset_partition,omitempty"`
+
 	// Process limit; set <= `0' to disable limit.
 	PidsLimit int64 `json:"pids_limit"`
 
@@ -120,6 +192,10 @@ type Resources struct {
 	// Tuning swappiness behaviour per cgroup
 	MemorySwappiness *uint64 `json:"memory_swappiness"`
 
+	// Limit of compressed swap (zswap) usage (in bytes); set `-1` for no limit.
+	// Used on cgroup v2 only, ignored if the kernel does not support it.
+	MemoryZswapMax *int64 `json:"memory_zswap_max,omitempty"`
+
 	// Set priority of network traffic for container
 	NetPrioIfpriomap []*IfPrioMap `json:"net_prio_ifpriomap"`
 
@@ -134,6 +210,19 @@ type Resources struct {
 	// CpuWeight sets a proportional bandwidth limit.
 	CpuWeight uint64 `json:"cpu_weight"`
 
+	// CpuUclampMin and CpuUclampMax set the utilization clamping range
+	// (cpu.uclamp.min and cpu.uclamp.max), as a percentage with up to two
+	// decimal places (e.g. "20.50"). CpuUclampMax also accepts "max".
+	CpuUclampMin string `json:"cpu_uclamp_min,omitempty"`
+	CpuUclampMax string `json:"cpu_uclamp_max,omitempty"`
+
+	// IO latency targets per device (io.latency).
+	BlkioLatencyDevice []*LatencyDevice `json:"blkio_latency_device,omitempty"`
+
+	// Misc is a map of misc controller resource names (such as "sgx_epc")
+	// to their limits, written to misc.max. Used on cgroup v2 only.
+	Misc map[string]uint64 `json:"misc,omitempty"`
+
 	// Unified is cgroupv2-only key-value map.
 	Unified map[string]string `json:"unified"`
 
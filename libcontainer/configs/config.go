@@ -26,6 +26,27 @@ type IDMap struct {
 	Size        int `json:"size"`
 }
 
+// MaskMode is the way a path in Config.MaskPaths is masked.
+type MaskMode string
+
+const (
+	// MaskDefault masks a file by bind mounting /dev/null over it,
+	// and a directory by mounting a read-only tmpfs over it.
+	MaskDefault MaskMode = ""
+	// MaskTmpfs masks a directory with a read-only tmpfs.
+	MaskTmpfs MaskMode = "tmpfs"
+	// MaskReadonly does not hide the path contents, but makes the path
+	// read-only (recursively), the same as Config.ReadonlyPaths.
+	MaskReadonly MaskMode = "readonly"
+)
+
+// TimeOffset is the offset of a clock in a time namespace
+// relative to the same clock of the host.
+type TimeOffset struct {
+	Secs     int64  `json:"secs"`
+	Nanosecs uint32 `json:"nanosecs"`
+}
+
 // Seccomp represents syscall restrictions
 // By default, only the native architecture of the kernel is allowed to be used
 // for syscalls. Additional architectures can be added by specifying them in
@@ -165,8 +186,12 @@ type Config struct {
 	// mount pointing to /dev/null as to prevent reads of the file.
 	MaskPaths []string `json:"mask_paths"`
 
+	// MaskPathModes specifies how some of the MaskPaths are masked, by path.
+	// The paths which are not in it are masked using MaskDefault.
+	MaskPathModes map[string]MaskMode `json:"mask_path_modes,omitempty"`
+
 	// ReadonlyPaths specifies paths within the container's rootfs to remount as read-only
-	// so that these files prevent any writes.
+	// (recursively, if supported by the kernel) so that these files prevent any writes.
 	ReadonlyPaths []string `json:"readonly_paths"`
 
 	// Sysctl is a map of properties and their values. It is the equivalent of using
@@ -208,6 +233,51 @@ type Config struct {
 	// RootlessCgroups is set when unlikely to have the full access to cgroups.
 	// When RootlessCgroups is set, cgroups errors are ignored.
 	RootlessCgroups bool `json:"rootless_cgroups,omitempty"`
+
+	// Scheduler specifies the scheduling policy and attributes of the
+	// container processes. If it is unset, they are inherited from runc.
+	Scheduler *Scheduler `json:"scheduler,omitempty"`
+
+	// IOPriority specifies the I/O scheduling class and priority of the
+	// container processes. If it is unset, they are inherited from runc.
+	IOPriority *IOPriority `json:"io_priority,omitempty"`
+
+	// MemoryPolicy specifies the NUMA memory policy of the container
+	// processes. If it is unset, it is inherited from runc.
+	MemoryPolicy *MemoryPolicy `json:"memory_policy,omitempty"`
+
+	// ExecCPUAffinity specifies the CPU affinity of the processes executed
+	// in the running container (runc exec). It does not apply to the init.
+	ExecCPUAffinity *CPUAffinity `json:"exec_cpu_affinity,omitempty"`
+
+	// CoreSched specifies the core scheduling cookie of the container
+	// processes. If it is unset, the cookie is inherited from runc.
+	CoreSched *CoreSched `json:"core_sched,omitempty"`
+
+	// Personality contains configuration for the Linux personality syscall.
+	Personality *LinuxPersonality `json:"personality,omitempty"`
+
+	// TimeOffsets specifies the offsets of the clocks in a new time namespace,
+	// keyed by the clock name ("monotonic" or "boottime").
+	TimeOffsets map[string]TimeOffset `json:"time_offsets,omitempty"`
+}
+
+const (
+	// PerLinux is the standard Linux execution domain.
+	PerLinux = 0x0000
+	// PerLinux32 is the Linux execution domain for 32-bit userlands,
+	// making uname(2) report a 32-bit machine.
+	PerLinux32 = 0x0008
+)
+
+// LinuxPersonality is the Linux execution domain of the container
+// processes, see personality(2).
+type LinuxPersonality struct {
+	// Domain is the execution domain, PerLinux or PerLinux32.
+	Domain int `json:"domain"`
+
+	// Flags are additional personality flags.
+	Flags []int `json:"flags,omitempty"`
 }
 
 type (
@@ -275,8 +345,27 @@ type Capabilities struct {
 }
 
 func (hooks HookList) RunHooks(state *specs.State) error {
+	return hooks.run("", state)
+}
+
+// Run executes all hooks of the given stage, stopping at the first error.
+// Command hooks output is logged (at debug level) along with the stage.
+func (hooks Hooks) Run(name HookName, state *specs.State) error {
+	return hooks[name].run(name, state)
+}
+
+func (hooks HookList) run(name HookName, state *specs.State) error {
 	for i, h := range hooks {
-		if err := h.Run(state); err != nil {
+		var err error
+		if ch, ok := h.(CommandHook); ok {
+			err = ch.run(name, state)
+		} else {
+			err = h.Run(state)
+		}
+		if err != nil {
+			if name != "" {
+				return fmt.Errorf("error running %s hook #%d: %w", name, i, err)
+			}
 			return fmt.Errorf("error running hook #%d: %w", i, err)
 		}
 	}
@@ -370,6 +459,10 @@ type CommandHook struct {
 }
 
 func (c Command) Run(s *specs.State) error {
+	return c.run("", s)
+}
+
+func (c Command) run(stage HookName, s *specs.State) error {
 	b, err := json.Marshal(s)
 	if err != nil {
 		return err
@@ -388,11 +481,7 @@ func (c Command) Run(s *specs.State) error {
 	}
 	errC := make(chan error, 1)
 	go func() {
-		err := cmd.Wait()
-		if err != nil {
-			err = fmt.Errorf("error running hook: %w, stdout: %s, stderr: %s", err, stdout.String(), stderr.String())
-		}
-		errC <- err
+		errC <- cmd.Wait()
 	}()
 	var timerCh <-chan time.Time
 	if c.Timeout != nil {
@@ -401,11 +490,26 @@ func (c Command) Run(s *specs.State) error {
 		timerCh = timer.C
 	}
 	select {
-	case err := <-errC:
-		return err
+	case err = <-errC:
+		if err != nil {
+			err = fmt.Errorf("error running hook: %w, stdout: %s, stderr: %s", err, stdout.String(), stderr.String())
+		}
 	case <-timerCh:
 		_ = cmd.Process.Kill()
-		<-errC
-		return fmt.Errorf("hook ran past specified timeout of %.1fs", c.Timeout.Seconds())
+		err = fmt.Errorf("hook ran past specified timeout of %.1fs", c.Timeout.Seconds())
+		select {
+		case <-errC:
+			err = fmt.Errorf("%w, stdout: %s, stderr: %s", err, stdout.String(), stderr.String())
+		case <-time.After(time.Second):
+			// The hook children (if any) still have its stdout
+			// and/or stderr open, so the output can't be read.
+		}
+	}
+	if err == nil && (stdout.Len() > 0 || stderr.Len() > 0) {
+		logrus.WithFields(logrus.Fields{
+			"hook":  c.Path,
+			"stage": stage,
+		}).Debugf("hook output: stdout: %s, stderr: %s", stdout.String(), stderr.String())
 	}
+	return err
 }
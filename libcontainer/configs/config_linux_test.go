@@ -18,6 +18,22 @@ func TestRemoveNamespace(t *testing.T) {
 	}
 }
 
+func TestNamespaceIsPrivate(t *testing.T) {
+	ns := Namespaces{
+		{Type: NEWPID},
+		{Type: NEWNET, Path: "/proc/1/ns/net"},
+	}
+	if !ns.IsPrivate(NEWPID) {
+		t.Error("NEWPID should be private")
+	}
+	if ns.IsPrivate(NEWNET) {
+		t.Error("NEWNET should not be private, as it is shared")
+	}
+	if ns.IsPrivate(NEWIPC) {
+		t.Error("NEWIPC should not be private, as it is not configured")
+	}
+}
+
 func TestHostRootUIDNoUSERNS(t *testing.T) {
 	config := &Config{
 		Namespaces: Namespaces{},
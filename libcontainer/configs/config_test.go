@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"os"
 	"reflect"
+	"strings"
 	"testing"
 	"time"
 
@@ -226,3 +227,37 @@ func TestCommandHookRunTimeout(t *testing.T) {
 		t.Error("Expected error to occur but it was nil")
 	}
 }
+
+func TestHooksRunStage(t *testing.T) {
+	state := &specs.State{
+		Version: "1",
+		ID:      "1",
+		Status:  "created",
+		Pid:     1,
+		Bundle:  "/bundle",
+	}
+	timeout := 100 * time.Millisecond
+
+	hooks := configs.Hooks{
+		configs.CreateRuntime: configs.HookList{configs.NewCommandHook(configs.Command{
+			Path:    "/bin/sh",
+			Args:    []string{"/bin/sh", "-c", "echo waiting; exec sleep 1"},
+			Timeout: &timeout,
+		})},
+	}
+
+	err := hooks.Run(configs.CreateRuntime, state)
+	if err == nil {
+		t.Fatal("Expected error to occur but it was nil")
+	}
+	for _, s := range []string{"createRuntime hook #0", "timeout", "waiting"} {
+		if !strings.Contains(err.Error(), s) {
+			t.Errorf("Expected error to contain %q, got %q", s, err)
+		}
+	}
+
+	// No hooks for the stage is not an error.
+	if err := hooks.Run(configs.Poststop, state); err != nil {
+		t.Errorf("Expected error to not occur but it was %+v", err)
+	}
+}
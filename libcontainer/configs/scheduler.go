@@ -0,0 +1,74 @@
+package configs
+
+// Scheduler is the scheduling policy and attributes of a process,
+// as set by sched_setattr(2).
+type Scheduler struct {
+	// Policy is the scheduling policy, such as "SCHED_OTHER", "SCHED_FIFO",
+	// or "SCHED_DEADLINE".
+	Policy string `json:"policy"`
+
+	// Nice is the nice value of the process, for SCHED_OTHER and SCHED_BATCH.
+	Nice int32 `json:"nice,omitempty"`
+
+	// Priority is the static priority of the process, for SCHED_FIFO and
+	// SCHED_RR.
+	Priority int32 `json:"priority,omitempty"`
+
+	// Flags are the scheduling flags, such as "SCHED_FLAG_RESET_ON_FORK".
+	Flags []string `json:"flags,omitempty"`
+
+	// Runtime, Deadline, and Period are the SCHED_DEADLINE parameters,
+	// in nanoseconds.
+	Runtime  uint64 `json:"runtime,omitempty"`
+	Deadline uint64 `json:"deadline,omitempty"`
+	Period   uint64 `json:"period,omitempty"`
+}
+
+// IOPriority is the I/O scheduling class and priority of a process,
+// as set by ioprio_set(2).
+type IOPriority struct {
+	// Class is the I/O scheduling class, one of "IOPRIO_CLASS_RT",
+	// "IOPRIO_CLASS_BE", or "IOPRIO_CLASS_IDLE".
+	Class string `json:"class"`
+
+	// Priority is the priority level within the class, from 0 (highest)
+	// to 7 (lowest).
+	Priority int `json:"priority,omitempty"`
+}
+
+// CoreSched configures core scheduling for the container processes, so that
+// they never share an SMT core with processes of other containers (see
+// Documentation/admin-guide/hw-vuln/core-scheduling.rst in the kernel tree).
+type CoreSched struct {
+	// SharePid is the PID of a process to share the core scheduling cookie
+	// with, for example the init of another container in the same pod.
+	// If it is 0, a new cookie is created for the container.
+	SharePid int `json:"share_pid,omitempty"`
+}
+
+// CPUAffinity is the CPU affinity of a process executed in a running
+// container, in the cpuset list format (e.g. "0-3,7").
+type CPUAffinity struct {
+	// Initial is the affinity of the process before it joins the
+	// container cgroup. If it is empty, the affinity of runc is used.
+	Initial string `json:"initial,omitempty"`
+
+	// Final is the affinity of the process once it has joined the
+	// container cgroup. If it is empty, it is set by the kernel from
+	// the cgroup cpuset.
+	Final string `json:"final,omitempty"`
+}
+
+// MemoryPolicy is the NUMA memory policy of the container processes, as
+// set by set_mempolicy(2).
+type MemoryPolicy struct {
+	// Mode is the policy mode, e.g. "MPOL_BIND" or "MPOL_INTERLEAVE".
+	Mode string `json:"mode"`
+
+	// Nodes is the list of NUMA nodes in the cpuset list format
+	// (e.g. "0-1,3"), as for cpuset.mems.
+	Nodes string `json:"nodes,omitempty"`
+
+	// Flags are the mode flags, e.g. "MPOL_F_STATIC_NODES".
+	Flags []string `json:"flags,omitempty"`
+}
@@ -0,0 +1,183 @@
+package configs
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unsafe"
+
+	"golang.org/x/sys/unix"
+)
+
+// SchedAttr is the argument of sched_setattr(2), i.e. struct sched_attr
+// from include/uapi/linux/sched/types.h (without the utilization clamping
+// fields, which are not supported, so neither are the
+// SCHED_FLAG_UTIL_CLAMP_* flags).
+type SchedAttr struct {
+	Size     uint32
+	Policy   uint32
+	Flags    uint64
+	Nice     int32
+	Priority uint32
+	Runtime  uint64
+	Deadline uint64
+	Period   uint64
+}
+
+var schedPolicies = map[string]uint32{
+	"SCHED_OTHER":    0,
+	"SCHED_FIFO":     1,
+	"SCHED_RR":       2,
+	"SCHED_BATCH":    3,
+	"SCHED_IDLE":     5,
+	"SCHED_DEADLINE": 6,
+}
+
+var schedFlags = map[string]uint64{
+	"SCHED_FLAG_RESET_ON_FORK": 0x01,
+	"SCHED_FLAG_RECLAIM":       0x02,
+	"SCHED_FLAG_DL_OVERRUN":    0x04,
+	"SCHED_FLAG_KEEP_POLICY":   0x08,
+	"SCHED_FLAG_KEEP_PARAMS":   0x10,
+}
+
+// ToSchedAttr converts s to the sched_setattr(2) argument.
+func (s *Scheduler) ToSchedAttr() (*SchedAttr, error) {
+	policy, ok := schedPolicies[s.Policy]
+	if !ok {
+		return nil, fmt.Errorf("invalid scheduler policy %q", s.Policy)
+	}
+	var flags uint64
+	for _, f := range s.Flags {
+		v, ok := schedFlags[f]
+		if !ok {
+			return nil, fmt.Errorf("invalid scheduler flag %q", f)
+		}
+		flags |= v
+	}
+	return &SchedAttr{
+		Size:     uint32(unsafe.Sizeof(SchedAttr{})),
+		Policy:   policy,
+		Flags:    flags,
+		Nice:     s.Nice,
+		Priority: uint32(s.Priority),
+		Runtime:  s.Runtime,
+		Deadline: s.Deadline,
+		Period:   s.Period,
+	}, nil
+}
+
+var ioprioClasses = map[string]int{
+	"IOPRIO_CLASS_RT":   1,
+	"IOPRIO_CLASS_BE":   2,
+	"IOPRIO_CLASS_IDLE": 3,
+}
+
+const ioprioClassShift = 13
+
+// ToIOPrio converts p to the ioprio_set(2) argument.
+func (p *IOPriority) ToIOPrio() (int, error) {
+	class, ok := ioprioClasses[p.Class]
+	if !ok {
+		return 0, fmt.Errorf("invalid io priority class %q", p.Class)
+	}
+	if p.Priority < 0 || p.Priority > 7 {
+		return 0, fmt.Errorf("io priority %d out of range [0, 7]", p.Priority)
+	}
+	return class<<ioprioClassShift | p.Priority, nil
+}
+
+// ToCPUSet parses a CPU list (e.g. "0-3,7") into a CPU set. The result
+// is nil for an empty list.
+func ToCPUSet(list string) (*unix.CPUSet, error) {
+	if list == "" {
+		return nil, nil
+	}
+	var set unix.CPUSet
+	err := parseList(list, len(set)*64, set.Set)
+	if err != nil {
+		return nil, fmt.Errorf("invalid cpu list: %w", err)
+	}
+	return &set, nil
+}
+
+// parseList parses a list of IDs in the cpuset list format (e.g. "0-3,7"),
+// calling set for every ID, which must be less than max.
+func parseList(list string, max int, set func(int)) error {
+	for _, r := range strings.Split(list, ",") {
+		first, last := r, r
+		if i := strings.IndexByte(r, '-'); i >= 0 {
+			first, last = r[:i], r[i+1:]
+		}
+		start, err := strconv.ParseUint(first, 10, 32)
+		if err != nil {
+			return fmt.Errorf("%q: %w", list, err)
+		}
+		end, err := strconv.ParseUint(last, 10, 32)
+		if err != nil {
+			return fmt.Errorf("%q: %w", list, err)
+		}
+		if start > end || end >= uint64(max) {
+			return fmt.Errorf("%q: bad range %q", list, r)
+		}
+		for id := start; id <= end; id++ {
+			set(int(id))
+		}
+	}
+	return nil
+}
+
+var mpolModes = map[string]int{
+	"MPOL_DEFAULT":             0,
+	"MPOL_PREFERRED":           1,
+	"MPOL_BIND":                2,
+	"MPOL_INTERLEAVE":          3,
+	"MPOL_LOCAL":               4,
+	"MPOL_PREFERRED_MANY":      5,
+	"MPOL_WEIGHTED_INTERLEAVE": 6,
+}
+
+var mpolFlags = map[string]int{
+	"MPOL_F_NUMA_BALANCING": 1 << 13,
+	"MPOL_F_RELATIVE_NODES": 1 << 14,
+	"MPOL_F_STATIC_NODES":   1 << 15,
+}
+
+// NodeMask is a NUMA node bit mask, with room for MAX_NUMNODES (1024) nodes.
+type NodeMask [1024 / 64]uint64
+
+// ToMempolicy converts p to the set_mempolicy(2) mode (including the flags)
+// and node mask arguments.
+func (p *MemoryPolicy) ToMempolicy() (int, *NodeMask, error) {
+	mode, ok := mpolModes[p.Mode]
+	if !ok {
+		return 0, nil, fmt.Errorf("invalid memory policy mode %q", p.Mode)
+	}
+	for _, f := range p.Flags {
+		flag, ok := mpolFlags[f]
+		if !ok {
+			return 0, nil, fmt.Errorf("invalid memory policy flag %q", f)
+		}
+		mode |= flag
+	}
+	var nodes NodeMask
+	if p.Nodes != "" {
+		err := parseList(p.Nodes, len(nodes)*64, func(n int) {
+			nodes[n/64] |= 1 << (uint(n) % 64)
+		})
+		if err != nil {
+			return 0, nil, fmt.Errorf("invalid memory policy nodes: %w", err)
+		}
+	}
+	switch p.Mode {
+	case "MPOL_DEFAULT", "MPOL_LOCAL":
+		if p.Nodes != "" {
+			return 0, nil, fmt.Errorf("memory policy mode %s does not take nodes", p.Mode)
+		}
+	case "MPOL_BIND", "MPOL_INTERLEAVE", "MPOL_PREFERRED_MANY", "MPOL_WEIGHTED_INTERLEAVE":
+		if p.Nodes == "" {
+			return 0, nil, fmt.Errorf("memory policy mode %s requires nodes", p.Mode)
+		}
+	}
+	return mode, &nodes, nil
+}
@@ -0,0 +1,49 @@
+package configs
+
+import (
+	"testing"
+
+	"golang.org/x/sys/unix"
+)
+
+func TestToCPUSet(t *testing.T) {
+	for _, tc := range []struct {
+		list  string
+		cpus  []int
+		isErr bool
+	}{
+		{list: ""},
+		{list: "0", cpus: []int{0}},
+		{list: "0-3,7", cpus: []int{0, 1, 2, 3, 7}},
+		{list: "2,4-5,5", cpus: []int{2, 4, 5}},
+		{list: "3-1", isErr: true},
+		{list: "1,", isErr: true},
+		{list: "a-b", isErr: true},
+		{list: "0-1024", isErr: true},
+	} {
+		set, err := ToCPUSet(tc.list)
+		if tc.isErr {
+			if err == nil {
+				t.Errorf("%q: expected error, got nil", tc.list)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%q: unexpected error: %v", tc.list, err)
+			continue
+		}
+		if tc.cpus == nil {
+			if set != nil {
+				t.Errorf("%q: expected nil, got %v", tc.list, set)
+			}
+			continue
+		}
+		var exp unix.CPUSet
+		for _, c := range tc.cpus {
+			exp.Set(c)
+		}
+		if *set != exp {
+			t.Errorf("%q: expected %v, got %v", tc.list, exp, *set)
+		}
+	}
+}
@@ -0,0 +1,336 @@
+package configs
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"strconv"
+
+	dbus "github.com/godbus/dbus/v5"
+)
+
+// SystemdProperty is an additional property for the systemd unit
+// created for the container.
+type SystemdProperty struct {
+	// Name is the unit property name, such as "TimeoutStopUSec".
+	Name string
+	// Value is the property value and its dbus type.
+	Value dbus.Variant
+	// Runtime tells that the property can be changed for a running unit,
+	// so it is applied not only when the unit is started, but also every
+	// time the container resources are set or updated.
+	Runtime bool
+}
+
+// systemdPropertyJSON is the serialized form of SystemdProperty.
+// Value is encoded according to the signature, with dbus structs
+// represented as JSON arrays and dicts as JSON objects.
+type systemdPropertyJSON struct {
+	Name      string          `json:"name"`
+	Signature string          `json:"signature"`
+	Value     json.RawMessage `json:"value"`
+	Runtime   bool            `json:"runtime,omitempty"`
+}
+
+var dbusSignatureType = reflect.TypeOf(dbus.Signature{})
+
+// Validate checks that the property has a name and a value of a type which
+// is supported, i.e. can be both sent to systemd and saved in the state.
+func (p SystemdProperty) Validate() error {
+	if p.Name == "" {
+		return errors.New("systemd property with no name")
+	}
+	sig := p.Value.Signature().String()
+	if sig == "" {
+		return fmt.Errorf("systemd property %s: no value", p.Name)
+	}
+	if _, err := typeForSignature(sig); err != nil {
+		return fmt.Errorf("systemd property %s: %w", p.Name, err)
+	}
+	return nil
+}
+
+func (p SystemdProperty) MarshalJSON() ([]byte, error) {
+	value, err := json.Marshal(toJSONValue(reflect.ValueOf(p.Value.Value())))
+	if err != nil {
+		return nil, fmt.Errorf("systemd property %s: %w", p.Name, err)
+	}
+	return json.Marshal(systemdPropertyJSON{
+		Name:      p.Name,
+		Signature: p.Value.Signature().String(),
+		Value:     value,
+		Runtime:   p.Runtime,
+	})
+}
+
+func (p *SystemdProperty) UnmarshalJSON(data []byte) error {
+	var pj systemdPropertyJSON
+	if err := json.Unmarshal(data, &pj); err != nil {
+		return err
+	}
+	t, err := typeForSignature(pj.Signature)
+	if err != nil {
+		return fmt.Errorf("systemd property %s: %w", pj.Name, err)
+	}
+	var raw interface{}
+	dec := json.NewDecoder(bytes.NewReader(pj.Value))
+	dec.UseNumber()
+	if err := dec.Decode(&raw); err != nil {
+		return fmt.Errorf("systemd property %s: %w", pj.Name, err)
+	}
+	v := reflect.New(t).Elem()
+	if err := fromJSONValue(v, raw); err != nil {
+		return fmt.Errorf("systemd property %s: %w", pj.Name, err)
+	}
+	sig, err := dbus.ParseSignature(pj.Signature)
+	if err != nil {
+		return fmt.Errorf("systemd property %s: %w", pj.Name, err)
+	}
+
+	*p = SystemdProperty{
+		Name:    pj.Name,
+		Value:   dbus.MakeVariantWithSignature(v.Interface(), sig),
+		Runtime: pj.Runtime,
+	}
+	return nil
+}
+
+// typeForSignature returns a Go type which can hold dbus values of the
+// single complete type sig. Variants and file descriptors are not supported.
+func typeForSignature(sig string) (reflect.Type, error) {
+	t, rest, err := parseSignature(sig)
+	if err != nil {
+		return nil, err
+	}
+	if rest != "" {
+		return nil, fmt.Errorf("signature %q: not a single complete type", sig)
+	}
+	return t, nil
+}
+
+func parseSignature(sig string) (reflect.Type, string, error) {
+	if sig == "" {
+		return nil, "", errors.New("incomplete signature")
+	}
+	switch c, rest := sig[0], sig[1:]; c {
+	case 'y':
+		return reflect.TypeOf(uint8(0)), rest, nil
+	case 'b':
+		return reflect.TypeOf(false), rest, nil
+	case 'n':
+		return reflect.TypeOf(int16(0)), rest, nil
+	case 'q':
+		return reflect.TypeOf(uint16(0)), rest, nil
+	case 'i':
+		return reflect.TypeOf(int32(0)), rest, nil
+	case 'u':
+		return reflect.TypeOf(uint32(0)), rest, nil
+	case 'x':
+		return reflect.TypeOf(int64(0)), rest, nil
+	case 't':
+		return reflect.TypeOf(uint64(0)), rest, nil
+	case 'd':
+		return reflect.TypeOf(float64(0)), rest, nil
+	case 's':
+		return reflect.TypeOf(""), rest, nil
+	case 'o':
+		return reflect.TypeOf(dbus.ObjectPath("")), rest, nil
+	case 'g':
+		return dbusSignatureType, rest, nil
+	case 'a':
+		if rest != "" && rest[0] == '{' {
+			key, r, err := parseSignature(rest[1:])
+			if err != nil {
+				return nil, "", err
+			}
+			if key.Kind() == reflect.Struct || key.Kind() == reflect.Slice || key.Kind() == reflect.Map {
+				return nil, "", fmt.Errorf("dict key must be a basic type, got %s", key)
+			}
+			val, r, err := parseSignature(r)
+			if err != nil {
+				return nil, "", err
+			}
+			if r == "" || r[0] != '}' {
+				return nil, "", errors.New("unterminated dict entry in signature")
+			}
+			return reflect.MapOf(key, val), r[1:], nil
+		}
+		elem, r, err := parseSignature(rest)
+		if err != nil {
+			return nil, "", err
+		}
+		return reflect.SliceOf(elem), r, nil
+	case '(':
+		var fields []reflect.StructField
+		for rest != "" && rest[0] != ')' {
+			var (
+				t   reflect.Type
+				err error
+			)
+			t, rest, err = parseSignature(rest)
+			if err != nil {
+				return nil, "", err
+			}
+			fields = append(fields, reflect.StructField{Name: "F" + strconv.Itoa(len(fields)), Type: t})
+		}
+		if rest == "" || len(fields) == 0 {
+			return nil, "", errors.New("bad struct in signature")
+		}
+		return reflect.StructOf(fields), rest[1:], nil
+	default:
+		return nil, "", fmt.Errorf("unsupported type %q in signature", c)
+	}
+}
+
+// toJSONValue converts a dbus value into a form suitable for json.Marshal.
+func toJSONValue(v reflect.Value) interface{} {
+	switch v.Kind() {
+	case reflect.Struct:
+		if v.Type() == dbusSignatureType {
+			return v.Interface().(dbus.Signature).String()
+		}
+		var fields []interface{}
+		for i := 0; i < v.NumField(); i++ {
+			if v.Type().Field(i).PkgPath == "" { // exported
+				fields = append(fields, toJSONValue(v.Field(i)))
+			}
+		}
+		return fields
+	case reflect.Slice, reflect.Array:
+		elems := make([]interface{}, v.Len())
+		for i := range elems {
+			elems[i] = toJSONValue(v.Index(i))
+		}
+		return elems
+	case reflect.Map:
+		m := make(map[string]interface{}, v.Len())
+		iter := v.MapRange()
+		for iter.Next() {
+			m[fmt.Sprint(iter.Key().Interface())] = toJSONValue(iter.Value())
+		}
+		return m
+	case reflect.Invalid:
+		return nil
+	}
+	return v.Interface()
+}
+
+// fromJSONValue sets v (which type is produced by typeForSignature)
+// from raw, which is decoded from JSON using json.Decoder.UseNumber.
+func fromJSONValue(v reflect.Value, raw interface{}) error {
+	errType := func() error {
+		return fmt.Errorf("can't use %v (%T) as %s", raw, raw, v.Type())
+	}
+	switch v.Kind() {
+	case reflect.Bool:
+		b, ok := raw.(bool)
+		if !ok {
+			return errType()
+		}
+		v.SetBool(b)
+	case reflect.Int16, reflect.Int32, reflect.Int64:
+		n, ok := raw.(json.Number)
+		if !ok {
+			return errType()
+		}
+		i, err := strconv.ParseInt(string(n), 10, v.Type().Bits())
+		if err != nil {
+			return err
+		}
+		v.SetInt(i)
+	case reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		n, ok := raw.(json.Number)
+		if !ok {
+			return errType()
+		}
+		u, err := strconv.ParseUint(string(n), 10, v.Type().Bits())
+		if err != nil {
+			return err
+		}
+		v.SetUint(u)
+	case reflect.Float64:
+		n, ok := raw.(json.Number)
+		if !ok {
+			return errType()
+		}
+		f, err := n.Float64()
+		if err != nil {
+			return err
+		}
+		v.SetFloat(f)
+	case reflect.String:
+		s, ok := raw.(string)
+		if !ok {
+			return errType()
+		}
+		v.SetString(s)
+	case reflect.Struct:
+		if v.Type() == dbusSignatureType {
+			s, ok := raw.(string)
+			if !ok {
+				return errType()
+			}
+			sig, err := dbus.ParseSignature(s)
+			if err != nil {
+				return err
+			}
+			v.Set(reflect.ValueOf(sig))
+			return nil
+		}
+		fields, ok := raw.([]interface{})
+		if !ok || len(fields) != v.NumField() {
+			return errType()
+		}
+		for i, f := range fields {
+			if err := fromJSONValue(v.Field(i), f); err != nil {
+				return err
+			}
+		}
+	case reflect.Slice:
+		elems, ok := raw.([]interface{})
+		if !ok {
+			return errType()
+		}
+		s := reflect.MakeSlice(v.Type(), len(elems), len(elems))
+		for i, e := range elems {
+			if err := fromJSONValue(s.Index(i), e); err != nil {
+				return err
+			}
+		}
+		v.Set(s)
+	case reflect.Map:
+		entries, ok := raw.(map[string]interface{})
+		if !ok {
+			return errType()
+		}
+		m := reflect.MakeMapWithSize(v.Type(), len(entries))
+		for k, e := range entries {
+			key := reflect.New(v.Type().Key()).Elem()
+			var rawKey interface{} = json.Number(k)
+			switch key.Kind() {
+			case reflect.String:
+				rawKey = k
+			case reflect.Bool:
+				b, err := strconv.ParseBool(k)
+				if err != nil {
+					return err
+				}
+				rawKey = b
+			}
+			if err := fromJSONValue(key, rawKey); err != nil {
+				return err
+			}
+			val := reflect.New(v.Type().Elem()).Elem()
+			if err := fromJSONValue(val, e); err != nil {
+				return err
+			}
+			m.SetMapIndex(key, val)
+		}
+		v.Set(m)
+	default:
+		return errType()
+	}
+	return nil
+}
@@ -0,0 +1,66 @@
+package configs
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+
+	dbus "github.com/godbus/dbus/v5"
+)
+
+func TestSystemdPropertyJSON(t *testing.T) {
+	type deviceEntry struct {
+		Path  string
+		Perms string
+	}
+	props := []SystemdProperty{
+		{Name: "TimeoutStopUSec", Value: dbus.MakeVariant(uint64(123456789))},
+		{Name: "CollectMode", Value: dbus.MakeVariant("inactive-or-failed")},
+		{Name: "CPUWeight", Value: dbus.MakeVariant(uint64(100)), Runtime: true},
+		{Name: "AllowedCPUs", Value: dbus.MakeVariant([]byte{0x0f})},
+		{Name: "DeviceAllow", Value: dbus.MakeVariant([]deviceEntry{{"/dev/null", "rwm"}, {"char-pts", "rwm"}})},
+		{Name: "Environment", Value: dbus.MakeVariant(map[string]int32{"a": -1})},
+		{Name: "Weights", Value: dbus.MakeVariant(map[uint32]bool{7: true})},
+	}
+
+	for _, p := range props {
+		if err := p.Validate(); err != nil {
+			t.Errorf("%s: unexpected validation error: %v", p.Name, err)
+			continue
+		}
+		data, err := json.Marshal(p)
+		if err != nil {
+			t.Errorf("%s: marshal error: %v", p.Name, err)
+			continue
+		}
+		var p2 SystemdProperty
+		if err := json.Unmarshal(data, &p2); err != nil {
+			t.Errorf("%s: unmarshal error: %v (json: %s)", p.Name, err, data)
+			continue
+		}
+		if p2.Name != p.Name || p2.Runtime != p.Runtime {
+			t.Errorf("%s: expected %+v, got %+v", p.Name, p, p2)
+		}
+		if p2.Value.Signature() != p.Value.Signature() {
+			t.Errorf("%s: expected signature %s, got %s", p.Name, p.Value.Signature(), p2.Value.Signature())
+		}
+		// Compare in a form independent of Go types.
+		exp, _ := json.Marshal(toJSONValue(reflect.ValueOf(p.Value.Value())))
+		got, _ := json.Marshal(toJSONValue(reflect.ValueOf(p2.Value.Value())))
+		if string(exp) != string(got) {
+			t.Errorf("%s: expected value %s, got %s", p.Name, exp, got)
+		}
+	}
+}
+
+func TestSystemdPropertyValidate(t *testing.T) {
+	for _, p := range []SystemdProperty{
+		{Value: dbus.MakeVariant(1)},
+		{Name: "NoValue"},
+		{Name: "Variant", Value: dbus.MakeVariant(dbus.MakeVariant(1))},
+	} {
+		if err := p.Validate(); err == nil {
+			t.Errorf("%+v: expected error, got nil", p)
+		}
+	}
+}
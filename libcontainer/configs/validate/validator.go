@@ -5,9 +5,13 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"regexp"
+	"strconv"
 	"strings"
 	"sync"
+	"unsafe"
 
+	securejoin "github.com/cyphar/filepath-securejoin"
 	"github.com/opencontainers/runc/libcontainer/cgroups"
 	"github.com/opencontainers/runc/libcontainer/configs"
 	"github.com/opencontainers/runc/libcontainer/intelrdt"
@@ -18,21 +22,48 @@ import (
 
 type check func(config *configs.Config) error
 
+// ConfigError is returned by Validate if the config is invalid.
+type ConfigError struct {
+	// Field is the (JSON) name of the configs.Config field, or a group of
+	// fields, which failed the validation, e.g. "cgroups" or "rootfs".
+	Field string
+	Err   error
+}
+
+func (e *ConfigError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *ConfigError) Unwrap() error {
+	return e.Err
+}
+
 func Validate(config *configs.Config) error {
-	checks := []check{
-		cgroupsCheck,
-		rootfs,
-		network,
-		hostname,
-		security,
-		namespaces,
-		sysctl,
-		intelrdtCheck,
-		rootlessEUIDCheck,
+	checks := []struct {
+		field string
+		check check
+	}{
+		{"cgroups", cgroupsCheck},
+		{"rootfs", rootfs},
+		{"networks", network},
+		{"hostname", hostname},
+		{"security", security},
+		{"namespaces", namespaces},
+		{"sysctl", sysctl},
+		{"intel_rdt", intelrdtCheck},
+		{"rootless_euid", rootlessEUIDCheck},
+		{"mounts", idmapMounts},
+		{"scheduler", scheduler},
+		{"io_priority", ioPriority},
+		{"capabilities", capabilities},
+		{"core_sched", coreSched},
+		{"exec_cpu_affinity", execCPUAffinity},
+		{"memory_policy", memoryPolicy},
+		{"mask_path_modes", maskPathModes},
 	}
 	for _, c := range checks {
-		if err := c(config); err != nil {
-			return err
+		if err := c.check(config); err != nil {
+			return &ConfigError{Field: c.field, Err: err}
 		}
 	}
 	// Relaxed validation rules for backward compatibility
@@ -112,6 +143,22 @@ func namespaces(config *configs.Config) error {
 		}
 	}
 
+	if config.Namespaces.Contains(configs.NEWTIME) {
+		if _, err := os.Stat("/proc/self/ns/time"); os.IsNotExist(err) {
+			return errors.New("time namespaces aren't enabled in the kernel")
+		}
+	}
+	if len(config.TimeOffsets) > 0 {
+		if config.Namespaces.PathOf(configs.NEWTIME) != "" || !config.Namespaces.Contains(configs.NEWTIME) {
+			return errors.New("time offsets specified, but no new time namespace is requested in the config")
+		}
+		for clock := range config.TimeOffsets {
+			if clock != "monotonic" && clock != "boottime" {
+				return fmt.Errorf("time offsets: invalid clock %q", clock)
+			}
+		}
+	}
+
 	return nil
 }
 
@@ -231,6 +278,70 @@ func intelrdtCheck(config *configs.Config) error {
 	return nil
 }
 
+func checkManagedOOM(c *configs.Cgroup) error {
+	if !c.Systemd || !cgroups.IsCgroup2UnifiedMode() {
+		return errors.New("invalid configuration: managed OOM requires systemd cgroup driver on cgroup v2")
+	}
+	oom := c.ManagedOOM
+	for name, v := range map[string]string{"memory pressure": oom.MemoryPressure, "swap": oom.Swap} {
+		switch v {
+		case "", "auto", "kill":
+		default:
+			return fmt.Errorf("invalid configuration: managed OOM %s action %q (must be auto or kill)", name, v)
+		}
+	}
+	if oom.MemoryPressureLimit > 10000 {
+		return fmt.Errorf("invalid configuration: managed OOM memory pressure limit %d is over 10000 (100%%)", oom.MemoryPressureLimit)
+	}
+	return nil
+}
+
+// checkDelegatedResources returns an error if resources are set for one of
+// the controllers systemd knows about, but which is not in the list of
+// controllers to delegate, as systemd would not enable it for the container.
+func checkDelegatedResources(c *configs.Cgroup) error {
+	r := c.Resources
+	if r == nil {
+		return nil
+	}
+	delegated := make(map[string]bool, len(c.DelegateControllers))
+	for _, ctrl := range c.DelegateControllers {
+		// The cgroup v1 names are aliases.
+		switch ctrl {
+		case "cpuacct":
+			ctrl = "cpu"
+		case "blkio":
+			ctrl = "io"
+		}
+		delegated[ctrl] = true
+	}
+	set := map[string]bool{
+		"cpu": r.CpuShares != 0 || r.CpuWeight != 0 || r.CpuQuota != 0 || r.CpuPeriod != 0 ||
+			r.CpuRtRuntime != 0 || r.CpuRtPeriod != 0 || r.CpuUclampMin != "" || r.CpuUclampMax != "",
+		"cpuset": r.CpusetCpus != "" || r.CpusetMems != "" || r.CpusetPartition != "",
+		"io": r.BlkioWeight != 0 || r.BlkioLeafWeight != 0 || len(r.BlkioWeightDevice) > 0 ||
+			len(r.BlkioThrottleReadBpsDevice) > 0 || len(r.BlkioThrottleWriteBpsDevice) > 0 ||
+			len(r.BlkioThrottleReadIOPSDevice) > 0 || len(r.BlkioThrottleWriteIOPSDevice) > 0 ||
+			len(r.BlkioLatencyDevice) > 0,
+		"memory": r.Memory != 0 || r.MemoryReservation != 0 || r.MemorySwap != 0 ||
+			r.MemorySwappiness != nil || r.MemoryZswapMax != nil || r.OomKillDisable,
+		"pids": r.PidsLimit != 0,
+	}
+	for key := range r.Unified {
+		if i := strings.Index(key, "."); i > 0 {
+			if _, ok := set[key[:i]]; ok {
+				set[key[:i]] = true
+			}
+		}
+	}
+	for ctrl, isSet := range set {
+		if isSet && !delegated[ctrl] {
+			return fmt.Errorf("invalid configuration: %s resources are set, but the %s controller is not delegated", ctrl, ctrl)
+		}
+	}
+	return nil
+}
+
 func cgroupsCheck(config *configs.Config) error {
 	c := config.Cgroups
 	if c == nil {
@@ -241,6 +352,62 @@ func cgroupsCheck(config *configs.Config) error {
 		return fmt.Errorf("cgroup: either Path or Name and Parent should be used, got %+v", c)
 	}
 
+	if c.Threaded {
+		if !cgroups.IsCgroup2UnifiedMode() {
+			return errors.New("invalid configuration: threaded cgroup is only supported on cgroup v2")
+		}
+		if c.Systemd {
+			return errors.New("invalid configuration: threaded cgroup is not supported with systemd cgroup driver")
+		}
+	}
+
+	for _, p := range c.SystemdProps {
+		if err := p.Validate(); err != nil {
+			return fmt.Errorf("invalid configuration: %w", err)
+		}
+	}
+
+	if c.ManagedOOM != nil {
+		if err := checkManagedOOM(c); err != nil {
+			return err
+		}
+	}
+
+	for _, ctrl := range c.DelegateControllers {
+		switch ctrl {
+		case "cpu", "cpuacct", "cpuset", "io", "blkio", "memory", "devices", "pids":
+		default:
+			return fmt.Errorf("invalid configuration: can't delegate unknown controller %q", ctrl)
+		}
+	}
+	if len(c.DelegateControllers) > 0 && !c.Systemd {
+		return errors.New("invalid configuration: delegating controllers requires systemd cgroup driver")
+	}
+	if len(c.DelegateControllers) > 0 {
+		if err := checkDelegatedResources(c); err != nil {
+			return err
+		}
+	}
+
+	if c.UnitStop != nil {
+		if !c.Systemd {
+			return errors.New("invalid configuration: unit stop settings require systemd cgroup driver")
+		}
+		switch c.UnitStop.KillMode {
+		case "", "control-group", "mixed", "process", "none":
+		default:
+			return fmt.Errorf("invalid configuration: unknown kill mode %q", c.UnitStop.KillMode)
+		}
+	}
+
+	if c.ExistingUnit && !c.Systemd {
+		return errors.New("invalid configuration: joining an existing unit requires systemd cgroup driver")
+	}
+
+	if c.StartAsync && (!c.Systemd || !cgroups.IsCgroup2UnifiedMode()) {
+		return errors.New("invalid configuration: asynchronous unit start is only supported by systemd cgroup driver on cgroup v2")
+	}
+
 	r := c.Resources
 	if r == nil {
 		return nil
@@ -250,6 +417,36 @@ func cgroupsCheck(config *configs.Config) error {
 		return cgroups.ErrV1NoUnified
 	}
 
+	if !cgroups.IsCgroup2UnifiedMode() && len(r.BlkioLatencyDevice) > 0 {
+		return errors.New("invalid configuration: io latency is only supported on cgroup v2")
+	}
+
+	if !cgroups.IsCgroup2UnifiedMode() && len(r.Misc) > 0 {
+		return errors.New("invalid configuration: misc controller is only supported on cgroup v2")
+	}
+
+	if r.CpuUclampMin != "" || r.CpuUclampMax != "" {
+		if !cgroups.IsCgroup2UnifiedMode() {
+			return errors.New("invalid configuration: cpu uclamp is only supported on cgroup v2")
+		}
+		if err := checkUclamp(r.CpuUclampMin, r.CpuUclampMax); err != nil {
+			return err
+		}
+	}
+
+	switch r.CpusetPartition {
+	case "", "member":
+	case "root", "isolated":
+		if !cgroups.IsCgroup2UnifiedMode() {
+			return errors.New("invalid configuration: cpuset partition is only supported on cgroup v2")
+		}
+		if r.CpusetCpus == "" {
+			return fmt.Errorf("invalid configuration: cpuset partition %q requires cpuset cpus to be set", r.CpusetPartition)
+		}
+	default:
+		return fmt.Errorf("invalid configuration: unknown cpuset partition type %q", r.CpusetPartition)
+	}
+
 	if cgroups.IsCgroup2UnifiedMode() {
 		_, err := cgroups.ConvertMemorySwapToCgroupV2Value(r.MemorySwap, r.Memory)
 		if err != nil {
@@ -260,6 +457,44 @@ func cgroupsCheck(config *configs.Config) error {
 	return nil
 }
 
+var uclampRe = regexp.MustCompile(`^[0-9]+(\.[0-9]{1,2})?$`)
+
+// checkUclamp checks that min and max are valid cpu.uclamp.{min,max}
+// values, i.e. percentages with at most two decimal places, and that
+// the minimum does not exceed the maximum.
+func checkUclamp(uclampMin, uclampMax string) error {
+	parse := func(name, val string) (float64, error) {
+		if val == "" {
+			return 0, nil
+		}
+		if name == "max" && val == "max" {
+			return 100, nil
+		}
+		// ParseFloat also accepts signs, exponents, hex floats,
+		// NaN and Inf, none of which the kernel accepts.
+		if !uclampRe.MatchString(val) {
+			return 0, fmt.Errorf("invalid configuration: cpu uclamp %s %q must be a percentage with at most two decimal places", name, val)
+		}
+		v, err := strconv.ParseFloat(val, 64)
+		if err != nil || v > 100 {
+			return 0, fmt.Errorf("invalid configuration: cpu uclamp %s %q must be a percentage between 0 and 100", name, val)
+		}
+		return v, nil
+	}
+	minV, err := parse("min", uclampMin)
+	if err != nil {
+		return err
+	}
+	maxV, err := parse("max", uclampMax)
+	if err != nil {
+		return err
+	}
+	if uclampMin != "" && uclampMax != "" && minV > maxV {
+		return fmt.Errorf("invalid configuration: cpu uclamp min %q is greater than max %q", uclampMin, uclampMax)
+	}
+	return nil
+}
+
 func mounts(config *configs.Config) error {
 	for _, m := range config.Mounts {
 		if !filepath.IsAbs(m.Destination) {
@@ -270,6 +505,186 @@ func mounts(config *configs.Config) error {
 	return nil
 }
 
+func capabilities(config *configs.Config) error {
+	return Capabilities(config.Capabilities)
+}
+
+// Capabilities validates a set of capabilities, either of the container
+// or of a process executed in it. Every ambient capability must also be
+// in both the permitted and the inheritable sets, as otherwise the kernel
+// refuses to raise it (see capabilities(7)).
+func Capabilities(caps *configs.Capabilities) error {
+	if caps == nil || len(caps.Ambient) == 0 {
+		return nil
+	}
+	inSet := func(set []string, c string) bool {
+		for _, s := range set {
+			if s == c {
+				return true
+			}
+		}
+		return false
+	}
+	for _, c := range caps.Ambient {
+		if !inSet(caps.Permitted, c) || !inSet(caps.Inheritable, c) {
+			return fmt.Errorf("ambient capability %s must also be in both permitted and inheritable capabilities", c)
+		}
+	}
+	return nil
+}
+
+func memoryPolicy(config *configs.Config) error {
+	if config.MemoryPolicy == nil {
+		return nil
+	}
+	_, _, err := config.MemoryPolicy.ToMempolicy()
+	return err
+}
+
+func maskPathModes(config *configs.Config) error {
+	for path, mode := range config.MaskPathModes {
+		switch mode {
+		case configs.MaskDefault, configs.MaskTmpfs, configs.MaskReadonly:
+		default:
+			return fmt.Errorf("invalid mask mode %q for %s", mode, path)
+		}
+		masked := false
+		for _, p := range config.MaskPaths {
+			if p == path {
+				masked = true
+				break
+			}
+		}
+		if !masked {
+			return fmt.Errorf("mask mode set for %s, which is not a masked path", path)
+		}
+		if mode == configs.MaskTmpfs {
+			if err := checkMaskDir(config.Rootfs, path); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// checkMaskDir checks that path, masked with a tmpfs, is not a file. As
+// procfs is not mounted in the container yet, /proc paths are checked on the
+// host one. Paths which do not exist are not masked, so they are fine.
+func checkMaskDir(rootfs, path string) error {
+	p := filepath.Clean(path)
+	if p != "/proc" && !strings.HasPrefix(p, "/proc/") {
+		var err error
+		if p, err = securejoin.SecureJoin(rootfs, p); err != nil {
+			return err
+		}
+	}
+	fi, err := os.Stat(p)
+	if err != nil {
+		return nil
+	}
+	if !fi.IsDir() {
+		return fmt.Errorf("mask mode %q set for %s, which is not a directory", configs.MaskTmpfs, path)
+	}
+	return nil
+}
+
+func execCPUAffinity(config *configs.Config) error {
+	a := config.ExecCPUAffinity
+	if a == nil {
+		return nil
+	}
+	if _, err := configs.ToCPUSet(a.Initial); err != nil {
+		return fmt.Errorf("invalid initial exec cpu affinity: %w", err)
+	}
+	if _, err := configs.ToCPUSet(a.Final); err != nil {
+		return fmt.Errorf("invalid final exec cpu affinity: %w", err)
+	}
+	return nil
+}
+
+func coreSched(config *configs.Config) error {
+	if config.CoreSched == nil {
+		return nil
+	}
+	if config.CoreSched.SharePid < 0 {
+		return fmt.Errorf("invalid core scheduling share pid %d", config.CoreSched.SharePid)
+	}
+	var cookie uint64
+	err := unix.Prctl(unix.PR_SCHED_CORE, unix.PR_SCHED_CORE_GET, 0, 0, uintptr(unsafe.Pointer(&cookie)))
+	if errors.Is(err, unix.EINVAL) {
+		return errors.New("core scheduling is not supported by the kernel")
+	}
+	return nil
+}
+
+func scheduler(config *configs.Config) error {
+	s := config.Scheduler
+	if s == nil {
+		return nil
+	}
+	if _, err := s.ToSchedAttr(); err != nil {
+		return err
+	}
+	switch s.Policy {
+	case "SCHED_FIFO", "SCHED_RR":
+		if s.Priority < 1 || s.Priority > 99 {
+			return fmt.Errorf("scheduler priority %d out of range [1, 99] for %s", s.Priority, s.Policy)
+		}
+	default:
+		if s.Priority != 0 {
+			return fmt.Errorf("scheduler priority is not supported for %s", s.Policy)
+		}
+	}
+	if s.Nice < -20 || s.Nice > 19 {
+		return fmt.Errorf("scheduler nice value %d out of range [-20, 19]", s.Nice)
+	}
+	if s.Policy == "SCHED_DEADLINE" {
+		if s.Runtime == 0 || s.Deadline < s.Runtime || (s.Period != 0 && s.Period < s.Deadline) {
+			return errors.New("invalid SCHED_DEADLINE parameters: 0 < runtime <= deadline <= period is required")
+		}
+	} else if s.Runtime != 0 || s.Deadline != 0 || s.Period != 0 {
+		return fmt.Errorf("scheduler runtime, deadline, and period are not supported for %s", s.Policy)
+	}
+	return nil
+}
+
+func ioPriority(config *configs.Config) error {
+	if config.IOPriority == nil {
+		return nil
+	}
+	_, err := config.IOPriority.ToIOPrio()
+	return err
+}
+
+// idmapMounts validates the id-mapped mounts.
+func idmapMounts(config *configs.Config) error {
+	for _, m := range config.Mounts {
+		if !m.IsIDMapped() {
+			continue
+		}
+		if err := checkIDMapMount(config, m); err != nil {
+			return fmt.Errorf("invalid mount %q: %w", m.Destination, err)
+		}
+	}
+	return nil
+}
+
+func checkIDMapMount(config *configs.Config, m *configs.Mount) error {
+	if !m.IsBind() {
+		return errors.New("id-mapped mounts are only supported for bind mounts")
+	}
+	if len(m.UIDMappings) == 0 || len(m.GIDMappings) == 0 {
+		return errors.New("id-mapped mounts require both uid and gid mappings")
+	}
+	if !config.Namespaces.Contains(configs.NEWUSER) {
+		return errors.New("id-mapped mounts require a user namespace")
+	}
+	if config.RootlessEUID {
+		return errors.New("id-mapped mounts are not supported for rootless containers")
+	}
+	return nil
+}
+
 func isHostNetNS(path string) (bool, error) {
 	const currentProcessNetns = "/proc/self/ns/net"
 
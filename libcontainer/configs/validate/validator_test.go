@@ -1,10 +1,12 @@
 package validate
 
 import (
+	"errors"
 	"os"
 	"path/filepath"
 	"testing"
 
+	"github.com/opencontainers/runc/libcontainer/cgroups"
 	"github.com/opencontainers/runc/libcontainer/configs"
 	"golang.org/x/sys/unix"
 )
@@ -92,6 +94,13 @@ func TestValidateHostnameWithoutUTSNamespace(t *testing.T) {
 	if err == nil {
 		t.Error("Expected error to occur but it was nil")
 	}
+	var cErr *ConfigError
+	if !errors.As(err, &cErr) {
+		t.Fatalf("Expected ConfigError, got %T", err)
+	}
+	if cErr.Field != "hostname" {
+		t.Errorf("Expected field %q, got %q", "hostname", cErr.Field)
+	}
 }
 
 func TestValidateSecurityWithMaskPaths(t *testing.T) {
@@ -160,6 +169,255 @@ func TestValidateUsernamespace(t *testing.T) {
 	}
 }
 
+func TestValidateScheduler(t *testing.T) {
+	for _, tc := range []struct {
+		s     configs.Scheduler
+		isErr bool
+	}{
+		{s: configs.Scheduler{Policy: "SCHED_OTHER", Nice: -5}},
+		{s: configs.Scheduler{Policy: "SCHED_FIFO", Priority: 50, Flags: []string{"SCHED_FLAG_RESET_ON_FORK"}}},
+		{s: configs.Scheduler{Policy: "SCHED_DEADLINE", Runtime: 10000, Deadline: 20000, Period: 30000}},
+		{s: configs.Scheduler{Policy: "SCHED_DEADLINE", Runtime: 10000, Deadline: 20000}},
+		{s: configs.Scheduler{Policy: "SCHED_UNKNOWN"}, isErr: true},
+		{s: configs.Scheduler{Policy: "SCHED_OTHER", Flags: []string{"SCHED_FLAG_UNKNOWN"}}, isErr: true},
+		{s: configs.Scheduler{Policy: "SCHED_OTHER", Flags: []string{"SCHED_FLAG_UTIL_CLAMP_MIN"}}, isErr: true},
+		{s: configs.Scheduler{Policy: "SCHED_ISO"}, isErr: true},
+		{s: configs.Scheduler{Policy: "SCHED_FIFO"}, isErr: true},
+		{s: configs.Scheduler{Policy: "SCHED_OTHER", Priority: 1}, isErr: true},
+		{s: configs.Scheduler{Policy: "SCHED_BATCH", Nice: 20}, isErr: true},
+		{s: configs.Scheduler{Policy: "SCHED_DEADLINE", Runtime: 20000, Deadline: 10000}, isErr: true},
+		{s: configs.Scheduler{Policy: "SCHED_RR", Priority: 1, Period: 10000}, isErr: true},
+	} {
+		s := tc.s
+		config := &configs.Config{
+			Rootfs:    "/var",
+			Scheduler: &s,
+		}
+		err := Validate(config)
+		if tc.isErr && err == nil {
+			t.Errorf("%+v: expected error, got nil", tc.s)
+		} else if !tc.isErr && err != nil {
+			t.Errorf("%+v: expected no error, got %v", tc.s, err)
+		}
+	}
+}
+
+func TestValidateAmbientCapabilities(t *testing.T) {
+	for _, tc := range []struct {
+		caps  configs.Capabilities
+		isErr bool
+	}{
+		{caps: configs.Capabilities{Permitted: []string{"CAP_KILL"}}},
+		{caps: configs.Capabilities{
+			Permitted:   []string{"CAP_KILL", "CAP_NET_BIND_SERVICE"},
+			Inheritable: []string{"CAP_NET_BIND_SERVICE"},
+			Ambient:     []string{"CAP_NET_BIND_SERVICE"},
+		}},
+		{caps: configs.Capabilities{
+			Permitted: []string{"CAP_NET_BIND_SERVICE"},
+			Ambient:   []string{"CAP_NET_BIND_SERVICE"},
+		}, isErr: true},
+		{caps: configs.Capabilities{
+			Inheritable: []string{"CAP_NET_BIND_SERVICE"},
+			Ambient:     []string{"CAP_NET_BIND_SERVICE"},
+		}, isErr: true},
+	} {
+		caps := tc.caps
+		config := &configs.Config{
+			Rootfs:       "/var",
+			Capabilities: &caps,
+		}
+		err := Validate(config)
+		if tc.isErr && err == nil {
+			t.Errorf("%+v: expected error, got nil", tc.caps)
+		} else if !tc.isErr && err != nil {
+			t.Errorf("%+v: expected no error, got %v", tc.caps, err)
+		}
+	}
+}
+
+func TestValidateMemoryPolicy(t *testing.T) {
+	for _, tc := range []struct {
+		p     configs.MemoryPolicy
+		isErr bool
+	}{
+		{p: configs.MemoryPolicy{Mode: "MPOL_DEFAULT"}},
+		{p: configs.MemoryPolicy{Mode: "MPOL_LOCAL"}},
+		{p: configs.MemoryPolicy{Mode: "MPOL_PREFERRED"}},
+		{p: configs.MemoryPolicy{Mode: "MPOL_BIND", Nodes: "0-1,3"}},
+		{p: configs.MemoryPolicy{Mode: "MPOL_INTERLEAVE", Nodes: "0,1", Flags: []string{"MPOL_F_STATIC_NODES"}}},
+		{p: configs.MemoryPolicy{Mode: "MPOL_UNKNOWN"}, isErr: true},
+		{p: configs.MemoryPolicy{Mode: "MPOL_BIND"}, isErr: true},
+		{p: configs.MemoryPolicy{Mode: "MPOL_LOCAL", Nodes: "0"}, isErr: true},
+		{p: configs.MemoryPolicy{Mode: "MPOL_BIND", Nodes: "0-1024"}, isErr: true},
+		{p: configs.MemoryPolicy{Mode: "MPOL_BIND", Nodes: "0", Flags: []string{"MPOL_F_UNKNOWN"}}, isErr: true},
+	} {
+		p := tc.p
+		config := &configs.Config{
+			Rootfs:       "/var",
+			MemoryPolicy: &p,
+		}
+		err := Validate(config)
+		if tc.isErr && err == nil {
+			t.Errorf("%+v: expected error, got nil", tc.p)
+		} else if !tc.isErr && err != nil {
+			t.Errorf("%+v: expected no error, got %v", tc.p, err)
+		}
+	}
+}
+
+func TestValidateMaskPathModes(t *testing.T) {
+	rootfs := t.TempDir()
+	if err := os.Mkdir(filepath.Join(rootfs, "dir"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(rootfs, "file"), nil, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	for _, tc := range []struct {
+		modes map[string]configs.MaskMode
+		isErr bool
+	}{
+		{modes: map[string]configs.MaskMode{"/proc/kcore": configs.MaskDefault}},
+		{modes: map[string]configs.MaskMode{"/proc/acpi": configs.MaskTmpfs}},
+		{modes: map[string]configs.MaskMode{"/proc/acpi": configs.MaskReadonly, "/proc/kcore": configs.MaskReadonly}},
+		{modes: map[string]configs.MaskMode{"/proc/acpi": "overlay"}, isErr: true},
+		{modes: map[string]configs.MaskMode{"/proc/sys": configs.MaskTmpfs}, isErr: true},
+		{modes: map[string]configs.MaskMode{"/dir": configs.MaskTmpfs}},
+		{modes: map[string]configs.MaskMode{"/file": configs.MaskTmpfs}, isErr: true},
+		{modes: map[string]configs.MaskMode{"/file": configs.MaskReadonly}},
+		{modes: map[string]configs.MaskMode{"/proc/version": configs.MaskTmpfs}, isErr: true},
+	} {
+		config := &configs.Config{
+			Rootfs:        rootfs,
+			Namespaces:    configs.Namespaces{{Type: configs.NEWNS}},
+			MaskPaths:     []string{"/proc/acpi", "/proc/kcore", "/proc/version", "/dir", "/file"},
+			MaskPathModes: tc.modes,
+		}
+		err := Validate(config)
+		if tc.isErr && err == nil {
+			t.Errorf("%+v: expected error, got nil", tc.modes)
+		} else if !tc.isErr && err != nil {
+			t.Errorf("%+v: expected no error, got %v", tc.modes, err)
+		}
+	}
+}
+
+func TestValidateIOPriority(t *testing.T) {
+	for _, tc := range []struct {
+		p     configs.IOPriority
+		isErr bool
+	}{
+		{p: configs.IOPriority{Class: "IOPRIO_CLASS_RT", Priority: 0}},
+		{p: configs.IOPriority{Class: "IOPRIO_CLASS_BE", Priority: 7}},
+		{p: configs.IOPriority{Class: "IOPRIO_CLASS_IDLE"}},
+		{p: configs.IOPriority{Class: "IOPRIO_CLASS_NONE"}, isErr: true},
+		{p: configs.IOPriority{Class: "IOPRIO_CLASS_BE", Priority: 8}, isErr: true},
+		{p: configs.IOPriority{Class: "IOPRIO_CLASS_BE", Priority: -1}, isErr: true},
+	} {
+		p := tc.p
+		config := &configs.Config{
+			Rootfs:     "/var",
+			IOPriority: &p,
+		}
+		err := Validate(config)
+		if tc.isErr && err == nil {
+			t.Errorf("%+v: expected error, got nil", tc.p)
+		} else if !tc.isErr && err != nil {
+			t.Errorf("%+v: expected no error, got %v", tc.p, err)
+		}
+	}
+}
+
+func TestValidateIDMapMounts(t *testing.T) {
+	mapping := []configs.IDMap{{ContainerID: 0, HostID: 100000, Size: 65536}}
+	userns := configs.Namespaces([]configs.Namespace{{Type: configs.NEWUSER}})
+	for _, tc := range []struct {
+		name       string
+		namespaces configs.Namespaces
+		rootless   bool
+		mount      configs.Mount
+		isErr      bool
+	}{
+		{
+			name:       "not a bind mount",
+			namespaces: userns,
+			mount:      configs.Mount{Device: "tmpfs", UIDMappings: mapping, GIDMappings: mapping},
+			isErr:      true,
+		},
+		{
+			name:       "no gid mappings",
+			namespaces: userns,
+			mount:      configs.Mount{Flags: unix.MS_BIND, UIDMappings: mapping},
+			isErr:      true,
+		},
+		{
+			name:  "no userns",
+			mount: configs.Mount{Flags: unix.MS_BIND, UIDMappings: mapping, GIDMappings: mapping},
+			isErr: true,
+		},
+		{
+			name:       "rootless",
+			namespaces: userns,
+			rootless:   true,
+			mount:      configs.Mount{Flags: unix.MS_BIND, UIDMappings: mapping, GIDMappings: mapping},
+			isErr:      true,
+		},
+		{
+			name:       "valid",
+			namespaces: userns,
+			mount:      configs.Mount{Flags: unix.MS_BIND, UIDMappings: mapping, GIDMappings: mapping},
+		},
+	} {
+		m := tc.mount
+		m.Destination = "/mnt"
+		config := &configs.Config{
+			Rootfs:       "/var",
+			Namespaces:   tc.namespaces,
+			RootlessEUID: tc.rootless,
+			Mounts:       []*configs.Mount{&m},
+		}
+		err := idmapMounts(config)
+		if tc.isErr && err == nil {
+			t.Errorf("%s: expected error, got nil", tc.name)
+		} else if !tc.isErr && err != nil {
+			t.Errorf("%s: expected no error, got %v", tc.name, err)
+		}
+	}
+}
+
+func TestValidateTimeOffsets(t *testing.T) {
+	offsets := map[string]configs.TimeOffset{"monotonic": {Secs: 10}}
+	for _, tc := range []struct {
+		name       string
+		namespaces configs.Namespaces
+		offsets    map[string]configs.TimeOffset
+		isErr      bool
+	}{
+		{name: "no timens", offsets: offsets, isErr: true},
+		{name: "join timens", namespaces: []configs.Namespace{{Type: configs.NEWTIME, Path: "/proc/1/ns/time"}}, offsets: offsets, isErr: true},
+		{name: "bad clock", namespaces: []configs.Namespace{{Type: configs.NEWTIME}}, offsets: map[string]configs.TimeOffset{"realtime": {Secs: 1}}, isErr: true},
+		{name: "valid", namespaces: []configs.Namespace{{Type: configs.NEWTIME}}, offsets: offsets},
+	} {
+		if !tc.isErr {
+			if _, err := os.Stat("/proc/self/ns/time"); os.IsNotExist(err) {
+				continue
+			}
+		}
+		config := &configs.Config{
+			Rootfs:      "/var",
+			Namespaces:  tc.namespaces,
+			TimeOffsets: tc.offsets,
+		}
+		err := Validate(config)
+		if tc.isErr && err == nil {
+			t.Errorf("%s: expected error, got nil", tc.name)
+		} else if !tc.isErr && err != nil {
+			t.Errorf("%s: expected no error, got %v", tc.name, err)
+		}
+	}
+}
+
 func TestValidateUsernamespaceWithoutUserNS(t *testing.T) {
 	uidMap := configs.IDMap{ContainerID: 123}
 	config := &configs.Config{
@@ -359,3 +617,222 @@ func TestValidateMounts(t *testing.T) {
 		}
 	}
 }
+
+func TestValidateCpusetPartition(t *testing.T) {
+	testCases := []struct {
+		isErr     bool
+		cpus      string
+		partition string
+	}{
+		{isErr: false, partition: ""},
+		{isErr: false, partition: "member"},
+		{isErr: true, partition: "root"},
+		{isErr: true, partition: "bogus"},
+	}
+
+	for _, tc := range testCases {
+		config := &configs.Config{
+			Rootfs: "/var",
+			Cgroups: &configs.Cgroup{
+				Resources: &configs.Resources{
+					CpusetCpus:      tc.cpus,
+					CpusetPartition: tc.partition,
+				},
+			},
+		}
+
+		err := Validate(config)
+		if tc.isErr && err == nil {
+			t.Errorf("cpuset partition: %q, expected error, got nil", tc.partition)
+		}
+		if !tc.isErr && err != nil {
+			t.Errorf("cpuset partition: %q, expected nil, got error %v", tc.partition, err)
+		}
+	}
+}
+
+func TestValidateThreadedCgroup(t *testing.T) {
+	config := &configs.Config{
+		Rootfs: "/var",
+		Cgroups: &configs.Cgroup{
+			Threaded: true,
+			Systemd:  true,
+		},
+	}
+
+	err := Validate(config)
+	if err == nil {
+		t.Error("threaded cgroup with systemd: expected error, got nil")
+	}
+}
+
+func TestValidateExistingUnit(t *testing.T) {
+	config := &configs.Config{
+		Rootfs: "/var",
+		Cgroups: &configs.Cgroup{
+			ExistingUnit: true,
+		},
+	}
+
+	err := Validate(config)
+	if err == nil {
+		t.Error("existing unit without systemd: expected error, got nil")
+	}
+}
+
+func TestValidateStartAsync(t *testing.T) {
+	config := &configs.Config{
+		Rootfs: "/var",
+		Cgroups: &configs.Cgroup{
+			Systemd:    true,
+			StartAsync: true,
+		},
+	}
+
+	err := Validate(config)
+	if cgroups.IsCgroup2UnifiedMode() && err != nil {
+		t.Errorf("async start on cgroup v2: expected nil, got error %v", err)
+	}
+	if !cgroups.IsCgroup2UnifiedMode() && err == nil {
+		t.Error("async start on cgroup v1: expected error, got nil")
+	}
+
+	config.Cgroups.Systemd = false
+	if err := Validate(config); err == nil {
+		t.Error("async start without systemd: expected error, got nil")
+	}
+}
+
+func TestValidateManagedOOM(t *testing.T) {
+	testCases := []struct {
+		systemd bool
+		oom     configs.ManagedOOM
+		isErr   bool
+	}{
+		{systemd: false, oom: configs.ManagedOOM{Swap: "kill"}, isErr: true},
+		{systemd: true, oom: configs.ManagedOOM{Swap: "kill", MemoryPressure: "auto", MemoryPressureLimit: 5000}},
+		{systemd: true, oom: configs.ManagedOOM{Swap: "always"}, isErr: true},
+		{systemd: true, oom: configs.ManagedOOM{MemoryPressureLimit: 10001}, isErr: true},
+	}
+	for _, tc := range testCases {
+		tc := tc
+		config := &configs.Config{
+			Rootfs: "/var",
+			Cgroups: &configs.Cgroup{
+				Systemd:    tc.systemd,
+				ManagedOOM: &tc.oom,
+			},
+		}
+		err := Validate(config)
+		if !cgroups.IsCgroup2UnifiedMode() {
+			if err == nil {
+				t.Errorf("%+v: expected error on cgroup v1, got nil", tc.oom)
+			}
+			continue
+		}
+		if tc.isErr && err == nil {
+			t.Errorf("%+v: expected error, got nil", tc.oom)
+		}
+		if !tc.isErr && err != nil {
+			t.Errorf("%+v: expected nil, got error %v", tc.oom, err)
+		}
+	}
+}
+
+func TestValidateDelegateControllers(t *testing.T) {
+	testCases := []struct {
+		systemd bool
+		ctrls   []string
+		res     *configs.Resources
+		isErr   bool
+	}{
+		{systemd: true, ctrls: []string{"cpu", "memory", "pids"}},
+		{systemd: true, ctrls: []string{"cpu", "net_cls"}, isErr: true},
+		{systemd: false, ctrls: []string{"memory"}, isErr: true},
+		{systemd: true, ctrls: []string{"memory", "pids"}, res: &configs.Resources{Memory: 1 << 20, PidsLimit: 10}},
+		{systemd: true, ctrls: []string{"cpuacct", "blkio"}, res: &configs.Resources{CpuShares: 512, BlkioWeight: 100}},
+		{systemd: true, ctrls: []string{"memory"}, res: &configs.Resources{PidsLimit: 10}, isErr: true},
+		{systemd: true, res: &configs.Resources{PidsLimit: 10}},
+	}
+	for _, tc := range testCases {
+		config := &configs.Config{
+			Rootfs: "/var",
+			Cgroups: &configs.Cgroup{
+				Systemd:             tc.systemd,
+				DelegateControllers: tc.ctrls,
+				Resources:           tc.res,
+			},
+		}
+		err := Validate(config)
+		if tc.isErr && err == nil {
+			t.Errorf("%v: expected error, got nil", tc.ctrls)
+		}
+		if !tc.isErr && err != nil {
+			t.Errorf("%v: expected nil, got error %v", tc.ctrls, err)
+		}
+	}
+}
+
+func TestValidateUnitStop(t *testing.T) {
+	testCases := []struct {
+		systemd bool
+		stop    configs.UnitStop
+		isErr   bool
+	}{
+		{systemd: false, stop: configs.UnitStop{TimeoutUSec: 1}, isErr: true},
+		{systemd: true, stop: configs.UnitStop{TimeoutUSec: 1, KillMode: "mixed"}},
+		{systemd: true, stop: configs.UnitStop{KillMode: "all"}, isErr: true},
+	}
+	for _, tc := range testCases {
+		tc := tc
+		config := &configs.Config{
+			Rootfs: "/var",
+			Cgroups: &configs.Cgroup{
+				Systemd:  tc.systemd,
+				UnitStop: &tc.stop,
+			},
+		}
+		err := Validate(config)
+		if tc.isErr && err == nil {
+			t.Errorf("%+v: expected error, got nil", tc.stop)
+		}
+		if !tc.isErr && err != nil {
+			t.Errorf("%+v: expected nil, got error %v", tc.stop, err)
+		}
+	}
+}
+
+func TestCheckUclamp(t *testing.T) {
+	testCases := []struct {
+		min, max string
+		isErr    bool
+	}{
+		{min: "", max: ""},
+		{min: "10", max: "max"},
+		{min: "20.50", max: "80.25"},
+		{min: "0", max: "100"},
+		{min: "50", max: "40", isErr: true},
+		{min: "max", isErr: true},
+		{min: "101", isErr: true},
+		{min: "-1", isErr: true},
+		{max: "10.125", isErr: true},
+		{max: "foo", isErr: true},
+		{min: "NaN", isErr: true},
+		{max: "Inf", isErr: true},
+		{min: "0x1p4", isErr: true},
+		{min: "1e1", isErr: true},
+		{min: "+10", isErr: true},
+		{min: "10.", isErr: true},
+		{min: ".5", isErr: true},
+	}
+
+	for _, tc := range testCases {
+		err := checkUclamp(tc.min, tc.max)
+		if tc.isErr && err == nil {
+			t.Errorf("uclamp min %q max %q: expected error, got nil", tc.min, tc.max)
+		}
+		if !tc.isErr && err != nil {
+			t.Errorf("uclamp min %q max %q: expected nil, got error %v", tc.min, tc.max, err)
+		}
+	}
+}
@@ -53,6 +53,9 @@ type BaseState struct {
 	// InitProcessStartTime is the init process start time in clock cycles since boot time.
 	InitProcessStartTime uint64 `json:"init_process_start"`
 
+	// BootID is the boot ID of the host at the time the container was created.
+	BootID string `json:"boot_id,omitempty"`
+
 	// Created is the unix timestamp for the creation time of the container in UTC
 	Created time.Time `json:"created"`
 
@@ -96,6 +99,13 @@ type BaseContainer interface {
 	//
 	Set(config configs.Config) error
 
+	// Update changes resources of the running container. Unlike Set, it
+	// only applies the resources fields which are set (i.e. non-zero and
+	// non-nil), keeping the rest as currently configured, and saves the
+	// resulting config in the container state. To set a field back to zero,
+	// use Set.
+	Update(resources *configs.Resources) error
+
 	// Start a process inside the container. Returns error if process fails to
 	// start. You can track process lifecycle with passed Process structure.
 	Start(process *Process) (err error)
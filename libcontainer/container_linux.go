@@ -2,6 +2,7 @@ package libcontainer
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
@@ -12,6 +13,7 @@ import (
 	"path"
 	"path/filepath"
 	"reflect"
+	"sort"
 	"strconv"
 	"strings"
 	"sync"
@@ -29,6 +31,7 @@ import (
 
 	"github.com/opencontainers/runc/libcontainer/cgroups"
 	"github.com/opencontainers/runc/libcontainer/configs"
+	"github.com/opencontainers/runc/libcontainer/configs/validate"
 	"github.com/opencontainers/runc/libcontainer/intelrdt"
 	"github.com/opencontainers/runc/libcontainer/system"
 	"github.com/opencontainers/runc/libcontainer/utils"
@@ -44,11 +47,14 @@ type linuxContainer struct {
 	intelRdtManager      *intelrdt.Manager
 	initProcess          parentProcess
 	initProcessStartTime uint64
+	bootID               string
+	rebooted             bool
 	m                    sync.Mutex
 	criuVersion          int
 	state                containerState
 	created              time.Time
 	fifo                 *os.File
+	startPipe            *StartPipe
 }
 
 // State represents a running container's state
@@ -78,6 +84,10 @@ type State struct {
 
 	// Intel RDT "resource control" filesystem path
 	IntelRdtPath string `json:"intel_rdt_path"`
+
+	// StartPipe is the pipe used to start the container, if the exec fifo
+	// is not used.
+	StartPipe *StartPipe `json:"start_pipe,omitempty"`
 }
 
 // Container is a libcontainer container object.
@@ -112,6 +122,38 @@ type Container interface {
 
 	// NotifyMemoryPressure returns a read-only channel signaling when the container reaches a given pressure level
 	NotifyMemoryPressure(level PressureLevel) (<-chan struct{}, error)
+
+	// NotifyMemoryThreshold returns a read-only channel signaling every time the
+	// container memory usage crosses the given threshold (in bytes), either
+	// upwards or downwards. Only supported on cgroup v1.
+	NotifyMemoryThreshold(threshold uint64) (<-chan struct{}, error)
+
+	// NotifyPressure returns a read-only channel signaling every time the given
+	// PSI trigger fires for the container, until ctx is done, after which the
+	// channel is closed. Events are dropped if the previous one has not been
+	// received yet. Only supported on cgroup v2.
+	NotifyPressure(ctx context.Context, trigger PSITrigger) (<-chan struct{}, error)
+
+	// Reclaim triggers proactive reclaim of the given amount of memory (in bytes)
+	// from the container's cgroup. Only supported on cgroup v2.
+	Reclaim(bytes uint64) error
+
+	// Events returns a channel to receive the container events from,
+	// such as the init exit or OOM kills. See Event for details.
+	Events(ctx context.Context, triggers ...PSITrigger) (<-chan Event, error)
+
+	// SetRlimits sets the given resource limits of the container init, or,
+	// if all is true, of all the container processes, using prlimit(2).
+	// The limits are also saved in the container config, so they apply to
+	// the processes started afterwards. Other limits are left unchanged.
+	SetRlimits(rlimits []configs.Rlimit, all bool) error
+
+	// Stale returns whether the container state is stale, i.e. the container
+	// init is gone but its PID might now be used by another process: either
+	// the host has been rebooted since the container was created, or the
+	// process with the init PID has a different start time. A container with
+	// a stale state has the Stopped status.
+	Stale() (bool, error)
 }
 
 // ID returns the container's unique ID
@@ -155,18 +197,46 @@ func (c *linuxContainer) Processes() ([]int, error) {
 
 	pids, err = c.cgroupManager.GetAllPids()
 	if err != nil {
-		return nil, fmt.Errorf("unable to get all container pids: %w", err)
+		return nil, fmt.Errorf("unable to get all container pids: %w", c.cgroupError(err))
 	}
 	return pids, nil
 }
 
+// cgroupNotFoundError is an error which is both ErrCgroupNotFound and
+// the underlying err, for errors.Is and errors.As.
+type cgroupNotFoundError struct {
+	err error
+}
+
+func (e *cgroupNotFoundError) Error() string {
+	return ErrCgroupNotFound.Error() + ": " + e.err.Error()
+}
+
+func (e *cgroupNotFoundError) Is(target error) bool {
+	return target == ErrCgroupNotFound
+}
+
+func (e *cgroupNotFoundError) Unwrap() error {
+	return e.err
+}
+
+// cgroupError returns an error which is ErrCgroupNotFound (and still err)
+// if err happened because the container cgroup no longer exists, and err
+// otherwise.
+func (c *linuxContainer) cgroupError(err error) error {
+	if errors.Is(err, os.ErrNotExist) && !c.cgroupManager.Exists() {
+		return &cgroupNotFoundError{err: err}
+	}
+	return err
+}
+
 func (c *linuxContainer) Stats() (*Stats, error) {
 	var (
 		err   error
 		stats = &Stats{}
 	)
 	if stats.CgroupStats, err = c.cgroupManager.GetStats(); err != nil {
-		return stats, fmt.Errorf("unable to get container cgroup stats: %w", err)
+		return stats, fmt.Errorf("unable to get container cgroup stats: %w", c.cgroupError(err))
 	}
 	if c.intelRdtManager != nil {
 		if stats.IntelRdtStats, err = c.intelRdtManager.GetStats(); err != nil {
@@ -189,6 +259,39 @@ func (c *linuxContainer) Stats() (*Stats, error) {
 func (c *linuxContainer) Set(config configs.Config) error {
 	c.m.Lock()
 	defer c.m.Unlock()
+	return c.set(config)
+}
+
+func (c *linuxContainer) Update(resources *configs.Resources) error {
+	if resources == nil {
+		return errors.New("no resources to update")
+	}
+	c.m.Lock()
+	defer c.m.Unlock()
+	config := *c.config
+	cg := *config.Cgroups
+	var r configs.Resources
+	if cg.Resources != nil {
+		r = *cg.Resources
+	}
+	mergeResources(&r, resources)
+	cg.Resources = &r
+	config.Cgroups = &cg
+	return c.set(config)
+}
+
+// mergeResources sets the fields of r which are set in update.
+func mergeResources(r, update *configs.Resources) {
+	dst := reflect.ValueOf(r).Elem()
+	src := reflect.ValueOf(update).Elem()
+	for i := 0; i < src.NumField(); i++ {
+		if f := src.Field(i); !f.IsZero() {
+			dst.Field(i).Set(f)
+		}
+	}
+}
+
+func (c *linuxContainer) set(config configs.Config) error {
 	status, err := c.currentStatus()
 	if err != nil {
 		return err
@@ -227,13 +330,17 @@ func (c *linuxContainer) Start(process *Process) error {
 	if c.config.Cgroups.Resources.SkipDevices {
 		return errors.New("can't start container with SkipDevices set")
 	}
-	if process.Init {
+	if err := validate.Capabilities(process.Capabilities); err != nil {
+		return fmt.Errorf("invalid process capabilities: %w", err)
+	}
+	useFifo := process.Init && process.StartPipe == nil
+	if useFifo {
 		if err := c.createExecFifo(); err != nil {
 			return err
 		}
 	}
 	if err := c.start(process); err != nil {
-		if process.Init {
+		if useFifo {
 			c.deleteExecFifo()
 		}
 		return err
@@ -258,8 +365,11 @@ func (c *linuxContainer) Exec() error {
 }
 
 func (c *linuxContainer) exec() error {
-	path := filepath.Join(c.root, execFifoFilename)
 	pid := c.initProcess.pid()
+	if c.startPipe != nil {
+		return c.startPipe.start(pid)
+	}
+	path := filepath.Join(c.root, execFifoFilename)
 	blockingFifoOpenCh := awaitFifoOpen(path)
 	for {
 		select {
@@ -352,14 +462,16 @@ func (c *linuxContainer) start(process *Process) (retErr error) {
 	}
 
 	if process.Init {
-		c.fifo.Close()
+		if c.fifo != nil {
+			c.fifo.Close()
+		}
 		if c.config.Hooks != nil {
 			s, err := c.currentOCIState()
 			if err != nil {
 				return err
 			}
 
-			if err := c.config.Hooks[configs.Poststart].RunHooks(s); err != nil {
+			if err := c.config.Hooks.Run(configs.Poststart, s); err != nil {
 				if err := ignoreTerminateErrors(parent.terminate()); err != nil {
 					logrus.Warn(fmt.Errorf("error running poststart hook: %w", err))
 				}
@@ -384,6 +496,21 @@ func (c *linuxContainer) Signal(s os.Signal, all bool) error {
 		}
 		return signalAllProcesses(c.cgroupManager, s)
 	}
+	// When the container has its own PID namespace, killing its init with
+	// SIGKILL also kills all the other processes in that namespace (see
+	// pid_namespaces(7)). Otherwise, they would be left running, so kill
+	// all the processes in the container cgroup instead.
+	if sig, ok := s.(unix.Signal); ok && sig == unix.SIGKILL && !c.config.Namespaces.IsPrivate(configs.NEWPID) {
+		if status == Stopped && !c.cgroupManager.Exists() {
+			return ErrNotRunning
+		}
+		err := signalAllProcesses(c.cgroupManager, unix.SIGKILL)
+		if err == nil {
+			return nil
+		}
+		// Do not leave init running, at least.
+		logrus.Warnf("unable to kill all processes, killing init only: %v", err)
+	}
 	// to avoid a PID reuse attack
 	if status == Running || status == Created || status == Paused {
 		if err := c.initProcess.signal(s); err != nil {
@@ -471,7 +598,11 @@ func (c *linuxContainer) newParentProcess(p *Process) (parentProcess, error) {
 	// for container rootfs escape (and not doing it in `runc exec` avoided
 	// that problem), but we no longer do that. However, there's no need to do
 	// this for `runc exec` so we just keep it this way to be safe.
-	if err := c.includeExecFifo(cmd); err != nil {
+	if p.StartPipe != nil {
+		if err := c.includeStartPipe(cmd, p.StartPipe); err != nil {
+			return nil, fmt.Errorf("unable to setup start pipe: %w", err)
+		}
+	} else if err := c.includeExecFifo(cmd); err != nil {
 		return nil, fmt.Errorf("unable to setup exec fifo: %w", err)
 	}
 	return c.newInitProcess(p, cmd, messageSockPair, logFilePair)
@@ -543,6 +674,74 @@ func (c *linuxContainer) shouldSendMountSources() bool {
 	return false
 }
 
+// detachedMounts clones the bind mounts in the host mount namespace, using
+// the new mount API (see detachedMount), so init only needs to attach them
+// to the container rootfs. The clones are added to cmd.ExtraFiles, and their
+// fd numbers are passed to init in _LIBCONTAINER_DETACHED_FDS.
+//
+// Bind mounts with a source inside the container rootfs are left to init
+// to mount, as before, since their source may be on top of (or be created
+// by) an earlier container mount, which the host does not see. The same is
+// done for all bind mounts other than id-mapped ones if open_tree(2) is not
+// available.
+func (c *linuxContainer) detachedMounts(cmd *exec.Cmd) (_ []*os.File, Err error) {
+	if !c.config.Namespaces.Contains(configs.NEWNS) {
+		return nil, nil
+	}
+	var files []*os.File
+	defer func() {
+		if Err != nil {
+			for _, f := range files {
+				_ = f.Close()
+			}
+		}
+	}()
+
+	// Elements on this slice are paired with mounts, as mountFds in
+	// newInitProcess. It MUST have the same size as c.config.Mounts.
+	fds := make([]int, len(c.config.Mounts))
+	// Cloning a mount requires CAP_SYS_ADMIN.
+	useOpenTree := !c.config.RootlessEUID
+	for i, m := range c.config.Mounts {
+		fds[i] = -1
+		if !m.IsBind() {
+			continue
+		}
+		if !m.IsIDMapped() && (!useOpenTree || c.inRootfs(m.Source)) {
+			continue
+		}
+		f, err := detachedMount(m)
+		if err != nil {
+			if !m.IsIDMapped() && (errors.Is(err, unix.ENOSYS) || errors.Is(err, unix.EPERM)) {
+				logrus.Debugf("unable to use open_tree for bind mounts, falling back to mount: %v", err)
+				useOpenTree = false
+				continue
+			}
+			return nil, fmt.Errorf("error creating detached mount for %q: %w", m.Destination, err)
+		}
+		files = append(files, f)
+		cmd.ExtraFiles = append(cmd.ExtraFiles, f)
+		fds[i] = stdioFdCount + len(cmd.ExtraFiles) - 1
+	}
+	if len(files) == 0 {
+		return nil, nil
+	}
+
+	fdsJSON, err := json.Marshal(fds)
+	if err != nil {
+		return nil, fmt.Errorf("Error creating _LIBCONTAINER_DETACHED_FDS: %w", err)
+	}
+	cmd.Env = append(cmd.Env, "_LIBCONTAINER_DETACHED_FDS="+string(fdsJSON))
+
+	return files, nil
+}
+
+// inRootfs checks whether path (on the host) is inside the container rootfs.
+func (c *linuxContainer) inRootfs(path string) bool {
+	rel, err := filepath.Rel(c.config.Rootfs, filepath.Clean(path))
+	return err == nil && rel != ".." && !strings.HasPrefix(rel, "../")
+}
+
 func (c *linuxContainer) newInitProcess(p *Process, cmd *exec.Cmd, messageSockPair, logFilePair filePair) (*initProcess, error) {
 	cmd.Env = append(cmd.Env, "_LIBCONTAINER_INITTYPE="+string(initStandard))
 	nsMaps := make(map[configs.NamespaceType]string)
@@ -586,6 +785,11 @@ func (c *linuxContainer) newInitProcess(p *Process, cmd *exec.Cmd, messageSockPa
 		)
 	}
 
+	detachedFiles, err := c.detachedMounts(cmd)
+	if err != nil {
+		return nil, err
+	}
+
 	init := &initProcess{
 		cmd:             cmd,
 		messageSockPair: messageSockPair,
@@ -597,6 +801,7 @@ func (c *linuxContainer) newInitProcess(p *Process, cmd *exec.Cmd, messageSockPa
 		process:         p,
 		bootstrapData:   data,
 		sharePidns:      sharePidns,
+		detachedFiles:   detachedFiles,
 	}
 	c.initProcess = init
 	return init, nil
@@ -670,12 +875,19 @@ func (c *linuxContainer) newInitConfig(process *Process) *initConfig {
 		Capabilities:     process.Capabilities,
 		PassedFilesCount: len(process.ExtraFiles),
 		ContainerID:      c.ID(),
+		SyncVersion:      syncVersion,
+		SyncMinVersion:   syncMinVersion,
+		SyncFeatures:     syncFeatures,
 		NoNewPrivileges:  c.config.NoNewPrivileges,
 		RootlessEUID:     c.config.RootlessEUID,
 		RootlessCgroups:  c.config.RootlessCgroups,
 		AppArmorProfile:  c.config.AppArmorProfile,
 		ProcessLabel:     c.config.ProcessLabel,
 		Rlimits:          c.config.Rlimits,
+		Scheduler:        c.config.Scheduler,
+		IOPriority:       c.config.IOPriority,
+		Umask:            c.config.Umask,
+		CPUAffinity:      c.config.ExecCPUAffinity,
 		CreateConsole:    process.ConsoleSocket != nil,
 		ConsoleWidth:     process.ConsoleWidth,
 		ConsoleHeight:    process.ConsoleHeight,
@@ -692,6 +904,18 @@ func (c *linuxContainer) newInitConfig(process *Process) *initConfig {
 	if len(process.Rlimits) > 0 {
 		cfg.Rlimits = process.Rlimits
 	}
+	if process.Scheduler != nil {
+		cfg.Scheduler = process.Scheduler
+	}
+	if process.IOPriority != nil {
+		cfg.IOPriority = process.IOPriority
+	}
+	if process.Umask != nil {
+		cfg.Umask = process.Umask
+	}
+	if process.CPUAffinity != nil {
+		cfg.CPUAffinity = process.CPUAffinity
+	}
 	if cgroups.IsCgroup2UnifiedMode() {
 		cfg.Cgroup2Path = c.cgroupManager.Path("")
 	}
@@ -715,7 +939,7 @@ func (c *linuxContainer) Pause() error {
 	switch status {
 	case Running, Created:
 		if err := c.cgroupManager.Freeze(configs.Frozen); err != nil {
-			return err
+			return c.cgroupError(err)
 		}
 		return c.state.transition(&pausedState{
 			c: c,
@@ -735,7 +959,7 @@ func (c *linuxContainer) Resume() error {
 		return ErrNotPaused
 	}
 	if err := c.cgroupManager.Freeze(configs.Thawed); err != nil {
-		return err
+		return c.cgroupError(err)
 	}
 	return c.state.transition(&runningState{
 		c: c,
@@ -762,6 +986,88 @@ func (c *linuxContainer) NotifyMemoryPressure(level PressureLevel) (<-chan struc
 	return notifyMemoryPressure(c.cgroupManager.Path("memory"), level)
 }
 
+func (c *linuxContainer) NotifyMemoryThreshold(threshold uint64) (<-chan struct{}, error) {
+	if cgroups.IsCgroup2UnifiedMode() {
+		return nil, errors.New("memory threshold notifications are only supported on cgroup v1")
+	}
+	// XXX(cyphar): This requires cgroups.
+	if c.config.RootlessCgroups {
+		logrus.Warn("getting memory threshold notifications may fail if you don't have the full access to cgroups")
+	}
+	return notifyMemoryThreshold(c.cgroupManager.Path("memory"), threshold)
+}
+
+func (c *linuxContainer) NotifyPressure(ctx context.Context, trigger PSITrigger) (<-chan struct{}, error) {
+	if !cgroups.IsCgroup2UnifiedMode() {
+		return nil, errors.New("PSI triggers are only supported on cgroup v2")
+	}
+	if c.config.RootlessCgroups {
+		logrus.Warn("getting pressure notifications may fail if you don't have the full access to cgroups")
+	}
+	return notifyPressure(ctx, c.cgroupManager.Path(""), trigger)
+}
+
+func (c *linuxContainer) Reclaim(bytes uint64) error {
+	c.m.Lock()
+	defer c.m.Unlock()
+	status, err := c.currentStatus()
+	if err != nil {
+		return err
+	}
+	if status == Stopped {
+		return ErrNotRunning
+	}
+	return c.cgroupManager.Reclaim(bytes)
+}
+
+func (c *linuxContainer) SetRlimits(rlimits []configs.Rlimit, all bool) error {
+	c.m.Lock()
+	defer c.m.Unlock()
+	status, err := c.currentStatus()
+	if err != nil {
+		return err
+	}
+	if status == Stopped {
+		return ErrNotRunning
+	}
+	pids := []int{c.initProcess.pid()}
+	if all {
+		if pids, err = c.cgroupManager.GetAllPids(); err != nil {
+			return err
+		}
+	}
+	for _, pid := range pids {
+		// A process may exit in the meantime.
+		if err := setupRlimits(rlimits, pid); err != nil && !errors.Is(err, unix.ESRCH) {
+			return fmt.Errorf("pid %d: %w", pid, err)
+		}
+	}
+	config := *c.config
+	config.Rlimits = mergeRlimits(c.config.Rlimits, rlimits)
+	c.config = &config
+	_, err = c.updateState(nil)
+	return err
+}
+
+// mergeRlimits returns rlimits with the limits of the same type replaced by
+// the ones from update, and the others from update appended.
+func mergeRlimits(rlimits, update []configs.Rlimit) []configs.Rlimit {
+	res := make([]configs.Rlimit, 0, len(rlimits)+len(update))
+	for _, rl := range rlimits {
+		updated := false
+		for _, u := range update {
+			if u.Type == rl.Type {
+				updated = true
+				break
+			}
+		}
+		if !updated {
+			res = append(res, rl)
+		}
+	}
+	return append(res, update...)
+}
+
 var criuFeatures *criurpc.CriuFeatures
 
 func (c *linuxContainer) checkCriuFeatures(criuOpts *CriuOpts, rpcOpts *criurpc.CriuOpts, criuFeat *criurpc.CriuFeatures) error {
@@ -865,7 +1171,7 @@ func (c *linuxContainer) addMaskPaths(req *criurpc.CriuReq) error {
 			}
 			return err
 		}
-		if fi.IsDir() {
+		if fi.IsDir() || c.config.MaskPathModes[path] == configs.MaskReadonly {
 			continue
 		}
 
@@ -1825,10 +2131,10 @@ func (c *linuxContainer) criuNotifications(resp *criurpc.CriuResp, process *Proc
 			}
 			s.Pid = int(notify.GetPid())
 
-			if err := c.config.Hooks[configs.Prestart].RunHooks(s); err != nil {
+			if err := c.config.Hooks.Run(configs.Prestart, s); err != nil {
 				return err
 			}
-			if err := c.config.Hooks[configs.CreateRuntime].RunHooks(s); err != nil {
+			if err := c.config.Hooks.Run(configs.CreateRuntime, s); err != nil {
 				return err
 			}
 		}
@@ -1963,8 +2269,27 @@ func (c *linuxContainer) refreshState() error {
 	return c.state.transition(&stoppedState{c: c})
 }
 
-func (c *linuxContainer) runType() Status {
+func (c *linuxContainer) Stale() (bool, error) {
+	c.m.Lock()
+	defer c.m.Unlock()
 	if c.initProcess == nil {
+		return false, nil
+	}
+	if c.rebooted {
+		return true, nil
+	}
+	stat, err := system.Stat(c.initProcess.pid())
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return false, nil
+		}
+		return false, err
+	}
+	return stat.StartTime != c.initProcessStartTime, nil
+}
+
+func (c *linuxContainer) runType() Status {
+	if c.initProcess == nil || c.rebooted {
 		return Stopped
 	}
 	pid := c.initProcess.pid()
@@ -1975,6 +2300,12 @@ func (c *linuxContainer) runType() Status {
 	if stat.StartTime != c.initProcessStartTime || stat.State == system.Zombie || stat.State == system.Dead {
 		return Stopped
 	}
+	if c.startPipe != nil {
+		if c.startPipe.waiting(pid) {
+			return Created
+		}
+		return Running
+	}
 	// We'll create exec fifo and blocking on it after container is created,
 	// and delete it after start container.
 	if _, err := os.Stat(filepath.Join(c.root, execFifoFilename)); err == nil {
@@ -2013,11 +2344,13 @@ func (c *linuxContainer) currentState() (*State, error) {
 			Config:               *c.config,
 			InitProcessPid:       pid,
 			InitProcessStartTime: startTime,
+			BootID:               c.bootID,
 			Created:              c.created,
 		},
 		Rootless:            c.config.RootlessEUID && c.config.RootlessCgroups,
 		CgroupPaths:         c.cgroupManager.GetPaths(),
 		IntelRdtPath:        intelRdtPath,
+		StartPipe:           c.startPipe,
 		NamespacePaths:      make(map[configs.NamespaceType]string),
 		ExternalDescriptors: externalDescriptors,
 	}
@@ -2103,6 +2436,23 @@ func encodeIDMapping(idMap []configs.IDMap) ([]byte, error) {
 	return data.Bytes(), nil
 }
 
+// encodeTimeOffsets encodes clock offsets in the format expected by
+// /proc/self/timens_offsets, i.e. one "<clock> <secs> <nanosecs>" line
+// per clock, sorted by clock name.
+func encodeTimeOffsets(offsets map[string]configs.TimeOffset) []byte {
+	clocks := make([]string, 0, len(offsets))
+	for clock := range offsets {
+		clocks = append(clocks, clock)
+	}
+	sort.Strings(clocks)
+	data := bytes.NewBuffer(nil)
+	for _, clock := range clocks {
+		off := offsets[clock]
+		fmt.Fprintf(data, "%s %d %d\n", clock, off.Secs, off.Nanosecs)
+	}
+	return data.Bytes()
+}
+
 // netlinkError is an error wrapper type for use by custom netlink message
 // types. Panics with errors are wrapped in netlinkError so that the recover
 // in bootstrapData can distinguish intentional panics.
@@ -2235,6 +2585,14 @@ func (c *linuxContainer) bootstrapData(cloneFlags uintptr, nsMaps map[configs.Na
 		})
 	}
 
+	// write clock offsets for a new time namespace
+	if it == initStandard && cloneFlags&unix.CLONE_NEWTIME != 0 && len(c.config.TimeOffsets) > 0 {
+		r.AddData(&Bytemsg{
+			Type:  TimeOffsetsAttr,
+			Value: encodeTimeOffsets(c.config.TimeOffsets),
+		})
+	}
+
 	return bytes.NewReader(r.Serialize()), nil
 }
 
@@ -1,13 +1,16 @@
 package libcontainer
 
 import (
+	"errors"
 	"fmt"
 	"os"
+	"reflect"
 	"testing"
 
 	"github.com/opencontainers/runc/libcontainer/cgroups"
 	"github.com/opencontainers/runc/libcontainer/configs"
 	"github.com/opencontainers/runc/libcontainer/system"
+	"golang.org/x/sys/unix"
 )
 
 type mockCgroupManager struct {
@@ -49,6 +52,14 @@ func (m *mockCgroupManager) OOMKillCount() (uint64, error) {
 	return 0, nil
 }
 
+func (m *mockCgroupManager) Reclaim(_ uint64) error {
+	return nil
+}
+
+func (m *mockCgroupManager) CgroupFD() (*os.File, error) {
+	return nil, cgroups.ErrNoCgroupFD
+}
+
 func (m *mockCgroupManager) GetPaths() map[string]string {
 	return m.paths
 }
@@ -142,6 +153,49 @@ func TestGetContainerPids(t *testing.T) {
 	}
 }
 
+func TestCgroupError(t *testing.T) {
+	container := &linuxContainer{
+		cgroupManager: &mockCgroupManager{
+			paths: map[string]string{
+				"devices": "/nonexistent/cgroup",
+			},
+		},
+	}
+	cause := &os.PathError{Op: "open", Path: "/nonexistent/cgroup/cgroup.procs", Err: unix.ENOENT}
+	err := container.cgroupError(cause)
+	if !errors.Is(err, ErrCgroupNotFound) {
+		t.Errorf("expected ErrCgroupNotFound, got %v", err)
+	}
+	var pathErr *os.PathError
+	if !errors.Is(err, os.ErrNotExist) || !errors.As(err, &pathErr) || pathErr != cause {
+		t.Errorf("expected the cause to be kept, got %v", err)
+	}
+
+	// Other errors are returned as is.
+	other := errors.New("some error")
+	if err := container.cgroupError(other); err != other {
+		t.Errorf("expected %v, got %v", other, err)
+	}
+}
+
+func TestInRootfs(t *testing.T) {
+	c := &linuxContainer{config: &configs.Config{Rootfs: "/var/lib/rootfs"}}
+	for path, exp := range map[string]bool{
+		"/var/lib/rootfs":            true,
+		"/var/lib/rootfs/":           true,
+		"/var/lib/rootfs/mnt/data":   true,
+		"/var/lib/rootfs/../rootfs2": false,
+		"/var/lib/rootfs2":           false,
+		"/var/lib":                   false,
+		"/data":                      false,
+		"/var/lib/rootfs/..foo":      true,
+	} {
+		if got := c.inRootfs(path); got != exp {
+			t.Errorf("%s: expected %v, got %v", path, exp, got)
+		}
+	}
+}
+
 func TestGetContainerState(t *testing.T) {
 	var (
 		pid                 = os.Getpid()
@@ -286,3 +340,44 @@ func TestGetContainerStateAfterUpdate(t *testing.T) {
 		t.Fatalf("expected Memory to be 2048 but received %q", state.Config.Cgroups.Memory)
 	}
 }
+
+func TestMergeResources(t *testing.T) {
+	swappiness := uint64(10)
+	r := &configs.Resources{
+		Memory:    1 << 30,
+		CpuShares: 1024,
+		PidsLimit: 100,
+	}
+	mergeResources(r, &configs.Resources{
+		Memory:           2 << 30,
+		MemorySwappiness: &swappiness,
+	})
+
+	if r.Memory != 2<<30 {
+		t.Errorf("expected memory %d, got %d", 2<<30, r.Memory)
+	}
+	if r.MemorySwappiness == nil || *r.MemorySwappiness != swappiness {
+		t.Errorf("expected swappiness %d, got %v", swappiness, r.MemorySwappiness)
+	}
+	if r.CpuShares != 1024 || r.PidsLimit != 100 {
+		t.Errorf("unset fields changed: %+v", r)
+	}
+}
+
+func TestMergeRlimits(t *testing.T) {
+	rlimits := mergeRlimits([]configs.Rlimit{
+		{Type: unix.RLIMIT_NOFILE, Soft: 1024, Hard: 1024},
+		{Type: unix.RLIMIT_CORE, Soft: 0, Hard: 0},
+	}, []configs.Rlimit{
+		{Type: unix.RLIMIT_NOFILE, Soft: 2048, Hard: 4096},
+		{Type: unix.RLIMIT_NPROC, Soft: 100, Hard: 100},
+	})
+	exp := []configs.Rlimit{
+		{Type: unix.RLIMIT_CORE, Soft: 0, Hard: 0},
+		{Type: unix.RLIMIT_NOFILE, Soft: 2048, Hard: 4096},
+		{Type: unix.RLIMIT_NPROC, Soft: 100, Hard: 100},
+	}
+	if !reflect.DeepEqual(rlimits, exp) {
+		t.Errorf("expected %+v, got %+v", exp, rlimits)
+	}
+}
@@ -1,6 +1,10 @@
 package libcontainer
 
-import "errors"
+import (
+	"errors"
+
+	"github.com/opencontainers/runc/libcontainer/configs/validate"
+)
 
 var (
 	ErrExist      = errors.New("container with given ID already exists")
@@ -10,4 +14,12 @@ var (
 	ErrRunning    = errors.New("container still running")
 	ErrNotRunning = errors.New("container not running")
 	ErrNotPaused  = errors.New("container not paused")
+
+	// ErrCgroupNotFound is returned (wrapped) if the container cgroup
+	// has unexpectedly disappeared, e.g. was removed by someone else.
+	ErrCgroupNotFound = errors.New("container cgroup not found")
 )
+
+// ConfigError is returned by Factory.Create if the container config is
+// invalid. Use errors.As to get the name of the offending field.
+type ConfigError = validate.ConfigError
@@ -0,0 +1,397 @@
+package libcontainer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+	"unsafe"
+
+	"github.com/sirupsen/logrus"
+	"golang.org/x/sys/unix"
+
+	"github.com/opencontainers/runc/libcontainer/cgroups"
+	"github.com/opencontainers/runc/libcontainer/cgroups/fscommon"
+	"github.com/opencontainers/runc/libcontainer/cgroups/systemd"
+	"github.com/opencontainers/runc/libcontainer/system"
+)
+
+// EventType is the type of a container Event.
+type EventType int
+
+const (
+	// EventInitExit means the container init has exited.
+	EventInitExit EventType = iota + 1
+	// EventOOMKill means a container process was killed by the OOM killer.
+	EventOOMKill
+	// EventPressure means a PSI trigger has fired, see Event.Trigger.
+	EventPressure
+	// EventFrozen means the container processes have been frozen.
+	// Only reported on cgroup v2.
+	EventFrozen
+	// EventThawed means the container processes have been thawed.
+	// Only reported on cgroup v2.
+	EventThawed
+	// EventCgroupRemoved means the container cgroup has been removed.
+	EventCgroupRemoved
+	// EventUnitStopped means the container systemd unit has been stopped,
+	// or has failed. Only reported by the systemd cgroup driver.
+	EventUnitStopped
+	// EventUnitOOMKilled means the container systemd unit has been
+	// terminated because of OOM. Only reported by the systemd cgroup driver.
+	EventUnitOOMKilled
+	// EventUnitRemoved means the container systemd unit has been unloaded
+	// by systemd. Only reported by the systemd cgroup driver.
+	EventUnitRemoved
+)
+
+var eventTypeNames = map[EventType]string{
+	EventInitExit:      "init-exit",
+	EventOOMKill:       "oom-kill",
+	EventPressure:      "pressure",
+	EventFrozen:        "frozen",
+	EventThawed:        "thawed",
+	EventCgroupRemoved: "cgroup-removed",
+	EventUnitStopped:   "unit-stopped",
+	EventUnitOOMKilled: "unit-oom-killed",
+	EventUnitRemoved:   "unit-removed",
+}
+
+var unitEventTypes = map[systemd.UnitEventType]EventType{
+	systemd.UnitStopped:   EventUnitStopped,
+	systemd.UnitOOMKilled: EventUnitOOMKilled,
+	systemd.UnitRemoved:   EventUnitRemoved,
+}
+
+func (t EventType) String() string {
+	if name, ok := eventTypeNames[t]; ok {
+		return name
+	}
+	return fmt.Sprintf("EventType(%d)", int(t))
+}
+
+// Event is a change of the container state, as reported by Container.Events.
+type Event struct {
+	Type EventType
+	// Trigger is the PSI trigger which has fired, for EventPressure.
+	Trigger *PSITrigger
+}
+
+// Events returns a channel to receive the container events from. In
+// addition to the events which are always reported, EventPressure is
+// reported every time one of the given PSI triggers fires (cgroup v2 only).
+//
+// The channel is closed once ctx is done, or once there can be no more
+// events, i.e. the container init has exited and its cgroup was removed.
+func (c *linuxContainer) Events(ctx context.Context, triggers ...PSITrigger) (_ <-chan Event, Err error) {
+	c.m.Lock()
+	defer c.m.Unlock()
+	status, err := c.currentStatus()
+	if err != nil {
+		return nil, err
+	}
+	if status == Stopped {
+		return nil, ErrNotRunning
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer func() {
+		if Err != nil {
+			cancel()
+		}
+	}()
+	var pressure []<-chan struct{}
+	for _, t := range triggers {
+		if !cgroups.IsCgroup2UnifiedMode() {
+			return nil, errors.New("PSI triggers are only supported on cgroup v2")
+		}
+		ch, err := notifyPressure(ctx, c.cgroupManager.Path(""), t)
+		if err != nil {
+			return nil, err
+		}
+		pressure = append(pressure, ch)
+	}
+	oom, err := c.NotifyOOM()
+	if err != nil {
+		return nil, err
+	}
+	startTime, err := c.initProcess.startTime()
+	if err != nil {
+		return nil, err
+	}
+	var units <-chan systemd.UnitEvent
+	if w, ok := c.cgroupManager.(systemd.UnitWatcher); ok {
+		units, err = w.WatchUnit(ctx)
+		if err != nil {
+			logrus.Warnf("unable to watch systemd unit: %v", err)
+		}
+	}
+
+	var (
+		events = make(chan Event)
+		wg     sync.WaitGroup
+		// unitWg is for the systemd unit watcher, which (as the unit
+		// may outlive the container) only stops once ctx is done.
+		unitWg sync.WaitGroup
+	)
+	send := func(ev Event) bool {
+		select {
+		case events <- ev:
+			return true
+		case <-ctx.Done():
+			return false
+		}
+	}
+	// forward sends ev every time the OOM notifier ch fires, until ch is
+	// closed, after which done (if not nil) is called.
+	forward := func(ch <-chan struct{}, ev Event, done func()) {
+		defer wg.Done()
+		for {
+			select {
+			case _, ok := <-ch:
+				if !ok {
+					if done != nil {
+						done()
+					}
+					return
+				}
+				if !send(ev) {
+					return
+				}
+			case <-ctx.Done():
+				// The OOM notifier can't be stopped, so keep
+				// reading until it is done with the cgroup.
+				go func() {
+					for range ch {
+					}
+				}()
+				return
+			}
+		}
+	}
+
+	wg.Add(len(pressure))
+	for i, ch := range pressure {
+		ev := Event{Type: EventPressure, Trigger: &triggers[i]}
+		go func(ch <-chan struct{}) {
+			defer wg.Done()
+			// The channel is closed once ctx is done.
+			for range ch {
+				if !send(ev) {
+					return
+				}
+			}
+		}(ch)
+	}
+	if cgroups.IsCgroup2UnifiedMode() {
+		wg.Add(2)
+		go forward(oom, Event{Type: EventOOMKill}, nil)
+		go func() {
+			defer wg.Done()
+			if err := watchCgroupV2Events(ctx, c.cgroupManager.Path(""), send); err != nil {
+				logrus.Warnf("unable to watch cgroup events: %v", err)
+			}
+		}()
+	} else {
+		// The memory cgroup eventfd is closed once the cgroup is removed.
+		wg.Add(1)
+		go forward(oom, Event{Type: EventOOMKill}, func() {
+			send(Event{Type: EventCgroupRemoved})
+		})
+	}
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		if err := waitProcessExit(ctx, c.initProcess.pid(), startTime); err != nil {
+			if !errors.Is(err, context.Canceled) {
+				logrus.Warnf("unable to wait for container init: %v", err)
+			}
+			return
+		}
+		send(Event{Type: EventInitExit})
+	}()
+	if units != nil {
+		unitWg.Add(1)
+		go func() {
+			defer unitWg.Done()
+			for ev := range units {
+				if !send(Event{Type: unitEventTypes[ev.Type]}) {
+					return
+				}
+			}
+		}()
+	}
+	go func() {
+		wg.Wait()
+		cancel()
+		unitWg.Wait()
+		close(events)
+	}()
+
+	return events, nil
+}
+
+// cancelFd returns a file descriptor which is readable once ctx is done,
+// for use with poll(2), and a function to release it.
+func cancelFd(ctx context.Context) (int, func(), error) {
+	r, w, err := os.Pipe()
+	if err != nil {
+		return -1, nil, err
+	}
+	released := make(chan struct{})
+	go func() {
+		select {
+		case <-ctx.Done():
+		case <-released:
+		}
+		w.Close()
+	}()
+	return int(r.Fd()), func() {
+		close(released)
+		r.Close()
+	}, nil
+}
+
+// pollCancel waits for fd to be ready for events, or for ctx to be done.
+// It returns ctx.Err() in the latter case.
+func pollCancel(ctx context.Context, cfd, fd int, events int16) error {
+	for {
+		fds := []unix.PollFd{
+			{Fd: int32(fd), Events: events},
+			{Fd: int32(cfd), Events: unix.POLLIN},
+		}
+		_, err := unix.Poll(fds, -1)
+		if errors.Is(err, unix.EINTR) {
+			continue
+		}
+		if err != nil {
+			return os.NewSyscallError("poll", err)
+		}
+		if fds[1].Revents != 0 {
+			return ctx.Err()
+		}
+		return nil
+	}
+}
+
+// waitProcessExit waits until the process with the given pid and start
+// time exits, or ctx is done.
+func waitProcessExit(ctx context.Context, pid int, startTime uint64) error {
+	exited := func() bool {
+		stat, err := system.Stat(pid)
+		return err != nil || stat.StartTime != startTime || stat.State == system.Zombie || stat.State == system.Dead
+	}
+	pidfd, err := unix.PidfdOpen(pid, 0)
+	if err != nil {
+		if errors.Is(err, unix.ESRCH) {
+			return nil
+		}
+		if !errors.Is(err, unix.ENOSYS) {
+			return os.NewSyscallError("pidfd_open", err)
+		}
+		// No pidfd support, fall back to polling.
+		ticker := time.NewTicker(time.Second)
+		defer ticker.Stop()
+		for !exited() {
+			select {
+			case <-ticker.C:
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		}
+		return nil
+	}
+	defer unix.Close(pidfd)
+	// The pidfd refers to the same process from now on, so check that it
+	// is the right one (rather than a new process which reused its PID).
+	if exited() {
+		return nil
+	}
+	cfd, release, err := cancelFd(ctx)
+	if err != nil {
+		return err
+	}
+	defer release()
+	// A pidfd becomes readable once the process exits.
+	return pollCancel(ctx, cfd, pidfd, unix.POLLIN)
+}
+
+// watchCgroupV2Events watches the cgroup v2 cgroup.events file and the
+// cgroup directory using inotify, sending EventFrozen and EventThawed events
+// on the freezer state transitions, and EventCgroupRemoved once the cgroup
+// is removed. It returns once the cgroup is removed, or ctx is done.
+func watchCgroupV2Events(ctx context.Context, dir string, send func(Event) bool) error {
+	fd, err := unix.InotifyInit1(unix.IN_CLOEXEC | unix.IN_NONBLOCK)
+	if err != nil {
+		return fmt.Errorf("unable to init inotify: %w", err)
+	}
+	defer unix.Close(fd)
+	dirWd, err := unix.InotifyAddWatch(fd, dir, unix.IN_DELETE_SELF)
+	if err != nil {
+		return fmt.Errorf("unable to add inotify watch: %w", err)
+	}
+	if _, err := unix.InotifyAddWatch(fd, filepath.Join(dir, "cgroup.events"), unix.IN_MODIFY); err != nil {
+		return fmt.Errorf("unable to add inotify watch: %w", err)
+	}
+	frozen, err := fscommon.GetValueByKey(dir, "cgroup.events", "frozen")
+	if err != nil {
+		return err
+	}
+	cfd, release, err := cancelFd(ctx)
+	if err != nil {
+		return err
+	}
+	defer release()
+
+	var buffer [unix.SizeofInotifyEvent + unix.PathMax + 1]byte
+	for {
+		if err := pollCancel(ctx, cfd, fd, unix.POLLIN); err != nil {
+			if errors.Is(err, context.Canceled) {
+				return nil
+			}
+			return err
+		}
+		n, err := unix.Read(fd, buffer[:])
+		if errors.Is(err, unix.EAGAIN) || errors.Is(err, unix.EINTR) {
+			continue
+		}
+		if err != nil {
+			return fmt.Errorf("unable to read inotify events: %w", err)
+		}
+		removed, modified := false, false
+		for offset := 0; offset+unix.SizeofInotifyEvent <= n; {
+			ev := (*unix.InotifyEvent)(unsafe.Pointer(&buffer[offset]))
+			offset += unix.SizeofInotifyEvent + int(ev.Len)
+			if int(ev.Wd) == dirWd && ev.Mask&(unix.IN_DELETE_SELF|unix.IN_IGNORED) != 0 {
+				removed = true
+			} else if ev.Mask&unix.IN_MODIFY != 0 {
+				modified = true
+			}
+		}
+		if removed {
+			send(Event{Type: EventCgroupRemoved})
+			return nil
+		}
+		if !modified {
+			continue
+		}
+		f, err := fscommon.GetValueByKey(dir, "cgroup.events", "frozen")
+		if err != nil {
+			// The cgroup is being removed.
+			continue
+		}
+		if f != frozen {
+			frozen = f
+			ev := Event{Type: EventThawed}
+			if frozen == 1 {
+				ev.Type = EventFrozen
+			}
+			if !send(ev) {
+				return nil
+			}
+		}
+	}
+}
@@ -0,0 +1,108 @@
+package libcontainer
+
+import (
+	"context"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/opencontainers/runc/libcontainer/cgroups"
+	"github.com/opencontainers/runc/libcontainer/system"
+)
+
+func TestWatchCgroupV2Events(t *testing.T) {
+	cgroups.TestMode = true
+	defer func() { cgroups.TestMode = false }()
+
+	dir := filepath.Join(t.TempDir(), "cg")
+	if err := os.Mkdir(dir, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	eventsFile := filepath.Join(dir, "cgroup.events")
+	if err := os.WriteFile(eventsFile, []byte("populated 1\nfrozen 0\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	events := make(chan Event, 10)
+	errCh := make(chan error, 1)
+	send := func(ev Event) bool {
+		events <- ev
+		return true
+	}
+	go func() {
+		errCh <- watchCgroupV2Events(context.Background(), dir, send)
+	}()
+	expect := func(typ EventType) {
+		t.Helper()
+		select {
+		case ev := <-events:
+			if ev.Type != typ {
+				t.Fatalf("expected %s event, got %s", typ, ev.Type)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatalf("timed out waiting for %s event", typ)
+		}
+	}
+	// Give the watcher some time to set the watches up.
+	time.Sleep(100 * time.Millisecond)
+
+	for _, tc := range []struct {
+		data string
+		typ  EventType
+	}{
+		{"populated 1\nfrozen 1\n", EventFrozen},
+		{"populated 1\nfrozen 0\n", EventThawed},
+	} {
+		if err := os.WriteFile(eventsFile, []byte(tc.data), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		expect(tc.typ)
+	}
+	if err := os.RemoveAll(dir); err != nil {
+		t.Fatal(err)
+	}
+	expect(EventCgroupRemoved)
+	if err := <-errCh; err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestWaitProcessExit(t *testing.T) {
+	cmd := exec.Command("sleep", "1h")
+	if err := cmd.Start(); err != nil {
+		t.Fatal(err)
+	}
+	pid := cmd.Process.Pid
+	stat, err := system.Stat(pid)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// A wrong start time means the process has already exited.
+	if err := waitProcessExit(context.Background(), pid, stat.StartTime+1); err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := waitProcessExit(ctx, pid, stat.StartTime); err != context.Canceled { //nolint:errorlint // the error is not wrapped
+		t.Fatalf("expected %v, got %v", context.Canceled, err)
+	}
+
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- waitProcessExit(context.Background(), pid, stat.StartTime)
+	}()
+	_ = cmd.Process.Kill()
+	select {
+	case err := <-errCh:
+		if err != nil {
+			t.Fatal(err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for the process to exit")
+	}
+	_ = cmd.Wait()
+}
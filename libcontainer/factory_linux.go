@@ -17,6 +17,7 @@ import (
 	"github.com/opencontainers/runc/libcontainer/configs"
 	"github.com/opencontainers/runc/libcontainer/configs/validate"
 	"github.com/opencontainers/runc/libcontainer/intelrdt"
+	"github.com/opencontainers/runc/libcontainer/system"
 	"github.com/opencontainers/runc/libcontainer/utils"
 	"github.com/sirupsen/logrus"
 )
@@ -128,12 +129,16 @@ func (l *LinuxFactory) Create(id string, config *configs.Config) (Container, err
 	if err := os.MkdirAll(containerRoot, 0o711); err != nil {
 		return nil, err
 	}
+	// If the boot ID can't be read, the container state can't be found
+	// to be stale after a reboot, but this is no reason to fail.
+	bootID, _ := system.BootID()
 	c := &linuxContainer{
 		id:              id,
 		root:            containerRoot,
 		config:          config,
 		cgroupManager:   cm,
 		intelRdtManager: intelrdt.NewManager(config, id, ""),
+		bootID:          bootID,
 	}
 	c.state = &stoppedState{c: c}
 	return c, nil
@@ -167,6 +172,8 @@ func (l *LinuxFactory) Load(id string) (Container, error) {
 	c := &linuxContainer{
 		initProcess:          r,
 		initProcessStartTime: state.InitProcessStartTime,
+		startPipe:            state.StartPipe,
+		bootID:               state.BootID,
 		id:                   id,
 		config:               &state.Config,
 		cgroupManager:        cm,
@@ -174,6 +181,13 @@ func (l *LinuxFactory) Load(id string) (Container, error) {
 		root:                 containerRoot,
 		created:              state.Created,
 	}
+	// After a reboot, the init PID (and even its start time) can be used
+	// by an unrelated process, so don't look at it.
+	if state.BootID != "" {
+		if bootID, err := system.BootID(); err == nil && bootID != state.BootID {
+			c.rebooted = true
+		}
+	}
 	c.state = &loadedState{c: c}
 	if err := c.refreshState(); err != nil {
 		return nil, err
@@ -212,14 +226,20 @@ func (l *LinuxFactory) StartInitialization() (err error) {
 		}
 	}()
 
-	// Only init processes have FIFOFD.
-	fifofd := -1
+	// Only init processes have FIFOFD (or STARTPIPE).
+	fifofd, startPipeFd := -1, -1
 	envInitType := os.Getenv("_LIBCONTAINER_INITTYPE")
 	it := initType(envInitType)
 	if it == initStandard {
-		envFifoFd := os.Getenv("_LIBCONTAINER_FIFOFD")
-		if fifofd, err = strconv.Atoi(envFifoFd); err != nil {
-			return fmt.Errorf("unable to convert _LIBCONTAINER_FIFOFD: %w", err)
+		if envStartPipe := os.Getenv("_LIBCONTAINER_STARTPIPE"); envStartPipe != "" {
+			if startPipeFd, err = strconv.Atoi(envStartPipe); err != nil {
+				return fmt.Errorf("unable to convert _LIBCONTAINER_STARTPIPE: %w", err)
+			}
+		} else {
+			envFifoFd := os.Getenv("_LIBCONTAINER_FIFOFD")
+			if fifofd, err = strconv.Atoi(envFifoFd); err != nil {
+				return fmt.Errorf("unable to convert _LIBCONTAINER_FIFOFD: %w", err)
+			}
 		}
 	}
 
@@ -240,7 +260,13 @@ func (l *LinuxFactory) StartInitialization() (err error) {
 	}
 
 	// Get mount files (O_PATH).
-	mountFds, err := parseMountFds()
+	mountSrcFds, err := parseFdsFromEnv("_LIBCONTAINER_MOUNT_FDS")
+	if err != nil {
+		return err
+	}
+
+	// Get detached mounts.
+	detachedFds, err := parseFdsFromEnv("_LIBCONTAINER_DETACHED_FDS")
 	if err != nil {
 		return err
 	}
@@ -260,7 +286,7 @@ func (l *LinuxFactory) StartInitialization() (err error) {
 		}
 	}()
 
-	i, err := newContainerInit(it, pipe, consoleSocket, fifofd, logPipeFd, mountFds)
+	i, err := newContainerInit(it, pipe, consoleSocket, fifofd, startPipeFd, logPipeFd, mountFds{sourceFds: mountSrcFds, detachedFds: detachedFds})
 	if err != nil {
 		return err
 	}
@@ -297,17 +323,17 @@ func (l *LinuxFactory) validateID(id string) error {
 	return nil
 }
 
-func parseMountFds() ([]int, error) {
-	fdsJSON := os.Getenv("_LIBCONTAINER_MOUNT_FDS")
+func parseFdsFromEnv(envVar string) ([]int, error) {
+	fdsJSON := os.Getenv(envVar)
 	if fdsJSON == "" {
 		// Always return the nil slice if no fd is present.
 		return nil, nil
 	}
 
-	var mountFds []int
-	if err := json.Unmarshal([]byte(fdsJSON), &mountFds); err != nil {
-		return nil, fmt.Errorf("Error unmarshalling _LIBCONTAINER_MOUNT_FDS: %w", err)
+	var fds []int
+	if err := json.Unmarshal([]byte(fdsJSON), &fds); err != nil {
+		return nil, fmt.Errorf("Error unmarshalling %s: %w", envVar, err)
 	}
 
-	return mountFds, nil
+	return fds, nil
 }
@@ -9,6 +9,7 @@ import (
 
 	"github.com/moby/sys/mountinfo"
 	"github.com/opencontainers/runc/libcontainer/configs"
+	"github.com/opencontainers/runc/libcontainer/system"
 	"github.com/opencontainers/runc/libcontainer/utils"
 	"github.com/opencontainers/runtime-spec/specs-go"
 
@@ -163,6 +164,73 @@ func TestFactoryLoadContainer(t *testing.T) {
 	}
 }
 
+func TestFactoryLoadStale(t *testing.T) {
+	bootID, err := system.BootID()
+	if err != nil {
+		t.Skipf("unable to get boot id: %v", err)
+	}
+	stat, err := system.Stat(os.Getpid())
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, tc := range []struct {
+		name      string
+		bootID    string
+		startTime uint64
+		stale     bool
+		status    Status
+	}{
+		{name: "running", bootID: bootID, startTime: stat.StartTime, status: Running},
+		{name: "no boot id", startTime: stat.StartTime, status: Running},
+		{name: "rebooted", bootID: "not-" + bootID, startTime: stat.StartTime, stale: true, status: Stopped},
+		{name: "pid reused", bootID: bootID, startTime: stat.StartTime + 1, stale: true, status: Stopped},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			root := t.TempDir()
+			state := &State{
+				BaseState: BaseState{
+					InitProcessPid:       os.Getpid(),
+					InitProcessStartTime: tc.startTime,
+					BootID:               tc.bootID,
+					Config: configs.Config{
+						Cgroups: &configs.Cgroup{
+							Resources: &configs.Resources{},
+						},
+					},
+				},
+			}
+			if err := os.Mkdir(filepath.Join(root, "1"), 0o700); err != nil {
+				t.Fatal(err)
+			}
+			if err := marshal(filepath.Join(root, "1", stateFilename), state); err != nil {
+				t.Fatal(err)
+			}
+			factory, err := New(root)
+			if err != nil {
+				t.Fatal(err)
+			}
+			container, err := factory.Load("1")
+			if err != nil {
+				t.Fatal(err)
+			}
+			stale, err := container.Stale()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if stale != tc.stale {
+				t.Errorf("expected stale to be %v, got %v", tc.stale, stale)
+			}
+			status, err := container.Status()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if status != tc.status {
+				t.Errorf("expected status %s, got %s", tc.status, status)
+			}
+		})
+	}
+}
+
 func marshal(path string, v interface{}) error {
 	f, err := os.Create(path)
 	if err != nil {
@@ -8,7 +8,9 @@ import (
 	"io"
 	"net"
 	"os"
+	"runtime"
 	"strings"
+	"time"
 	"unsafe"
 
 	"github.com/containerd/console"
@@ -19,6 +21,7 @@ import (
 
 	"github.com/opencontainers/runc/libcontainer/capabilities"
 	"github.com/opencontainers/runc/libcontainer/cgroups"
+	"github.com/opencontainers/runc/libcontainer/cgroups/fscommon"
 	"github.com/opencontainers/runc/libcontainer/configs"
 	"github.com/opencontainers/runc/libcontainer/system"
 	"github.com/opencontainers/runc/libcontainer/user"
@@ -62,6 +65,10 @@ type initConfig struct {
 	PassedFilesCount int                   `json:"passed_files_count"`
 	ContainerID      string                `json:"containerid"`
 	Rlimits          []configs.Rlimit      `json:"rlimits"`
+	Scheduler        *configs.Scheduler    `json:"scheduler,omitempty"`
+	IOPriority       *configs.IOPriority   `json:"io_priority,omitempty"`
+	Umask            *uint32               `json:"umask,omitempty"`
+	CPUAffinity      *configs.CPUAffinity  `json:"cpu_affinity,omitempty"`
 	CreateConsole    bool                  `json:"create_console"`
 	ConsoleWidth     uint16                `json:"console_width"`
 	ConsoleHeight    uint16                `json:"console_height"`
@@ -69,24 +76,41 @@ type initConfig struct {
 	RootlessCgroups  bool                  `json:"rootless_cgroups,omitempty"`
 	SpecState        *specs.State          `json:"spec_state,omitempty"`
 	Cgroup2Path      string                `json:"cgroup2_path,omitempty"`
+	SyncVersion      int                   `json:"sync_version,omitempty"`
+	SyncMinVersion   int                   `json:"sync_min_version,omitempty"`
+	SyncFeatures     []syncType            `json:"sync_features,omitempty"`
+}
+
+// syncSupported returns whether the parent can handle the given optional
+// synchronisation message, see syncFeatures.
+func (c *initConfig) syncSupported(t syncType) bool {
+	for _, f := range c.SyncFeatures {
+		if f == t {
+			return true
+		}
+	}
+	return false
 }
 
 type initer interface {
 	Init() error
 }
 
-func newContainerInit(t initType, pipe *os.File, consoleSocket *os.File, fifoFd, logFd int, mountFds []int) (initer, error) {
+func newContainerInit(t initType, pipe *os.File, consoleSocket *os.File, fifoFd, startPipeFd, logFd int, mountFds mountFds) (initer, error) {
 	var config *initConfig
 	if err := json.NewDecoder(pipe).Decode(&config); err != nil {
 		return nil, err
 	}
+	if err := checkSyncVersion(config.SyncVersion, config.SyncMinVersion); err != nil {
+		return nil, err
+	}
 	if err := populateProcessEnvironment(config.Env); err != nil {
 		return nil, err
 	}
 	switch t {
 	case initSetns:
 		// mountFds must be nil in this case. We don't mount while doing runc exec.
-		if mountFds != nil {
+		if mountFds.sourceFds != nil || mountFds.detachedFds != nil {
 			return nil, errors.New("mountFds must be nil; can't mount from exec")
 		}
 
@@ -103,6 +127,7 @@ func newContainerInit(t initType, pipe *os.File, consoleSocket *os.File, fifoFd,
 			parentPid:     unix.Getppid(),
 			config:        config,
 			fifoFd:        fifoFd,
+			startPipeFd:   startPipeFd,
 			logFd:         logFd,
 			mountFds:      mountFds,
 		}, nil
@@ -495,6 +520,119 @@ func setupRlimits(limits []configs.Rlimit, pid int) error {
 	return nil
 }
 
+// setupScheduler sets the scheduling policy and attributes of the process.
+func setupScheduler(s *configs.Scheduler, pid int) error {
+	if s == nil {
+		return nil
+	}
+	attr, err := s.ToSchedAttr()
+	if err != nil {
+		return err
+	}
+	_, _, errno := unix.Syscall(unix.SYS_SCHED_SETATTR, uintptr(pid), uintptr(unsafe.Pointer(attr)), 0)
+	if errno != 0 {
+		return fmt.Errorf("error setting scheduler attributes: %w", os.NewSyscallError("sched_setattr", errno))
+	}
+	return nil
+}
+
+// setupMemoryPolicy sets the memory policy of the calling thread, which
+// is kept across execve(2), and inherited by child processes.
+func setupMemoryPolicy(config *configs.Config) error {
+	if config.MemoryPolicy == nil {
+		return nil
+	}
+	mode, nodes, err := config.MemoryPolicy.ToMempolicy()
+	if err != nil {
+		return err
+	}
+	_, _, errno := unix.Syscall(unix.SYS_SET_MEMPOLICY, uintptr(mode), uintptr(unsafe.Pointer(nodes)), uintptr(len(nodes)*64+1))
+	if errno != 0 {
+		return fmt.Errorf("error setting memory policy: %w", os.NewSyscallError("set_mempolicy", errno))
+	}
+	return nil
+}
+
+// PIDTYPE_* constants from include/linux/pid.h, used as the
+// PR_SCHED_CORE scope.
+const (
+	pidTypePid  = 0
+	pidTypeTgid = 1
+)
+
+// setupCoreSched gives the process pid the core scheduling cookie of the
+// process sharePid or, if sharePid is 0, a new cookie. This is a no-op
+// on machines without SMT, where the kernel returns ENODEV.
+func setupCoreSched(pid, sharePid int) error {
+	if sharePid == 0 {
+		err := unix.Prctl(unix.PR_SCHED_CORE, unix.PR_SCHED_CORE_CREATE, uintptr(pid), pidTypeTgid, 0)
+		if err != nil && !errors.Is(err, unix.ENODEV) {
+			return fmt.Errorf("error creating core scheduling cookie: %w", os.NewSyscallError("prctl", err))
+		}
+		return nil
+	}
+	// The kernel can only copy a cookie from another task to the calling
+	// thread, or from it to another task. Do it on a dedicated thread,
+	// which is never unlocked, so that it is terminated once done rather
+	// than returned to the Go scheduler with the cookie of a container.
+	errCh := make(chan error, 1)
+	go func() {
+		runtime.LockOSThread()
+		err := unix.Prctl(unix.PR_SCHED_CORE, unix.PR_SCHED_CORE_SHARE_FROM, uintptr(sharePid), pidTypePid, 0)
+		if errors.Is(err, unix.ENODEV) {
+			errCh <- nil
+			return
+		}
+		if err != nil {
+			errCh <- fmt.Errorf("error getting core scheduling cookie of pid %d: %w", sharePid, os.NewSyscallError("prctl", err))
+			return
+		}
+		if err := unix.Prctl(unix.PR_SCHED_CORE, unix.PR_SCHED_CORE_SHARE_TO, uintptr(pid), pidTypeTgid, 0); err != nil {
+			errCh <- fmt.Errorf("error setting core scheduling cookie: %w", os.NewSyscallError("prctl", err))
+			return
+		}
+		errCh <- nil
+	}()
+	return <-errCh
+}
+
+// setupUmask sets the umask of the calling process to umask, or to the
+// default of 0022 if it is nil.
+func setupUmask(umask *uint32) {
+	if umask != nil {
+		unix.Umask(int(*umask))
+	} else {
+		unix.Umask(0o022)
+	}
+}
+
+// setupPersonality sets the execution domain of the calling process.
+func setupPersonality(config *configs.Config) error {
+	personality := config.Personality.Domain
+	for _, f := range config.Personality.Flags {
+		personality |= f
+	}
+	return system.SetLinuxPersonality(personality)
+}
+
+const ioprioWhoProcess = 1 // IOPRIO_WHO_PROCESS
+
+// setupIOPriority sets the I/O priority of the process.
+func setupIOPriority(p *configs.IOPriority, pid int) error {
+	if p == nil {
+		return nil
+	}
+	ioprio, err := p.ToIOPrio()
+	if err != nil {
+		return err
+	}
+	_, _, errno := unix.Syscall(unix.SYS_IOPRIO_SET, ioprioWhoProcess, uintptr(pid), uintptr(ioprio))
+	if errno != 0 {
+		return fmt.Errorf("error setting io priority: %w", os.NewSyscallError("ioprio_set", errno))
+	}
+	return nil
+}
+
 const _P_PID = 1
 
 //nolint:structcheck,unused
@@ -522,10 +660,32 @@ func isWaitable(pid int) (bool, error) {
 
 // signalAllProcesses freezes then iterates over all the processes inside the
 // manager's cgroups sending the signal s to them.
-// If s is SIGKILL then it will wait for each process to exit.
+// If s is SIGKILL then it will wait for each process to exit, and for the
+// cgroup to become empty. If available, cgroup.kill is used instead, which
+// kills all the processes atomically.
 // For all other signals it will check if the process is ready to report its
 // exit status and only if it is will a wait be performed.
 func signalAllProcesses(m cgroups.Manager, s os.Signal) error {
+	if s == unix.SIGKILL {
+		// Either cgroup v2 or hybrid.
+		if p := m.Path(""); p != "" {
+			// cgroup.kill is available since kernel 5.14.
+			err := cgroups.WriteFile(p, "cgroup.kill", "1")
+			if err == nil {
+				return waitCgroupEmpty(m)
+			}
+			if !errors.Is(err, os.ErrNotExist) {
+				logrus.Debugf("can't use cgroup.kill, falling back to freeze and kill: %v", err)
+			}
+			// Fall back to freeze and kill.
+		}
+		defer func() {
+			if err := waitCgroupEmpty(m); err != nil {
+				logrus.Warn(err)
+			}
+		}()
+	}
+
 	var procs []*os.Process
 	if err := m.Freeze(configs.Frozen); err != nil {
 		logrus.Warn(err)
@@ -590,3 +750,49 @@ func signalAllProcesses(m cgroups.Manager, s os.Signal) error {
 	}
 	return nil
 }
+
+// waitCgroupEmpty waits for all the processes in the manager's cgroups to
+// exit, with an increasing delay between the checks.
+func waitCgroupEmpty(m cgroups.Manager) error {
+	const retries = 10
+	delay := 10 * time.Millisecond
+	for i := 0; i < retries; i++ {
+		if i != 0 {
+			time.Sleep(delay)
+			delay *= 2
+		}
+		empty, err := cgroupEmpty(m)
+		if err != nil {
+			return err
+		}
+		if empty {
+			return nil
+		}
+	}
+	return errors.New("timed out waiting for the cgroup to become empty")
+}
+
+// cgroupEmpty checks whether there are no processes in the manager's cgroups.
+func cgroupEmpty(m cgroups.Manager) (bool, error) {
+	if cgroups.IsCgroup2UnifiedMode() {
+		// The populated key is also true if there are processes in
+		// the sub-cgroups.
+		populated, err := fscommon.GetValueByKey(m.Path(""), "cgroup.events", "populated")
+		if err != nil {
+			if errors.Is(err, os.ErrNotExist) {
+				return true, nil
+			}
+			return false, err
+		}
+		return populated == 0, nil
+	}
+	pids, err := m.GetAllPids()
+	if err != nil {
+		// The cgroup may be already removed.
+		if errors.Is(err, os.ErrNotExist) {
+			return true, nil
+		}
+		return false, err
+	}
+	return len(pids) == 0, nil
+}
@@ -22,6 +22,7 @@ const (
 	UidmapPathAttr   uint16 = 27288
 	GidmapPathAttr   uint16 = 27289
 	MountSourcesAttr uint16 = 27290
+	TimeOffsetsAttr  uint16 = 27291
 )
 
 type Int32msg struct {
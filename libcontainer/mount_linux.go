@@ -1,9 +1,23 @@
 package libcontainer
 
 import (
+	"fmt"
+	"os"
 	"strconv"
+	"syscall"
+	"unsafe"
 
 	"golang.org/x/sys/unix"
+
+	"github.com/opencontainers/runc/libcontainer/configs"
+)
+
+// These are not (yet) defined in golang.org/x/sys/unix.
+const (
+	openTreeClone       = 0x1    // OPEN_TREE_CLONE
+	moveMountFEmptyPath = 0x4    // MOVE_MOUNT_F_EMPTY_PATH
+	moveMountTSymlinks  = 0x10   // MOVE_MOUNT_T_SYMLINKS
+	atRecursive         = 0x8000 // AT_RECURSIVE
 )
 
 // mountError holds an error from a failed mount or unmount operation.
@@ -81,3 +95,128 @@ func unmount(target string, flags int) error {
 	}
 	return nil
 }
+
+// openTree is a wrapper for open_tree(2).
+func openTree(dirfd int, path string, flags uint) (int, error) {
+	p, err := unix.BytePtrFromString(path)
+	if err != nil {
+		return -1, err
+	}
+	fd, _, errno := unix.Syscall(unix.SYS_OPEN_TREE, uintptr(dirfd), uintptr(unsafe.Pointer(p)), uintptr(flags))
+	if errno != 0 {
+		return -1, &mountError{
+			op:     "open_tree",
+			source: path,
+			flags:  uintptr(flags),
+			err:    errno,
+		}
+	}
+	return int(fd), nil
+}
+
+// moveMount moves the detached mount fd to target, or procfd (if not empty),
+// using move_mount(2).
+func moveMount(fd int, target, procfd string) error {
+	dst := target
+	if procfd != "" {
+		dst = procfd
+	}
+	empty, err := unix.BytePtrFromString("")
+	if err != nil {
+		return err
+	}
+	to, err := unix.BytePtrFromString(dst)
+	if err != nil {
+		return err
+	}
+	toDirfd := unix.AT_FDCWD
+	_, _, errno := unix.Syscall6(unix.SYS_MOVE_MOUNT,
+		uintptr(fd), uintptr(unsafe.Pointer(empty)),
+		uintptr(toDirfd), uintptr(unsafe.Pointer(to)),
+		moveMountFEmptyPath|moveMountTSymlinks, 0)
+	if errno != 0 {
+		return &mountError{
+			op:     "move_mount",
+			source: "fd " + strconv.Itoa(fd),
+			target: target,
+			procfd: procfd,
+			err:    errno,
+		}
+	}
+	return nil
+}
+
+// userNamespaceFile returns a user namespace with the given mappings.
+//
+// The namespace is created by a helper process, which never actually runs:
+// it is traced, so it stops right at execve, and is killed once the
+// namespace is opened.
+func userNamespaceFile(uidMap, gidMap []configs.IDMap) (*os.File, error) {
+	proc, err := os.StartProcess("/proc/self/exe", []string{"runc-idmap"}, &os.ProcAttr{
+		Sys: &syscall.SysProcAttr{
+			Cloneflags:  unix.CLONE_NEWUSER,
+			UidMappings: toSysProcIDMap(uidMap),
+			GidMappings: toSysProcIDMap(gidMap),
+			Ptrace:      true,
+		},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("unable to spawn a process for id mapping: %w", err)
+	}
+	defer func() {
+		_ = proc.Kill()
+		_, _ = proc.Wait()
+	}()
+	return os.Open(fmt.Sprintf("/proc/%d/ns/user", proc.Pid))
+}
+
+func toSysProcIDMap(idMap []configs.IDMap) []syscall.SysProcIDMap {
+	sysMap := make([]syscall.SysProcIDMap, len(idMap))
+	for i, m := range idMap {
+		sysMap[i] = syscall.SysProcIDMap{
+			ContainerID: m.ContainerID,
+			HostID:      m.HostID,
+			Size:        m.Size,
+		}
+	}
+	return sysMap
+}
+
+// detachedMount creates a detached copy of the bind mount m source (which
+// is made id-mapped if m.IsIDMapped), so it can be attached to the container
+// rootfs by moveMount.
+func detachedMount(m *configs.Mount) (*os.File, error) {
+	treeFlags := uint(openTreeClone | unix.O_CLOEXEC)
+	attrFlags := uint(unix.AT_EMPTY_PATH)
+	if m.Flags&unix.MS_REC != 0 {
+		treeFlags |= atRecursive
+		attrFlags |= atRecursive
+	}
+	fd, err := openTree(unix.AT_FDCWD, m.Source, treeFlags)
+	if err != nil {
+		return nil, err
+	}
+	if !m.IsIDMapped() {
+		return os.NewFile(uintptr(fd), "detached:"+m.Source), nil
+	}
+
+	userns, err := userNamespaceFile(m.UIDMappings, m.GIDMappings)
+	if err != nil {
+		_ = unix.Close(fd)
+		return nil, err
+	}
+	defer userns.Close()
+	if err := unix.MountSetattr(fd, "", attrFlags, &unix.MountAttr{
+		Attr_set:  unix.MOUNT_ATTR_IDMAP,
+		Userns_fd: uint64(userns.Fd()),
+	}); err != nil {
+		_ = unix.Close(fd)
+		return nil, &mountError{
+			op:     "mount_setattr",
+			source: m.Source,
+			flags:  uintptr(attrFlags),
+			err:    err,
+		}
+	}
+	return os.NewFile(uintptr(fd), "idmapped:"+m.Source), nil
+}
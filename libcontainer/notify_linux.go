@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"strconv"
 
 	"golang.org/x/sys/unix"
 )
@@ -17,6 +18,26 @@ const (
 	CriticalPressure
 )
 
+var pressureLevelNames = []string{"low", "medium", "critical"}
+
+func (l PressureLevel) String() string {
+	if l > CriticalPressure {
+		return fmt.Sprintf("PressureLevel(%d)", uint(l))
+	}
+	return pressureLevelNames[l]
+}
+
+// ParsePressureLevel parses a memory pressure level name, i.e. one of
+// "low", "medium", or "critical".
+func ParsePressureLevel(name string) (PressureLevel, error) {
+	for i, n := range pressureLevelNames {
+		if n == name {
+			return PressureLevel(i), nil
+		}
+	}
+	return 0, fmt.Errorf("invalid memory pressure level %q", name)
+}
+
 func registerMemoryEvent(cgDir string, evName string, arg string) (<-chan struct{}, error) {
 	evFile, err := os.Open(filepath.Join(cgDir, evName))
 	if err != nil {
@@ -79,6 +100,18 @@ func notifyMemoryPressure(dir string, level PressureLevel) (<-chan struct{}, err
 		return nil, fmt.Errorf("invalid pressure level %d", level)
 	}
 
-	levelStr := []string{"low", "medium", "critical"}[level]
-	return registerMemoryEvent(dir, "memory.pressure_level", levelStr)
+	return registerMemoryEvent(dir, "memory.pressure_level", level.String())
+}
+
+// notifyMemoryThreshold returns a channel signaling every time the memory
+// usage crosses the threshold (in bytes), in either direction.
+func notifyMemoryThreshold(dir string, threshold uint64) (<-chan struct{}, error) {
+	if dir == "" {
+		return nil, errors.New("memory controller missing")
+	}
+	if threshold == 0 {
+		return nil, errors.New("memory threshold must be greater than 0")
+	}
+
+	return registerMemoryEvent(dir, "memory.usage_in_bytes", strconv.FormatUint(threshold, 10))
 }
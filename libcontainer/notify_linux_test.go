@@ -115,3 +115,11 @@ func TestNotifyMemoryPressure(t *testing.T) {
 		testMemoryNotification(t, "memory.pressure_level", f, arg)
 	}
 }
+
+func TestNotifyMemoryThreshold(t *testing.T) {
+	f := func(path string) (<-chan struct{}, error) {
+		return notifyMemoryThreshold(path, 1<<20)
+	}
+
+	testMemoryNotification(t, "memory.usage_in_bytes", f, "1048576")
+}
@@ -0,0 +1,141 @@
+package libcontainer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"golang.org/x/sys/unix"
+)
+
+// PSITrigger describes a pressure stall information (PSI) trigger,
+// as documented in https://www.kernel.org/doc/html/latest/accounting/psi.html.
+// The trigger fires when tasks in the cgroup are stalled on the given
+// resource for at least Threshold within any Window period.
+type PSITrigger struct {
+	// Resource is one of "cpu", "memory", or "io".
+	Resource string
+	// Full selects the "full" stall type (all non-idle tasks stalled
+	// at the same time) rather than "some" (at least one task stalled).
+	Full bool
+	// Threshold is the cumulative stall time within Window that
+	// triggers an event.
+	Threshold time.Duration
+	// Window is the tracking window, from 500ms to 10s.
+	Window time.Duration
+}
+
+// String returns the trigger in the format expected by
+// the <resource>.pressure cgroup files.
+func (t PSITrigger) String() string {
+	kind := "some"
+	if t.Full {
+		kind = "full"
+	}
+	return fmt.Sprintf("%s %d %d", kind, t.Threshold.Microseconds(), t.Window.Microseconds())
+}
+
+func (t PSITrigger) validate() error {
+	switch t.Resource {
+	case "cpu", "memory", "io":
+	default:
+		return fmt.Errorf("invalid PSI resource %q", t.Resource)
+	}
+	if t.Window < 500*time.Millisecond || t.Window > 10*time.Second {
+		return fmt.Errorf("invalid PSI window %s: must be between 500ms and 10s", t.Window)
+	}
+	if t.Threshold <= 0 || t.Threshold > t.Window {
+		return fmt.Errorf("invalid PSI threshold %s: must be positive and not exceed the window", t.Threshold)
+	}
+	return nil
+}
+
+// registerPSITrigger writes the trigger to the corresponding pressure
+// file and returns a channel which is sent to every time the trigger
+// fires. Events are dropped while the previous one has not been received
+// yet. The channel is closed once the cgroup is removed, or ctx is done.
+func registerPSITrigger(ctx context.Context, cgDir string, t PSITrigger) (<-chan struct{}, error) {
+	if err := t.validate(); err != nil {
+		return nil, err
+	}
+	// The trigger is bound to the open file description,
+	// so the file must be kept open as long as we poll it.
+	f, err := os.OpenFile(filepath.Join(cgDir, t.Resource+".pressure"), os.O_RDWR|unix.O_NONBLOCK, 0)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := f.WriteString(t.String()); err != nil {
+		f.Close()
+		return nil, fmt.Errorf("unable to register PSI trigger %q: %w", t, err)
+	}
+	rc, err := f.SyscallConn()
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	cfd, release, err := cancelFd(ctx)
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	ch := make(chan struct{}, 1)
+	go func() {
+		defer func() {
+			release()
+			f.Close()
+			close(ch)
+		}()
+		for {
+			var (
+				revents, crevents int16
+				perr              error
+			)
+			err := rc.Control(func(fd uintptr) {
+				fds := []unix.PollFd{
+					{Fd: int32(fd), Events: unix.POLLPRI},
+					{Fd: int32(cfd), Events: unix.POLLIN},
+				}
+				_, perr = unix.Poll(fds, -1)
+				revents, crevents = fds[0].Revents, fds[1].Revents
+			})
+			if err == nil {
+				err = perr
+			}
+			if errors.Is(err, unix.EINTR) {
+				continue
+			}
+			if err != nil {
+				logrus.Warnf("unable to poll PSI trigger %q: %v", t, err)
+				return
+			}
+			if crevents != 0 {
+				return
+			}
+			// POLLERR is returned once the cgroup is removed.
+			// Anything but POLLPRI means it's not a PSI trigger.
+			if revents&unix.POLLERR != 0 || revents&unix.POLLPRI == 0 {
+				return
+			}
+			select {
+			case ch <- struct{}{}:
+			default:
+			}
+		}
+	}()
+	return ch, nil
+}
+
+// notifyPressure returns a channel on which you can expect an event every
+// time the given PSI trigger fires, until ctx is done. Requires cgroup v2
+// and kernel 5.2+.
+func notifyPressure(ctx context.Context, dir string, t PSITrigger) (<-chan struct{}, error) {
+	if dir == "" {
+		return nil, errors.New("cgroup path missing")
+	}
+
+	return registerPSITrigger(ctx, dir, t)
+}
@@ -0,0 +1,105 @@
+package libcontainer
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"golang.org/x/sys/unix"
+)
+
+func TestPSITriggerString(t *testing.T) {
+	for _, tc := range []struct {
+		trigger  PSITrigger
+		expected string
+	}{
+		{
+			trigger:  PSITrigger{Resource: "memory", Threshold: 150 * time.Millisecond, Window: time.Second},
+			expected: "some 150000 1000000",
+		},
+		{
+			trigger:  PSITrigger{Resource: "io", Full: true, Threshold: 50 * time.Millisecond, Window: 2 * time.Second},
+			expected: "full 50000 2000000",
+		},
+	} {
+		if got := tc.trigger.String(); got != tc.expected {
+			t.Errorf("expected %q, got %q", tc.expected, got)
+		}
+	}
+}
+
+func TestPSITriggerValidate(t *testing.T) {
+	for _, tc := range []struct {
+		trigger PSITrigger
+		isErr   bool
+	}{
+		{trigger: PSITrigger{Resource: "cpu", Threshold: 100 * time.Millisecond, Window: time.Second}},
+		{trigger: PSITrigger{Resource: "net", Threshold: 100 * time.Millisecond, Window: time.Second}, isErr: true},
+		{trigger: PSITrigger{Resource: "cpu", Threshold: 100 * time.Millisecond, Window: 100 * time.Millisecond}, isErr: true},
+		{trigger: PSITrigger{Resource: "cpu", Threshold: 2 * time.Second, Window: time.Second}, isErr: true},
+		{trigger: PSITrigger{Resource: "cpu", Window: time.Second}, isErr: true},
+	} {
+		err := tc.trigger.validate()
+		if tc.isErr && err == nil {
+			t.Errorf("trigger %+v: expected error, got nil", tc.trigger)
+		}
+		if !tc.isErr && err != nil {
+			t.Errorf("trigger %+v: expected no error, got %v", tc.trigger, err)
+		}
+	}
+}
+
+func TestNotifyPressureWritesTrigger(t *testing.T) {
+	cgDir := t.TempDir()
+	pressureFile := filepath.Join(cgDir, "memory.pressure")
+	if err := os.WriteFile(pressureFile, nil, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	trigger := PSITrigger{Resource: "memory", Threshold: 150 * time.Millisecond, Window: time.Second}
+	ctx, cancel := context.WithCancel(context.Background())
+	ch, err := notifyPressure(ctx, cgDir, trigger)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(pressureFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != trigger.String() {
+		t.Fatalf("expected %q to be written, got %q", trigger.String(), data)
+	}
+	cancel()
+	<-ch
+}
+
+func TestNotifyPressureCancel(t *testing.T) {
+	cgDir := t.TempDir()
+	// Unlike a regular file, a fifo is never ready for POLLPRI, so the
+	// notifier waits until ctx is done.
+	if err := unix.Mkfifo(filepath.Join(cgDir, "cpu.pressure"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	trigger := PSITrigger{Resource: "cpu", Threshold: 150 * time.Millisecond, Window: time.Second}
+	ctx, cancel := context.WithCancel(context.Background())
+	ch, err := notifyPressure(ctx, cgDir, trigger)
+	if err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case _, ok := <-ch:
+		t.Fatalf("unexpected receive from the channel (ok: %v)", ok)
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	cancel()
+	select {
+	case _, ok := <-ch:
+		if ok {
+			t.Fatal("unexpected event after cancel")
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("channel not closed after cancel")
+	}
+}
@@ -27,6 +27,12 @@ func registerMemoryEventV2(cgDir, evName, cgEvName string) (<-chan struct{}, err
 		unix.Close(fd)
 		return nil, fmt.Errorf("unable to add inotify watch: %w", err)
 	}
+	// Only report new OOM kills, not the ones which happened before.
+	oomKills, err := fscommon.GetValueByKey(cgDir, evName, "oom_kill")
+	if err != nil {
+		unix.Close(fd)
+		return nil, err
+	}
 	ch := make(chan struct{})
 	go func() {
 		var (
@@ -57,8 +63,15 @@ func registerMemoryEventV2(cgDir, evName, cgEvName string) (<-chan struct{}, err
 				}
 				switch int(rawEvent.Wd) {
 				case evFd:
+					// The file is also modified on other memory events,
+					// such as hitting memory.high or memory.max.
 					oom, err := fscommon.GetValueByKey(cgDir, evName, "oom_kill")
-					if err != nil || oom > 0 {
+					if err != nil {
+						// The cgroup has been removed.
+						return
+					}
+					if oom > oomKills {
+						oomKills = oom
 						ch <- struct{}{}
 					}
 				case cgFd:
@@ -74,7 +87,8 @@ func registerMemoryEventV2(cgDir, evName, cgEvName string) (<-chan struct{}, err
 }
 
 // notifyOnOOMV2 returns channel on which you can expect event about OOM,
-// if process died without OOM this channel will be closed.
+// if process died without OOM this channel will be closed. An event is sent
+// every time the oom_kill counter in memory.events increases.
 func notifyOnOOMV2(path string) (<-chan struct{}, error) {
 	return registerMemoryEventV2(path, "memory.events", "cgroup.events")
 }
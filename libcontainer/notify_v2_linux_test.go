@@ -0,0 +1,57 @@
+package libcontainer
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/opencontainers/runc/libcontainer/cgroups"
+)
+
+func TestNotifyOnOOMV2(t *testing.T) {
+	cgroups.TestMode = true
+	defer func() { cgroups.TestMode = false }()
+
+	dir := t.TempDir()
+	write := func(file, data string) {
+		t.Helper()
+		if err := os.WriteFile(filepath.Join(dir, file), []byte(data), 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// An OOM kill which happened before the registration.
+	write("memory.events", "low 0\nhigh 0\nmax 1\noom 1\noom_kill 1\n")
+	write("cgroup.events", "populated 1\nfrozen 0\n")
+
+	ch, err := notifyOnOOMV2(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Other memory events must not be reported.
+	write("memory.events", "low 0\nhigh 5\nmax 2\noom 1\noom_kill 1\n")
+	select {
+	case <-ch:
+		t.Fatal("unexpected notification without an OOM kill")
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	write("memory.events", "low 0\nhigh 5\nmax 3\noom 2\noom_kill 2\n")
+	select {
+	case <-ch:
+	case <-time.After(time.Second):
+		t.Fatal("no notification on channel after 1s")
+	}
+
+	// The channel is closed once the cgroup is empty.
+	write("cgroup.events", "populated 0\nfrozen 0\n")
+	select {
+	case _, ok := <-ch:
+		if ok {
+			t.Fatal("expected no notification to be triggered")
+		}
+	case <-time.After(time.Second):
+		t.Fatal("channel not closed after 1s")
+	}
+}
@@ -20,6 +20,7 @@
 #include <sys/ioctl.h>
 #include <sys/prctl.h>
 #include <sys/socket.h>
+#include <sys/syscall.h>
 #include <sys/types.h>
 #include <sys/wait.h>
 
@@ -67,6 +68,29 @@ struct clone_t {
 	int jmpval;
 };
 
+/*
+ * The clone3(2) arguments, from include/uapi/linux/sched.h (which is not
+ * available, or is too old, on a lot of systems).
+ */
+struct clone_args_t {
+	uint64_t flags __attribute__((aligned(8)));
+	uint64_t pidfd __attribute__((aligned(8)));
+	uint64_t child_tid __attribute__((aligned(8)));
+	uint64_t parent_tid __attribute__((aligned(8)));
+	uint64_t exit_signal __attribute__((aligned(8)));
+	uint64_t stack __attribute__((aligned(8)));
+	uint64_t stack_size __attribute__((aligned(8)));
+	uint64_t tls __attribute__((aligned(8)));
+	uint64_t set_tid __attribute__((aligned(8)));
+	uint64_t set_tid_size __attribute__((aligned(8)));
+	uint64_t cgroup __attribute__((aligned(8)));
+};
+
+#ifndef __NR_clone3
+/* Make the syscall fail with ENOSYS, so the clone(2) fallback is used. */
+#	define __NR_clone3 -1
+#endif
+
 struct nlconfig_t {
 	char *data;
 
@@ -94,6 +118,10 @@ struct nlconfig_t {
 	/* Mount sources opened outside the container userns. */
 	char *mountsources;
 	size_t mountsources_len;
+
+	/* Time namespace clock offsets. */
+	char *timensoffset;
+	size_t timensoffset_len;
 };
 
 /*
@@ -127,6 +155,7 @@ static int loglevel = DEBUG;
 #define UIDMAPPATH_ATTR		27288
 #define GIDMAPPATH_ATTR		27289
 #define MOUNT_SOURCES_ATTR	27290
+#define TIMENSOFFSET_ATTR	27291
 
 /*
  * Use the raw syscall for versions of glibc which don't include a function for
@@ -376,6 +405,16 @@ static void update_oom_score_adj(char *data, size_t len)
 		bail("failed to update /proc/self/oom_score_adj");
 }
 
+static void update_timens_offsets(char *data, size_t len)
+{
+	if (data == NULL || len == 0)
+		return;
+
+	write_log(DEBUG, "update /proc/self/timens_offsets to '%s'", data);
+	if (write_file(data, len, "/proc/self/timens_offsets") < 0)
+		bail("failed to update /proc/self/timens_offsets");
+}
+
 /* A dummy function that just jumps to the given jumpval. */
 static int child_func(void *arg) __attribute__((noinline));
 static int child_func(void *arg)
@@ -384,6 +423,11 @@ static int child_func(void *arg)
 	longjmp(*ca->env, ca->jmpval);
 }
 
+static int sys_clone3(struct clone_args_t *args)
+{
+	return syscall(__NR_clone3, args, sizeof(*args));
+}
+
 static int clone_parent(jmp_buf *env, int jmpval) __attribute__((noinline));
 static int clone_parent(jmp_buf *env, int jmpval)
 {
@@ -391,6 +435,35 @@ static int clone_parent(jmp_buf *env, int jmpval)
 		.env = env,
 		.jmpval = jmpval,
 	};
+	/*
+	 * With CLONE_PARENT, the exit signal is the one of the parent (the
+	 * SIGCHLD given to clone(2) below is ignored), and clone3(2) requires
+	 * it to be unset.
+	 */
+	struct clone_args_t args = {
+		.flags = CLONE_PARENT,
+	};
+	int pid;
+
+	/*
+	 * Prefer clone3(2): without a new stack it behaves like fork(2), so the
+	 * child can jump to its stage directly (clone_t and its stack are only
+	 * needed by clone(2)). Fall back to clone(2) if the kernel is older than 5.3,
+	 * or if clone3(2) is blocked by a seccomp profile (which usually makes
+	 * it fail with ENOSYS, but sometimes with EPERM).
+	 *
+	 * No other clone3(2) features are used. CLONE_NEWTIME has to be
+	 * unshared in stage-1, as the clock offsets can only be set before any
+	 * process is in the time namespace. The cgroup is joined by the parent
+	 * before nsexec runs (using CLONE_INTO_CGROUP if possible, see
+	 * cgroupfd_go120_linux.go), so all the stages are already in it. And
+	 * set_tid is only useful for restoring a container, which CRIU does.
+	 */
+	pid = sys_clone3(&args);
+	if (pid == 0)
+		longjmp(*env, jmpval);
+	if (pid > 0 || (errno != ENOSYS && errno != EPERM))
+		return pid;
 
 	return clone(child_func, ca.stack_ptr, CLONE_PARENT | SIGCHLD, &ca);
 }
@@ -464,6 +537,8 @@ static int nsflag(char *name)
 		return CLONE_NEWUSER;
 	else if (!strcmp(name, "uts"))
 		return CLONE_NEWUTS;
+	else if (!strcmp(name, "time"))
+		return CLONE_NEWTIME;
 
 	/* If we don't recognise a name, fallback to 0. */
 	return 0;
@@ -554,6 +629,10 @@ static void nl_parse(int fd, struct nlconfig_t *config)
 			config->mountsources = current;
 			config->mountsources_len = payload_len;
 			break;
+		case TIMENSOFFSET_ATTR:
+			config->timensoffset = current;
+			config->timensoffset_len = payload_len;
+			break;
 		default:
 			bail("unknown netlink message type %d", nlattr->nla_type);
 		}
@@ -1228,6 +1307,14 @@ void nsexec(void)
 			if (unshare(config.cloneflags & ~CLONE_NEWCGROUP) < 0)
 				bail("failed to unshare remaining namespaces (except cgroupns)");
 
+			/*
+			 * The clock offsets of a new time namespace can only be set
+			 * before any process is created in it, so this has to be done
+			 * before stage-2 is cloned.
+			 */
+			if (config.cloneflags & CLONE_NEWTIME)
+				update_timens_offsets(config.timensoffset, config.timensoffset_len);
+
 			/* Ask our parent to send the mount sources fds. */
 			if (config.mountsources) {
 				s = SYNC_MOUNTSOURCES_PLS;
@@ -0,0 +1,65 @@
+package libcontainer
+
+import (
+	"errors"
+	"fmt"
+	"os"
+
+	"golang.org/x/sys/unix"
+
+	"github.com/opencontainers/runc/libcontainer/system"
+)
+
+// Pidfd returns a pidfd (see pidfd_open(2)) referring to the process.
+//
+// For a process started by the caller, and not yet waited for, the PID can
+// not be reused, so the pidfd is guaranteed to refer to the right process.
+func (p Process) Pidfd() (*os.File, error) {
+	pid, err := p.Pid()
+	if err != nil {
+		return nil, err
+	}
+	fd, err := unix.PidfdOpen(pid, 0)
+	if err != nil {
+		return nil, os.NewSyscallError("pidfd_open", err)
+	}
+	return os.NewFile(uintptr(fd), fmt.Sprintf("pidfd:%d", pid)), nil
+}
+
+// signalProcess sends a signal to the process with the given pid, unless
+// the PID is now used by another process, i.e. the process start time is
+// not startTime. If pidfds are supported, this is done without races: once
+// the pidfd is open, it refers to the same process no matter what, so the
+// signal can't be delivered to a process which reused the PID after the
+// start time check. Otherwise, this falls back to kill(2).
+func signalProcess(pid int, startTime uint64, sig unix.Signal) error {
+	fd, err := unix.PidfdOpen(pid, 0)
+	if err != nil {
+		if errors.Is(err, unix.ENOSYS) {
+			return unix.Kill(pid, sig)
+		}
+		return err
+	}
+	defer unix.Close(fd)
+
+	stat, err := system.Stat(pid)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return unix.ESRCH
+		}
+		return err
+	}
+	if stat.StartTime != startTime {
+		return unix.ESRCH
+	}
+	return pidfdSendSignal(fd, sig)
+}
+
+// pidfdSendSignal is a wrapper for pidfd_send_signal(2).
+func pidfdSendSignal(pidfd int, sig unix.Signal) error {
+	_, _, errno := unix.Syscall6(unix.SYS_PIDFD_SEND_SIGNAL, uintptr(pidfd), uintptr(sig), 0, 0, 0, 0)
+	if errno != 0 {
+		return errno
+	}
+	return nil
+}
@@ -0,0 +1,42 @@
+package libcontainer
+
+import (
+	"errors"
+	"os/exec"
+	"testing"
+
+	"golang.org/x/sys/unix"
+
+	"github.com/opencontainers/runc/libcontainer/system"
+)
+
+func TestSignalProcessStartTime(t *testing.T) {
+	cmd := exec.Command("sleep", "100")
+	if err := cmd.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		_ = cmd.Process.Kill()
+		_ = cmd.Wait()
+	}()
+	stat, err := system.Stat(cmd.Process.Pid)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// A process with a different start time is not signalled.
+	err = signalProcess(cmd.Process.Pid, stat.StartTime+1, unix.SIGKILL)
+	if !errors.Is(err, unix.ESRCH) {
+		t.Fatalf("expected ESRCH, got %v", err)
+	}
+	if err := unix.Kill(cmd.Process.Pid, 0); err != nil {
+		t.Fatalf("process was signalled: %v", err)
+	}
+
+	if err := signalProcess(cmd.Process.Pid, stat.StartTime, unix.SIGKILL); err != nil {
+		t.Fatal(err)
+	}
+	if err := cmd.Wait(); err == nil {
+		t.Fatal("expected the process to be killed")
+	}
+}
@@ -54,7 +54,8 @@ type Process struct {
 	ConsoleHeight uint16
 
 	// Capabilities specify the capabilities to keep when executing the process inside the container
-	// All capabilities not specified will be dropped from the processes capability mask
+	// All capabilities not specified will be dropped from the processes capability mask.
+	// These are independent of the container config capabilities.
 	Capabilities *configs.Capabilities
 
 	// AppArmorProfile specifies the profile to apply to the process and is
@@ -65,18 +66,42 @@ type Process struct {
 	Label string
 
 	// NoNewPrivileges controls whether processes can gain additional privileges.
+	// If it is nil, the one from the container config is used.
 	NoNewPrivileges *bool
 
 	// Rlimits specifies the resource limits, such as max open files, to set in the container
 	// If Rlimits are not set, the container will inherit rlimits from the parent process
 	Rlimits []configs.Rlimit
 
+	// Scheduler specifies the scheduling attributes of the process.
+	// If it is nil, the ones from the container config are used.
+	Scheduler *configs.Scheduler
+
+	// IOPriority specifies the I/O priority of the process.
+	// If it is nil, the one from the container config is used.
+	IOPriority *configs.IOPriority
+
+	// CPUAffinity specifies the CPU affinity of a non-init process.
+	// If it is nil, the container config ExecCPUAffinity is used.
+	CPUAffinity *configs.CPUAffinity
+
+	// Umask specifies the umask of the process.
+	// If it is nil, the one from the container config is used.
+	Umask *uint32
+
 	// ConsoleSocket provides the masterfd console.
 	ConsoleSocket *os.File
 
 	// Init specifies whether the process is the first process in the container.
 	Init bool
 
+	// StartPipe, if set, is the read end of a pipe which is used to start
+	// the container instead of the exec fifo in the container state
+	// directory: the user process is started once a byte is written to the
+	// other end of the pipe (or once Exec is called). It can be closed by
+	// the caller once Start returns. Only used for the init process.
+	StartPipe *os.File
+
 	ops processOperations
 
 	LogLevel string
@@ -9,6 +9,7 @@ import (
 	"os"
 	"os/exec"
 	"path/filepath"
+	"runtime"
 	"strconv"
 	"time"
 
@@ -78,11 +79,44 @@ func (p *setnsProcess) signal(sig os.Signal) error {
 	return unix.Kill(p.pid(), s)
 }
 
+// startWithCPUAffinity starts the process with the initial CPU affinity,
+// if any. Since the affinity is inherited from the thread calling fork,
+// it is temporarily set for the current (locked) thread.
+func (p *setnsProcess) startWithCPUAffinity() error {
+	var initial *unix.CPUSet
+	if p.config.CPUAffinity != nil {
+		var err error
+		if initial, err = configs.ToCPUSet(p.config.CPUAffinity.Initial); err != nil {
+			return err
+		}
+	}
+	if initial == nil {
+		return p.cmd.Start()
+	}
+
+	// Note the thread can't just be terminated afterwards, as the parent
+	// death signal of the child is bound to it.
+	runtime.LockOSThread()
+	defer runtime.UnlockOSThread()
+	var orig unix.CPUSet
+	if err := unix.SchedGetaffinity(0, &orig); err != nil {
+		return fmt.Errorf("error getting cpu affinity: %w", err)
+	}
+	if err := unix.SchedSetaffinity(0, initial); err != nil {
+		return fmt.Errorf("error setting initial cpu affinity: %w", err)
+	}
+	err := p.cmd.Start()
+	if err := unix.SchedSetaffinity(0, &orig); err != nil {
+		logrus.WithError(err).Warn("unable to restore cpu affinity")
+	}
+	return err
+}
+
 func (p *setnsProcess) start() (retErr error) {
 	defer p.messageSockPair.parent.Close()
 	// get the "before" value of oom kill count
 	oom, _ := p.manager.OOMKillCount()
-	err := p.cmd.Start()
+	err := p.startWithCPUAffinity()
 	// close the write-side of the pipes (controlled by child)
 	p.messageSockPair.child.Close()
 	p.logFilePair.child.Close()
@@ -157,6 +191,18 @@ func (p *setnsProcess) start() (retErr error) {
 	if err := setupRlimits(p.config.Rlimits, p.pid()); err != nil {
 		return fmt.Errorf("error setting rlimits for process: %w", err)
 	}
+	if err := setupScheduler(p.config.Scheduler, p.pid()); err != nil {
+		return err
+	}
+	if err := setupIOPriority(p.config.IOPriority, p.pid()); err != nil {
+		return err
+	}
+	if p.config.Config.CoreSched != nil && p.initProcessPid != 0 {
+		// Join the core scheduling group of the container.
+		if err := setupCoreSched(p.pid(), p.initProcessPid); err != nil {
+			return err
+		}
+	}
 	if err := utils.WriteJSON(p.messageSockPair.parent, p.config); err != nil {
 		return fmt.Errorf("error writing config to pipe: %w", err)
 	}
@@ -206,7 +252,7 @@ func (p *setnsProcess) start() (retErr error) {
 			}
 			return nil
 		default:
-			return errors.New("invalid JSON payload from child")
+			return fmt.Errorf("unexpected synchronisation message %q from child", string(sync.Type))
 		}
 	})
 
@@ -305,6 +351,8 @@ type initProcess struct {
 	process         *Process
 	bootstrapData   io.Reader
 	sharePidns      bool
+	// detachedFiles are the detached mounts passed to the child.
+	detachedFiles []*os.File
 }
 
 func (p *initProcess) pid() int {
@@ -355,13 +403,37 @@ func (p *initProcess) waitForChildExit(childPid int) error {
 
 func (p *initProcess) start() (retErr error) {
 	defer p.messageSockPair.parent.Close() //nolint: errcheck
-	err := p.cmd.Start()
+	// If possible, start init right in the container cgroup, so it never
+	// runs in the wrong one. Apply below is still needed in any case.
+	cgroupExisted := p.manager.Exists()
+	cgroupFD, err := p.manager.CgroupFD()
+	if err == nil {
+		defer cgroupFD.Close()
+		useCgroupFD(p.cmd, cgroupFD)
+	} else if !errors.Is(err, cgroups.ErrNoCgroupFD) {
+		logrus.Debugf("unable to open cgroup fd, not using CLONE_INTO_CGROUP: %v", err)
+	}
+	err = p.cmd.Start()
+	if err != nil {
+		if cmd := retryWithoutCgroupFD(p.cmd, err); cmd != nil {
+			logrus.Debugf("unable to use CLONE_INTO_CGROUP, retrying without it: %v", err)
+			p.cmd = cmd
+			err = p.cmd.Start()
+		}
+	}
 	p.process.ops = p
 	// close the write-side of the pipes (controlled by child)
 	_ = p.messageSockPair.child.Close()
 	_ = p.logFilePair.child.Close()
+	for _, f := range p.detachedFiles {
+		_ = f.Close()
+	}
 	if err != nil {
 		p.process.ops = nil
+		if cgroupFD != nil && !cgroupExisted {
+			// Remove the cgroup created by CgroupFD.
+			_ = p.manager.Destroy()
+		}
 		return fmt.Errorf("unable to start init: %w", err)
 	}
 
@@ -500,6 +572,17 @@ func (p *initProcess) start() (retErr error) {
 			if err := setupRlimits(p.config.Rlimits, p.pid()); err != nil {
 				return fmt.Errorf("error setting rlimits for ready process: %w", err)
 			}
+			if err := setupScheduler(p.config.Scheduler, p.pid()); err != nil {
+				return err
+			}
+			if err := setupIOPriority(p.config.IOPriority, p.pid()); err != nil {
+				return err
+			}
+			if cs := p.config.Config.CoreSched; cs != nil {
+				if err := setupCoreSched(p.pid(), cs.SharePid); err != nil {
+					return err
+				}
+			}
 			// call prestart and CreateRuntime hooks
 			if !p.config.Config.Namespaces.Contains(configs.NEWNS) {
 				// Setup cgroup before the hook, so that the prestart and CreateRuntime hook could apply cgroup permissions.
@@ -522,10 +605,10 @@ func (p *initProcess) start() (retErr error) {
 					s.Status = specs.StateCreating
 					hooks := p.config.Config.Hooks
 
-					if err := hooks[configs.Prestart].RunHooks(s); err != nil {
+					if err := hooks.Run(configs.Prestart, s); err != nil {
 						return err
 					}
-					if err := hooks[configs.CreateRuntime].RunHooks(s); err != nil {
+					if err := hooks.Run(configs.CreateRuntime, s); err != nil {
 						return err
 					}
 				}
@@ -577,10 +660,10 @@ func (p *initProcess) start() (retErr error) {
 				s.Status = specs.StateCreating
 				hooks := p.config.Config.Hooks
 
-				if err := hooks[configs.Prestart].RunHooks(s); err != nil {
+				if err := hooks.Run(configs.Prestart, s); err != nil {
 					return err
 				}
-				if err := hooks[configs.CreateRuntime].RunHooks(s); err != nil {
+				if err := hooks.Run(configs.CreateRuntime, s); err != nil {
 					return err
 				}
 			}
@@ -590,7 +673,7 @@ func (p *initProcess) start() (retErr error) {
 			}
 			sentResume = true
 		default:
-			return errors.New("invalid JSON payload from child")
+			return fmt.Errorf("unexpected synchronisation message %q from child", string(sync.Type))
 		}
 
 		return nil
@@ -35,6 +35,18 @@ type mountConfig struct {
 	rootlessCgroups bool
 	cgroupns        bool
 	fd              *int
+	detachedFd      *int
+}
+
+// mountFds are the fds used to set up the mounts, as passed by the parent.
+// Each slice (if not nil) is paired with config.Mounts, with -1 meaning
+// there is no fd for the mount.
+type mountFds struct {
+	// sourceFds are the fds of bind mount sources (O_PATH).
+	sourceFds []int
+	// detachedFds are the fds of detached bind mounts (see open_tree(2)),
+	// which are used instead of sourceFds if available.
+	detachedFds []int
 }
 
 // needsSetupDev returns true if /dev needs to be set up.
@@ -50,14 +62,17 @@ func needsSetupDev(config *configs.Config) bool {
 // prepareRootfs sets up the devices, mount points, and filesystems for use
 // inside a new mount namespace. It doesn't set anything as ro. You must call
 // finalizeRootfs after this function to finish setting up the rootfs.
-func prepareRootfs(pipe io.ReadWriter, iConfig *initConfig, mountFds []int) (err error) {
+func prepareRootfs(pipe io.ReadWriter, iConfig *initConfig, mountFds mountFds) (err error) {
 	config := iConfig.Config
 	if err := prepareRoot(config); err != nil {
 		return fmt.Errorf("error preparing rootfs: %w", err)
 	}
 
-	if mountFds != nil && len(mountFds) != len(config.Mounts) {
-		return fmt.Errorf("malformed mountFds slice. Expected size: %v, got: %v. Slice: %v", len(config.Mounts), len(mountFds), mountFds)
+	if mountFds.sourceFds != nil && len(mountFds.sourceFds) != len(config.Mounts) {
+		return fmt.Errorf("malformed mountFds slice. Expected size: %v, got: %v. Slice: %v", len(config.Mounts), len(mountFds.sourceFds), mountFds.sourceFds)
+	}
+	if mountFds.detachedFds != nil && len(mountFds.detachedFds) != len(config.Mounts) {
+		return fmt.Errorf("malformed detachedFds slice. Expected size: %v, got: %v. Slice: %v", len(config.Mounts), len(mountFds.detachedFds), mountFds.detachedFds)
 	}
 
 	mountConfig := &mountConfig{
@@ -71,8 +86,13 @@ func prepareRootfs(pipe io.ReadWriter, iConfig *initConfig, mountFds []int) (err
 	for i, m := range config.Mounts {
 		// Just before the loop we checked that if not empty, len(mountFds) == len(config.Mounts).
 		// Therefore, we can access mountFds[i] without any concerns.
-		if mountFds != nil && mountFds[i] != -1 {
-			mountConfig.fd = &mountFds[i]
+		mountConfig.fd = nil
+		if mountFds.sourceFds != nil && mountFds.sourceFds[i] != -1 {
+			mountConfig.fd = &mountFds.sourceFds[i]
+		}
+		mountConfig.detachedFd = nil
+		if mountFds.detachedFds != nil && mountFds.detachedFds[i] != -1 {
+			mountConfig.detachedFd = &mountFds.detachedFds[i]
 		}
 
 		if err := mountToRootfs(m, mountConfig); err != nil {
@@ -116,7 +136,7 @@ func prepareRootfs(pipe io.ReadWriter, iConfig *initConfig, mountFds []int) (err
 	s := iConfig.SpecState
 	s.Pid = unix.Getpid()
 	s.Status = specs.StateCreating
-	if err := iConfig.Config.Hooks[configs.CreateContainer].RunHooks(s); err != nil {
+	if err := iConfig.Config.Hooks.Run(configs.CreateContainer, s); err != nil {
 		return err
 	}
 
@@ -171,11 +191,6 @@ func finalizeRootfs(config *configs.Config) (err error) {
 		}
 	}
 
-	if config.Umask != nil {
-		unix.Umask(int(*config.Umask))
-	} else {
-		unix.Umask(0o022)
-	}
 	return nil
 }
 
@@ -432,10 +447,19 @@ func mountToRootfs(m *configs.Mount, c *mountConfig) error {
 		}
 		return nil
 	case "bind":
+		if c.detachedFd != nil {
+			mountFd = c.detachedFd
+		}
 		if err := prepareBindMount(m, rootfs, mountFd); err != nil {
 			return err
 		}
-		if err := mountPropagate(m, rootfs, mountLabel, mountFd); err != nil {
+		if c.detachedFd != nil {
+			if err := mountDetached(m, rootfs, *c.detachedFd); err != nil {
+				return err
+			}
+		} else if m.IsIDMapped() {
+			return errors.New("no detached mount for id-mapped mount")
+		} else if err := mountPropagate(m, rootfs, mountLabel, mountFd); err != nil {
 			return err
 		}
 		// bind mount won't change mount options, we need remount to make mount options effective.
@@ -972,6 +996,16 @@ func readonlyPath(path string) error {
 		return err
 	}
 
+	// Make the submounts read-only too, if the kernel supports it (5.12+),
+	// and if it is allowed to (it may be blocked by seccomp or a LSM).
+	err := unix.MountSetattr(unix.AT_FDCWD, path, unix.AT_RECURSIVE, &unix.MountAttr{Attr_set: unix.MOUNT_ATTR_RDONLY})
+	if err == nil {
+		return nil
+	}
+	if !errors.Is(err, unix.ENOSYS) && !errors.Is(err, unix.EPERM) {
+		return &os.PathError{Op: "mount_setattr", Path: path, Err: err}
+	}
+
 	var s unix.Statfs_t
 	if err := unix.Statfs(path, &s); err != nil {
 		return &os.PathError{Op: "statfs", Path: path, Err: err}
@@ -1016,7 +1050,17 @@ func remountReadonly(m *configs.Mount) error {
 // mounts ( proc/kcore ).
 // For files, maskPath bind mounts /dev/null over the top of the specified path.
 // For directories, maskPath mounts read-only tmpfs over the top of the specified path.
-func maskPath(path string, mountLabel string) error {
+func maskPath(path string, mountLabel string, mode configs.MaskMode) error {
+	switch mode {
+	case configs.MaskReadonly:
+		return readonlyPath(path)
+	case configs.MaskTmpfs:
+		err := mount("tmpfs", path, "", "tmpfs", unix.MS_RDONLY, label.FormatMountLabel("", mountLabel))
+		if err != nil && !errors.Is(err, os.ErrNotExist) {
+			return err
+		}
+		return nil
+	}
 	if err := mount("/dev/null", path, "", "", unix.MS_BIND, ""); err != nil && !errors.Is(err, os.ErrNotExist) {
 		if errors.Is(err, unix.ENOTDIR) {
 			return mount("tmpfs", path, "", "tmpfs", unix.MS_RDONLY, label.FormatMountLabel("", mountLabel))
@@ -1059,6 +1103,17 @@ func remount(m *configs.Mount, rootfs string, mountFd *int) error {
 	})
 }
 
+// mountDetached attaches the detached mount fd (cloned by the parent from
+// the mount source) to the mount destination.
+func mountDetached(m *configs.Mount, rootfs string, fd int) error {
+	if err := utils.WithProcfd(rootfs, m.Destination, func(procfd string) error {
+		return moveMount(fd, m.Destination, procfd)
+	}); err != nil {
+		return err
+	}
+	return applyPropagation(m, rootfs)
+}
+
 // Do the mount operation followed by additional mounts required to take care
 // of propagation flags. This will always be scoped inside the container rootfs.
 func mountPropagate(m *configs.Mount, rootfs string, mountLabel string, mountFd *int) error {
@@ -1088,6 +1143,10 @@ func mountPropagate(m *configs.Mount, rootfs string, mountLabel string, mountFd
 	}); err != nil {
 		return err
 	}
+	return applyPropagation(m, rootfs)
+}
+
+func applyPropagation(m *configs.Mount, rootfs string) error {
 	// We have to apply mount propagation flags in a separate WithProcfd() call
 	// because the previous call invalidates the passed procfd -- the mount
 	// target needs to be re-opened.
@@ -11,6 +11,7 @@ import (
 	"golang.org/x/sys/unix"
 
 	"github.com/opencontainers/runc/libcontainer/apparmor"
+	"github.com/opencontainers/runc/libcontainer/configs"
 	"github.com/opencontainers/runc/libcontainer/keys"
 	"github.com/opencontainers/runc/libcontainer/seccomp"
 	"github.com/opencontainers/runc/libcontainer/system"
@@ -54,6 +55,26 @@ func (l *linuxSetnsInit) Init() error {
 			return err
 		}
 	}
+	if l.config.Config.Personality != nil {
+		if err := setupPersonality(l.config.Config); err != nil {
+			return err
+		}
+	}
+	setupUmask(l.config.Umask)
+	if err := setupMemoryPolicy(l.config.Config); err != nil {
+		return err
+	}
+	if a := l.config.CPUAffinity; a != nil && a.Final != "" {
+		// This thread is the one to exec the process, and the
+		// container cgroup has been joined by now.
+		cpus, err := configs.ToCPUSet(a.Final)
+		if err != nil {
+			return err
+		}
+		if err := unix.SchedSetaffinity(0, cpus); err != nil {
+			return fmt.Errorf("error setting final cpu affinity: %w", err)
+		}
+	}
 	if l.config.NoNewPrivileges {
 		if err := unix.Prctl(unix.PR_SET_NO_NEW_PRIVS, 1, 0, 0, 0); err != nil {
 			return err
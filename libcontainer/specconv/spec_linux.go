@@ -8,11 +8,11 @@ import (
 	"os"
 	"path/filepath"
 	"sort"
+	"strconv"
 	"strings"
 	"sync"
 	"time"
 
-	systemdDbus "github.com/coreos/go-systemd/v22/dbus"
 	dbus "github.com/godbus/dbus/v5"
 	"github.com/opencontainers/runc/libcontainer/cgroups"
 	"github.com/opencontainers/runc/libcontainer/configs"
@@ -49,6 +49,8 @@ func initMaps() {
 			specs.IPCNamespace:     configs.NEWIPC,
 			specs.UTSNamespace:     configs.NEWUTS,
 			specs.CgroupNamespace:  configs.NEWCGROUP,
+			// The runtime-spec version in use has no constant for it.
+			specs.LinuxNamespaceType("time"): configs.NEWTIME,
 		}
 
 		mountPropagationMapping = map[string]int{
@@ -125,7 +127,7 @@ func initMaps() {
 			"rnostrictatime": {true, unix.MOUNT_ATTR_STRICTATIME},
 			"rnosymfollow":   {false, unix.MOUNT_ATTR_NOSYMFOLLOW}, // since kernel 5.14
 			"rsymfollow":     {true, unix.MOUNT_ATTR_NOSYMFOLLOW},  // since kernel 5.14
-			// No support for MOUNT_ATTR_IDMAP yet (needs UserNS FD)
+			// MOUNT_ATTR_IDMAP is set by the "idmap" option, see setupIDMapMounts.
 		}
 
 		extensionFlags = map[string]struct {
@@ -166,6 +168,7 @@ func KnownMountOptions() []string {
 	for k := range extensionFlags {
 		res = append(res, k)
 	}
+	res = append(res, idmapOption)
 	sort.Strings(res)
 	return res
 }
@@ -322,6 +325,13 @@ type CreateOpts struct {
 	Spec             *specs.Spec
 	RootlessEUID     bool
 	RootlessCgroups  bool
+	// DefaultSystemdSlice, if set, is the slice to use with the systemd
+	// cgroup driver when the spec cgroupsPath does not specify one,
+	// instead of system.slice (or user.slice for rootless containers).
+	DefaultSystemdSlice string
+	// DefaultDevices, if not nil, is used instead of AllowedDevices as
+	// the set of devices which are automatically included.
+	DefaultDevices []*devices.Device
 }
 
 // CreateLibcontainerConfig creates a new libcontainer configuration from a
@@ -359,6 +369,15 @@ func CreateLibcontainerConfig(opts *CreateOpts) (*configs.Config, error) {
 		RootlessCgroups: opts.RootlessCgroups,
 	}
 
+	if config.CoreSched, err = initCoreSched(spec); err != nil {
+		return nil, err
+	}
+	config.ExecCPUAffinity = initExecCPUAffinity(spec)
+	config.MemoryPolicy = initMemoryPolicy(spec)
+	if config.MaskPathModes, err = initMaskPathModes(spec); err != nil {
+		return nil, err
+	}
+
 	for _, m := range spec.Mounts {
 		cm, err := createLibcontainerMount(cwd, m)
 		if err != nil {
@@ -367,7 +386,13 @@ func CreateLibcontainerConfig(opts *CreateOpts) (*configs.Config, error) {
 		config.Mounts = append(config.Mounts, cm)
 	}
 
-	defaultDevs, err := createDevices(spec, config)
+	allowedDevs := opts.DefaultDevices
+	if allowedDevs == nil {
+		if allowedDevs, err = initDefaultDevices(spec); err != nil {
+			return nil, err
+		}
+	}
+	defaultDevs, err := createDevices(spec, config, allowedDevs)
 	if err != nil {
 		return nil, err
 	}
@@ -425,6 +450,18 @@ func CreateLibcontainerConfig(opts *CreateOpts) (*configs.Config, error) {
 			}
 			config.Seccomp = seccomp
 		}
+		if spec.Linux.Personality != nil {
+			if len(spec.Linux.Personality.Flags) > 0 {
+				logrus.Warnf("ignoring unsupported personality flags: %+v", spec.Linux.Personality.Flags)
+			}
+			domain, err := getLinuxPersonalityFromStr(string(spec.Linux.Personality.Domain))
+			if err != nil {
+				return nil, err
+			}
+			config.Personality = &configs.LinuxPersonality{
+				Domain: domain,
+			}
+		}
 		if spec.Linux.IntelRdt != nil {
 			config.IntelRdt = &configs.IntelRdt{
 				ClosID:        spec.Linux.IntelRdt.ClosID,
@@ -434,6 +471,10 @@ func CreateLibcontainerConfig(opts *CreateOpts) (*configs.Config, error) {
 		}
 	}
 
+	if err := setupIDMapMounts(spec, config); err != nil {
+		return nil, err
+	}
+
 	// Set the host UID that should own the container's cgroup.
 	// This must be performed after setupUserNamespace, so that
 	// config.HostRootUID() returns the correct result.
@@ -580,9 +621,22 @@ func convertSecToUSec(value dbus.Variant) (dbus.Variant, error) {
 	return dbus.MakeVariant(sec), nil
 }
 
-func initSystemdProps(spec *specs.Spec) ([]systemdDbus.Property, error) {
+// isRuntimeProperty tells whether a systemd property can be changed for a
+// running unit. These are the resource control properties (see
+// systemd.resource-control(5)), except for device access ones, which are
+// always generated by runc itself.
+func isRuntimeProperty(name string) bool {
+	for _, prefix := range []string{"CPU", "Startup", "Allowed", "Memory", "IO", "BlockIO", "Tasks"} {
+		if strings.HasPrefix(name, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+func initSystemdProps(spec *specs.Spec) ([]configs.SystemdProperty, error) {
 	const keyPrefix = "org.systemd.property."
-	var sp []systemdDbus.Property
+	var sp []configs.SystemdProperty
 
 	for k, v := range spec.Annotations {
 		name := strings.TrimPrefix(k, keyPrefix)
@@ -608,12 +662,110 @@ func initSystemdProps(spec *specs.Spec) ([]systemdDbus.Property, error) {
 				}
 			}
 		}
-		sp = append(sp, systemdDbus.Property{Name: name, Value: value})
+		p := configs.SystemdProperty{Name: name, Value: value, Runtime: isRuntimeProperty(name)}
+		if err := p.Validate(); err != nil {
+			return nil, fmt.Errorf("annotation %s=%s: %w", k, v, err)
+		}
+		sp = append(sp, p)
 	}
 
 	return sp, nil
 }
 
+// Annotations to configure core scheduling, which is not (yet) in the
+// runtime-spec. If coreSchedAnnotation is "true", a new core scheduling
+// cookie is created for the container; coreSchedShareAnnotation is the PID
+// of a process (e.g. the init of the pod sandbox container) to share an
+// existing cookie with, and implies coreSchedAnnotation.
+const (
+	coreSchedAnnotation      = "org.opencontainers.runc.core-sched"
+	coreSchedShareAnnotation = "org.opencontainers.runc.core-sched.share-pid"
+)
+
+func initCoreSched(spec *specs.Spec) (*configs.CoreSched, error) {
+	var cs *configs.CoreSched
+	if v, ok := spec.Annotations[coreSchedAnnotation]; ok {
+		enable, err := strconv.ParseBool(v)
+		if err != nil {
+			return nil, fmt.Errorf("annotation %s=%s value parse error: %w", coreSchedAnnotation, v, err)
+		}
+		if enable {
+			cs = &configs.CoreSched{}
+		}
+	}
+	if v, ok := spec.Annotations[coreSchedShareAnnotation]; ok {
+		pid, err := strconv.Atoi(v)
+		if err != nil {
+			return nil, fmt.Errorf("annotation %s=%s value parse error: %w", coreSchedShareAnnotation, v, err)
+		}
+		cs = &configs.CoreSched{SharePid: pid}
+	}
+	return cs, nil
+}
+
+// Annotations to set the CPU affinity of the processes started by runc
+// exec (configs.CPUAffinity), which is not (yet) in the runtime-spec.
+const (
+	execCPUAffinityInitialAnnotation = "org.opencontainers.runc.exec-cpu-affinity.initial"
+	execCPUAffinityFinalAnnotation   = "org.opencontainers.runc.exec-cpu-affinity.final"
+)
+
+func initExecCPUAffinity(spec *specs.Spec) *configs.CPUAffinity {
+	initial := spec.Annotations[execCPUAffinityInitialAnnotation]
+	final := spec.Annotations[execCPUAffinityFinalAnnotation]
+	if initial == "" && final == "" {
+		return nil
+	}
+	return &configs.CPUAffinity{Initial: initial, Final: final}
+}
+
+// Annotations to set the NUMA memory policy of the container
+// (configs.MemoryPolicy), which is not (yet) in the runtime-spec. The
+// flags are comma-separated.
+const (
+	memoryPolicyModeAnnotation  = "org.opencontainers.runc.memory-policy.mode"
+	memoryPolicyNodesAnnotation = "org.opencontainers.runc.memory-policy.nodes"
+	memoryPolicyFlagsAnnotation = "org.opencontainers.runc.memory-policy.flags"
+)
+
+func initMemoryPolicy(spec *specs.Spec) *configs.MemoryPolicy {
+	mode, ok := spec.Annotations[memoryPolicyModeAnnotation]
+	if !ok {
+		return nil
+	}
+	p := &configs.MemoryPolicy{
+		Mode:  mode,
+		Nodes: spec.Annotations[memoryPolicyNodesAnnotation],
+	}
+	if flags := spec.Annotations[memoryPolicyFlagsAnnotation]; flags != "" {
+		p.Flags = strings.Split(flags, ",")
+	}
+	return p
+}
+
+// maskPathModeAnnotationPrefix, followed by a masked path, is the prefix of
+// the annotations setting how the masked path is masked (configs.MaskMode),
+// which is not (yet) in the runtime-spec.
+const maskPathModeAnnotationPrefix = "org.opencontainers.runc.masked-path-mode."
+
+func initMaskPathModes(spec *specs.Spec) (map[string]configs.MaskMode, error) {
+	var modes map[string]configs.MaskMode
+	for k, v := range spec.Annotations {
+		if !strings.HasPrefix(k, maskPathModeAnnotationPrefix) {
+			continue
+		}
+		path := strings.TrimPrefix(k, maskPathModeAnnotationPrefix)
+		if path == "" {
+			return nil, fmt.Errorf("annotation %s=%s: no path", k, v)
+		}
+		if modes == nil {
+			modes = make(map[string]configs.MaskMode)
+		}
+		modes[path] = configs.MaskMode(v)
+	}
+	return modes, nil
+}
+
 func CreateCgroupConfig(opts *CreateOpts, defaultDevs []*devices.Device) (*configs.Cgroup, error) {
 	var (
 		myCgroupPath string
@@ -647,7 +799,6 @@ func CreateCgroupConfig(opts *CreateOpts, defaultDevs []*devices.Device) (*confi
 
 	if useSystemdCgroup {
 		if myCgroupPath == "" {
-			// Default for c.Parent is set by systemd cgroup drivers.
 			c.ScopePrefix = "runc"
 			c.Name = name
 		} else {
@@ -661,6 +812,11 @@ func CreateCgroupConfig(opts *CreateOpts, defaultDevs []*devices.Device) (*confi
 			c.ScopePrefix = parts[1]
 			c.Name = parts[2]
 		}
+		if c.Parent == "" {
+			// If still empty, the default is set by systemd
+			// cgroup drivers.
+			c.Parent = opts.DefaultSystemdSlice
+		}
 	} else {
 		if myCgroupPath == "" {
 			c.Name = name
@@ -866,13 +1022,58 @@ func stringToDeviceRune(s string) (devices.Type, error) {
 	}
 }
 
-func createDevices(spec *specs.Spec, config *configs.Config) ([]*devices.Device, error) {
+func getLinuxPersonalityFromStr(domain string) (int, error) {
+	switch specs.LinuxPersonalityDomain(domain) {
+	case specs.PerLinux:
+		return configs.PerLinux, nil
+	case specs.PerLinux32:
+		return configs.PerLinux32, nil
+	}
+	return -1, fmt.Errorf("personality domain %q is not supported", domain)
+}
+
+// defaultDevicesAnnotation is the annotation to only include some of the
+// AllowedDevices device nodes: it is a comma-separated list of their paths,
+// or "none". The AllowedDevices rules with no device node are included
+// regardless.
+const defaultDevicesAnnotation = "org.opencontainers.runc.default-devices"
+
+func initDefaultDevices(spec *specs.Spec) ([]*devices.Device, error) {
+	v, ok := spec.Annotations[defaultDevicesAnnotation]
+	if !ok {
+		return AllowedDevices, nil
+	}
+	keep := make(map[string]bool)
+	if v != "none" {
+		for _, path := range strings.Split(v, ",") {
+			keep[path] = false
+		}
+	}
+	var devs []*devices.Device
+	for _, ad := range AllowedDevices {
+		if ad.Path != "" {
+			if _, ok := keep[ad.Path]; !ok {
+				continue
+			}
+			keep[ad.Path] = true
+		}
+		devs = append(devs, ad)
+	}
+	for path, found := range keep {
+		if !found {
+			return nil, fmt.Errorf("annotation %s=%s: %q is not a default device", defaultDevicesAnnotation, v, path)
+		}
+	}
+	return devs, nil
+}
+
+func createDevices(spec *specs.Spec, config *configs.Config, allowedDevs []*devices.Device) ([]*devices.Device, error) {
 	// If a spec device is redundant with a default device, remove that default
 	// device (the spec one takes priority).
 	dedupedAllowDevs := []*devices.Device{}
 
 next:
-	for _, ad := range AllowedDevices {
+	for _, ad := range allowedDevs {
 		if ad.Path != "" && spec.Linux != nil {
 			for _, sd := range spec.Linux.Devices {
 				if sd.Path == ad.Path {
@@ -954,6 +1155,33 @@ func setupUserNamespace(spec *specs.Spec, config *configs.Config) error {
 	return nil
 }
 
+// idmapOption is the mount option to make a bind mount id-mapped.
+const idmapOption = "idmap"
+
+// setupIDMapMounts makes the mounts with the "idmap" option id-mapped,
+// using the mappings of the container user namespace. This must be
+// performed after setupUserNamespace.
+func setupIDMapMounts(spec *specs.Spec, config *configs.Config) error {
+	for i, m := range spec.Mounts {
+		idmap := false
+		for _, o := range m.Options {
+			if o == idmapOption {
+				idmap = true
+				break
+			}
+		}
+		if !idmap {
+			continue
+		}
+		if !config.Namespaces.Contains(configs.NEWUSER) {
+			return fmt.Errorf("invalid mount %+v: %s option requires a user namespace", m, idmapOption)
+		}
+		config.Mounts[i].UIDMappings = config.UidMappings
+		config.Mounts[i].GIDMappings = config.GidMappings
+	}
+	return nil
+}
+
 // parseMountOptions parses options and returns a configs.Mount
 // structure with fields that depends on options set accordingly.
 func parseMountOptions(options []string) *configs.Mount {
@@ -992,6 +1220,8 @@ func parseMountOptions(options []string) *configs.Mount {
 			} else {
 				m.Extensions |= f.flag
 			}
+		} else if o == idmapOption {
+			// Handled by setupIDMapMounts.
 		} else {
 			data = append(data, o)
 		}
@@ -2,6 +2,7 @@ package specconv
 
 import (
 	"os"
+	"reflect"
 	"strings"
 	"testing"
 
@@ -458,6 +459,34 @@ func TestLinuxCgroupSystemdWithEmptyPath(t *testing.T) {
 	}
 }
 
+func TestLinuxCgroupSystemdDefaultSlice(t *testing.T) {
+	for cgroupsPath, expectedParent := range map[string]string{
+		"":                     "machine.slice",
+		":scopeprefix:name":    "machine.slice",
+		"parent:scopeprefix:n": "parent",
+	} {
+		spec := &specs.Spec{}
+		spec.Linux = &specs.Linux{
+			CgroupsPath: cgroupsPath,
+		}
+
+		opts := &CreateOpts{
+			CgroupName:          "ContainerID",
+			UseSystemdCgroup:    true,
+			DefaultSystemdSlice: "machine.slice",
+			Spec:                spec,
+		}
+
+		cgroup, err := CreateCgroupConfig(opts, nil)
+		if err != nil {
+			t.Fatalf("Couldn't create Cgroup config: %v", err)
+		}
+		if cgroup.Parent != expectedParent {
+			t.Errorf("cgroupsPath %q: expected to have %s as Parent instead of %s", cgroupsPath, expectedParent, cgroup.Parent)
+		}
+	}
+}
+
 func TestLinuxCgroupSystemdWithInvalidPath(t *testing.T) {
 	cgroupsPath := "/user/cgroups/path/id"
 
@@ -593,6 +622,42 @@ func TestDupNamespaces(t *testing.T) {
 	}
 }
 
+func TestIDMapMounts(t *testing.T) {
+	spec := Example()
+	spec.Root.Path = "/"
+	spec.Mounts = append(spec.Mounts, specs.Mount{
+		Destination: "/data",
+		Type:        "bind",
+		Source:      "/srv/data",
+		Options:     []string{"rbind", "idmap"},
+	})
+	idmap := []specs.LinuxIDMapping{{ContainerID: 0, HostID: 100000, Size: 65536}}
+
+	opts := &CreateOpts{Spec: spec}
+	if _, err := CreateLibcontainerConfig(opts); err == nil {
+		t.Fatal("expected idmap mount without userns to fail")
+	}
+
+	spec.Linux.Namespaces = append(spec.Linux.Namespaces, specs.LinuxNamespace{Type: specs.UserNamespace})
+	spec.Linux.UIDMappings = idmap
+	spec.Linux.GIDMappings = idmap
+	config, err := CreateLibcontainerConfig(opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	m := config.Mounts[len(config.Mounts)-1]
+	if !m.IsIDMapped() {
+		t.Fatalf("expected mount %+v to be id-mapped", m)
+	}
+	if m.Data != "" {
+		t.Errorf("expected no mount data, got %q", m.Data)
+	}
+	exp := []configs.IDMap{{ContainerID: 0, HostID: 100000, Size: 65536}}
+	if !reflect.DeepEqual(m.UIDMappings, exp) || !reflect.DeepEqual(m.GIDMappings, exp) {
+		t.Errorf("expected mappings %+v, got %+v and %+v", exp, m.UIDMappings, m.GIDMappings)
+	}
+}
+
 func TestNonZeroEUIDCompatibleSpecconvValidate(t *testing.T) {
 	if _, err := os.Stat("/proc/self/ns/user"); os.IsNotExist(err) {
 		t.Skip("Test requires userns.")
@@ -837,7 +902,7 @@ func TestCreateDevices(t *testing.T) {
 
 	conf := &configs.Config{}
 
-	defaultDevs, err := createDevices(spec, conf)
+	defaultDevs, err := createDevices(spec, conf, AllowedDevices)
 	if err != nil {
 		t.Errorf("failed to create devices: %v", err)
 	}
@@ -907,3 +972,155 @@ func TestCreateDevices(t *testing.T) {
 		t.Errorf("device /dev/ram0 not found in config devices; got %v", conf.Devices)
 	}
 }
+
+func TestLinuxPersonality(t *testing.T) {
+	for _, tc := range []struct {
+		domain specs.LinuxPersonalityDomain
+		exp    int
+		isErr  bool
+	}{
+		{domain: specs.PerLinux, exp: configs.PerLinux},
+		{domain: specs.PerLinux32, exp: configs.PerLinux32},
+		{domain: "LINUX64", isErr: true},
+	} {
+		spec := Example()
+		spec.Root.Path = "/"
+		spec.Linux.Personality = &specs.LinuxPersonality{Domain: tc.domain}
+		config, err := CreateLibcontainerConfig(&CreateOpts{
+			CgroupName: "ContainerID",
+			Spec:       spec,
+		})
+		if tc.isErr {
+			if err == nil {
+				t.Errorf("%s: expected error, got nil", tc.domain)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: unexpected error: %v", tc.domain, err)
+			continue
+		}
+		if config.Personality == nil || config.Personality.Domain != tc.exp {
+			t.Errorf("%s: expected domain %#x, got %+v", tc.domain, tc.exp, config.Personality)
+		}
+	}
+}
+
+func TestInitCoreSched(t *testing.T) {
+	for _, tc := range []struct {
+		annotations map[string]string
+		exp         *configs.CoreSched
+		isErr       bool
+	}{
+		{},
+		{annotations: map[string]string{coreSchedAnnotation: "false"}},
+		{annotations: map[string]string{coreSchedAnnotation: "true"}, exp: &configs.CoreSched{}},
+		{annotations: map[string]string{coreSchedShareAnnotation: "1234"}, exp: &configs.CoreSched{SharePid: 1234}},
+		{annotations: map[string]string{coreSchedAnnotation: "yes"}, isErr: true},
+		{annotations: map[string]string{coreSchedShareAnnotation: "init"}, isErr: true},
+	} {
+		cs, err := initCoreSched(&specs.Spec{Annotations: tc.annotations})
+		if tc.isErr {
+			if err == nil {
+				t.Errorf("%v: expected error, got nil", tc.annotations)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%v: unexpected error: %v", tc.annotations, err)
+			continue
+		}
+		if !reflect.DeepEqual(cs, tc.exp) {
+			t.Errorf("%v: expected %+v, got %+v", tc.annotations, tc.exp, cs)
+		}
+	}
+}
+
+func TestInitMaskPathModes(t *testing.T) {
+	for _, tc := range []struct {
+		annotations map[string]string
+		exp         map[string]configs.MaskMode
+		isErr       bool
+	}{
+		{},
+		{annotations: map[string]string{"org.opencontainers.runc.other": "x"}},
+		{
+			annotations: map[string]string{
+				maskPathModeAnnotationPrefix + "/proc/acpi":  "readonly",
+				maskPathModeAnnotationPrefix + "/proc/kcore": "",
+			},
+			exp: map[string]configs.MaskMode{
+				"/proc/acpi":  configs.MaskReadonly,
+				"/proc/kcore": configs.MaskDefault,
+			},
+		},
+		{annotations: map[string]string{maskPathModeAnnotationPrefix: "tmpfs"}, isErr: true},
+	} {
+		modes, err := initMaskPathModes(&specs.Spec{Annotations: tc.annotations})
+		if tc.isErr {
+			if err == nil {
+				t.Errorf("%v: expected error, got nil", tc.annotations)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%v: unexpected error: %v", tc.annotations, err)
+			continue
+		}
+		if !reflect.DeepEqual(modes, tc.exp) {
+			t.Errorf("%v: expected %+v, got %+v", tc.annotations, tc.exp, modes)
+		}
+	}
+}
+
+func TestInitDefaultDevices(t *testing.T) {
+	var rules int
+	for _, d := range AllowedDevices {
+		if d.Path == "" {
+			rules++
+		}
+	}
+	for _, tc := range []struct {
+		annotation string
+		paths      []string
+		isErr      bool
+	}{
+		{annotation: "none"},
+		{annotation: "/dev/null,/dev/zero", paths: []string{"/dev/null", "/dev/zero"}},
+		{annotation: "/dev/null,/dev/sda", isErr: true},
+		{annotation: "", isErr: true},
+	} {
+		spec := &specs.Spec{Annotations: map[string]string{defaultDevicesAnnotation: tc.annotation}}
+		devs, err := initDefaultDevices(spec)
+		if tc.isErr {
+			if err == nil {
+				t.Errorf("%q: expected error, got nil", tc.annotation)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%q: unexpected error: %v", tc.annotation, err)
+			continue
+		}
+		var paths []string
+		for _, d := range devs {
+			if d.Path != "" {
+				paths = append(paths, d.Path)
+			}
+		}
+		if len(devs)-len(paths) != rules {
+			t.Errorf("%q: expected %d rules without a device node, got %d", tc.annotation, rules, len(devs)-len(paths))
+		}
+		if !reflect.DeepEqual(paths, tc.paths) {
+			t.Errorf("%q: expected devices %v, got %v", tc.annotation, tc.paths, paths)
+		}
+	}
+
+	devs, err := initDefaultDevices(&specs.Spec{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(devs, AllowedDevices) {
+		t.Errorf("expected AllowedDevices without the annotation, got %v", devs)
+	}
+}
@@ -24,8 +24,9 @@ type linuxStandardInit struct {
 	consoleSocket *os.File
 	parentPid     int
 	fifoFd        int
+	startPipeFd   int
 	logFd         int
-	mountFds      []int
+	mountFds      mountFds
 	config        *initConfig
 }
 
@@ -88,13 +89,15 @@ func (l *linuxStandardInit) Init() error {
 
 	// We don't need the mountFds after prepareRootfs() nor if it fails.
 	err := prepareRootfs(l.pipe, l.config, l.mountFds)
-	for _, m := range l.mountFds {
-		if m == -1 {
-			continue
-		}
+	for _, fds := range [][]int{l.mountFds.sourceFds, l.mountFds.detachedFds} {
+		for _, m := range fds {
+			if m == -1 {
+				continue
+			}
 
-		if err := unix.Close(m); err != nil {
-			return fmt.Errorf("Unable to close mountFds fds: %w", err)
+			if err := unix.Close(m); err != nil {
+				return fmt.Errorf("Unable to close mountFds fds: %w", err)
+			}
 		}
 	}
 
@@ -120,6 +123,10 @@ func (l *linuxStandardInit) Init() error {
 			return err
 		}
 	}
+	setupUmask(l.config.Umask)
+	if err := setupMemoryPolicy(l.config.Config); err != nil {
+		return err
+	}
 
 	if hostname := l.config.Config.Hostname; hostname != "" {
 		if err := unix.Sethostname([]byte(hostname)); err != nil {
@@ -141,10 +148,15 @@ func (l *linuxStandardInit) Init() error {
 		}
 	}
 	for _, path := range l.config.Config.MaskPaths {
-		if err := maskPath(path, l.config.Config.MountLabel); err != nil {
+		if err := maskPath(path, l.config.Config.MountLabel, l.config.Config.MaskPathModes[path]); err != nil {
 			return fmt.Errorf("can't mask path %s: %w", path, err)
 		}
 	}
+	if l.config.Config.Personality != nil {
+		if err := setupPersonality(l.config.Config); err != nil {
+			return fmt.Errorf("unable to set personality: %w", err)
+		}
+	}
 	pdeath, err := system.GetParentDeathSignal()
 	if err != nil {
 		return fmt.Errorf("can't get pdeath signal: %w", err)
@@ -222,31 +234,38 @@ func (l *linuxStandardInit) Init() error {
 		return &os.PathError{Op: "close log pipe", Path: "fd " + strconv.Itoa(l.logFd), Err: err}
 	}
 
-	// Wait for the FIFO to be opened on the other side before exec-ing the
-	// user process. We open it through /proc/self/fd/$fd, because the fd that
-	// was given to us was an O_PATH fd to the fifo itself. Linux allows us to
-	// re-open an O_PATH fd through /proc.
-	fifoPath := "/proc/self/fd/" + strconv.Itoa(l.fifoFd)
-	fd, err := unix.Open(fifoPath, unix.O_WRONLY|unix.O_CLOEXEC, 0)
-	if err != nil {
-		return &os.PathError{Op: "open exec fifo", Path: fifoPath, Err: err}
-	}
-	if _, err := unix.Write(fd, []byte("0")); err != nil {
-		return &os.PathError{Op: "write exec fifo", Path: fifoPath, Err: err}
-	}
+	if l.startPipeFd >= 0 {
+		// Wait for the container to be started using the start pipe.
+		if err := waitStartPipe(l.startPipeFd); err != nil {
+			return err
+		}
+	} else {
+		// Wait for the FIFO to be opened on the other side before exec-ing the
+		// user process. We open it through /proc/self/fd/$fd, because the fd that
+		// was given to us was an O_PATH fd to the fifo itself. Linux allows us to
+		// re-open an O_PATH fd through /proc.
+		fifoPath := "/proc/self/fd/" + strconv.Itoa(l.fifoFd)
+		fd, err := unix.Open(fifoPath, unix.O_WRONLY|unix.O_CLOEXEC, 0)
+		if err != nil {
+			return &os.PathError{Op: "open exec fifo", Path: fifoPath, Err: err}
+		}
+		if _, err := unix.Write(fd, []byte("0")); err != nil {
+			return &os.PathError{Op: "write exec fifo", Path: fifoPath, Err: err}
+		}
 
-	// Close the O_PATH fifofd fd before exec because the kernel resets
-	// dumpable in the wrong order. This has been fixed in newer kernels, but
-	// we keep this to ensure CVE-2016-9962 doesn't re-emerge on older kernels.
-	// N.B. the core issue itself (passing dirfds to the host filesystem) has
-	// since been resolved.
-	// https://github.com/torvalds/linux/blob/v4.9/fs/exec.c#L1290-L1318
-	_ = unix.Close(l.fifoFd)
+		// Close the O_PATH fifofd fd before exec because the kernel resets
+		// dumpable in the wrong order. This has been fixed in newer kernels, but
+		// we keep this to ensure CVE-2016-9962 doesn't re-emerge on older kernels.
+		// N.B. the core issue itself (passing dirfds to the host filesystem) has
+		// since been resolved.
+		// https://github.com/torvalds/linux/blob/v4.9/fs/exec.c#L1290-L1318
+		_ = unix.Close(l.fifoFd)
+	}
 
 	s := l.config.SpecState
 	s.Pid = unix.Getpid()
 	s.Status = specs.StateCreated
-	if err := l.config.Config.Hooks[configs.StartContainer].RunHooks(s); err != nil {
+	if err := l.config.Config.Hooks.Run(configs.StartContainer, s); err != nil {
 		return err
 	}
 
@@ -0,0 +1,132 @@
+package libcontainer
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"strconv"
+
+	"golang.org/x/sys/unix"
+)
+
+// StartPipe describes the pipe which is used to start the container, if
+// Process.StartPipe is set, instead of the exec fifo.
+type StartPipe struct {
+	// Fd is the pipe file descriptor number in the container init.
+	Fd int `json:"fd"`
+	// Inode is the pipe inode number.
+	Inode uint64 `json:"inode"`
+}
+
+// includeStartPipe adds the read end of the start pipe to the given
+// exec.Cmd as an inherited fd, with _LIBCONTAINER_STARTPIPE set to its fd
+// number. The container init waits for a byte to be written to the pipe
+// before running the user process, and the container is in the created
+// state as long as the init has the pipe open.
+func (c *linuxContainer) includeStartPipe(cmd *exec.Cmd, pipe *os.File) error {
+	var st unix.Stat_t
+	if err := unix.Fstat(int(pipe.Fd()), &st); err != nil {
+		return &os.PathError{Op: "fstat", Path: pipe.Name(), Err: err}
+	}
+	if st.Mode&unix.S_IFMT != unix.S_IFIFO {
+		return fmt.Errorf("start pipe %s is not a pipe", pipe.Name())
+	}
+	// The container init needs to reopen the pipe for writing (see
+	// waitStartPipe) after switching to the container user, so make it
+	// writable by everyone, as the exec fifo is (see createExecFifo).
+	rootuid, err := c.Config().HostRootUID()
+	if err != nil {
+		return err
+	}
+	rootgid, err := c.Config().HostRootGID()
+	if err != nil {
+		return err
+	}
+	if err := unix.Fchown(int(pipe.Fd()), rootuid, rootgid); err != nil {
+		return &os.PathError{Op: "fchown", Path: pipe.Name(), Err: err}
+	}
+	if err := unix.Fchmod(int(pipe.Fd()), 0o622); err != nil {
+		return &os.PathError{Op: "fchmod", Path: pipe.Name(), Err: err}
+	}
+
+	cmd.ExtraFiles = append(cmd.ExtraFiles, pipe)
+	fd := stdioFdCount + len(cmd.ExtraFiles) - 1
+	cmd.Env = append(cmd.Env, "_LIBCONTAINER_STARTPIPE="+strconv.Itoa(fd))
+	c.startPipe = &StartPipe{Fd: fd, Inode: st.Ino}
+	return nil
+}
+
+func (s *StartPipe) path(pid int) string {
+	return "/proc/" + strconv.Itoa(pid) + "/fd/" + strconv.Itoa(s.Fd)
+}
+
+func (s *StartPipe) is(st *unix.Stat_t) bool {
+	return st.Mode&unix.S_IFMT == unix.S_IFIFO && st.Ino == s.Inode
+}
+
+// waiting returns whether the process with the given pid (the container
+// init) is still waiting on the start pipe.
+func (s *StartPipe) waiting(pid int) bool {
+	var st unix.Stat_t
+	if err := unix.Stat(s.path(pid), &st); err != nil {
+		return false
+	}
+	return s.is(&st)
+}
+
+// start starts the container by writing to the start pipe of the process
+// with the given pid (the container init). The pipe is opened through
+// /proc, so this works even if the caller has no access to the other end
+// of the pipe.
+func (s *StartPipe) start(pid int) error {
+	f, err := os.OpenFile(s.path(pid), os.O_WRONLY|unix.O_NONBLOCK|unix.O_CLOEXEC, 0)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return errors.New("cannot start an already running container")
+		}
+		return fmt.Errorf("start pipe: %w", err)
+	}
+	defer f.Close()
+	// Now that the pipe is open, make sure it is the right one.
+	var st unix.Stat_t
+	if err := unix.Fstat(int(f.Fd()), &st); err != nil {
+		return &os.PathError{Op: "fstat", Path: f.Name(), Err: err}
+	}
+	if !s.is(&st) {
+		return errors.New("cannot start an already running container")
+	}
+	if _, err := f.Write([]byte("0")); err != nil {
+		return fmt.Errorf("start pipe: %w", err)
+	}
+	return nil
+}
+
+// waitStartPipe waits for a byte to be written to the start pipe, and then
+// closes it. It is used by the container init.
+func waitStartPipe(fd int) error {
+	// Reopen the write end of the pipe through /proc and keep it open, so
+	// that the read does not return EOF once all the other write ends are
+	// closed, and the container can still be started (see StartPipe.start).
+	pipePath := "/proc/self/fd/" + strconv.Itoa(fd)
+	wfd, err := unix.Open(pipePath, unix.O_WRONLY|unix.O_NONBLOCK|unix.O_CLOEXEC, 0)
+	if err != nil {
+		return &os.PathError{Op: "open start pipe", Path: pipePath, Err: err}
+	}
+	defer unix.Close(wfd)
+	if err := unix.SetNonblock(fd, false); err != nil {
+		return &os.PathError{Op: "set start pipe blocking", Path: pipePath, Err: err}
+	}
+	buf := make([]byte, 1)
+	for {
+		_, err := unix.Read(fd, buf)
+		if errors.Is(err, unix.EINTR) {
+			continue
+		}
+		if err != nil {
+			return &os.PathError{Op: "read start pipe", Path: pipePath, Err: err}
+		}
+		break
+	}
+	return unix.Close(fd)
+}
@@ -0,0 +1,90 @@
+package libcontainer
+
+import (
+	"os"
+	"testing"
+	"time"
+
+	"golang.org/x/sys/unix"
+)
+
+func newTestStartPipe(t *testing.T) (*StartPipe, *os.File, *os.File) {
+	t.Helper()
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		r.Close()
+		w.Close()
+	})
+	var st unix.Stat_t
+	if err := unix.Fstat(int(r.Fd()), &st); err != nil {
+		t.Fatal(err)
+	}
+	return &StartPipe{Fd: int(r.Fd()), Inode: st.Ino}, r, w
+}
+
+func TestStartPipe(t *testing.T) {
+	s, r, _ := newTestStartPipe(t)
+	pid := os.Getpid()
+	if !s.waiting(pid) {
+		t.Fatal("expected the start pipe to be waiting")
+	}
+	if err := s.start(pid); err != nil {
+		t.Fatal(err)
+	}
+	buf := make([]byte, 1)
+	if _, err := r.Read(buf); err != nil {
+		t.Fatal(err)
+	}
+
+	// A different pipe at the same fd number.
+	other, _, _ := newTestStartPipe(t)
+	other.Fd = s.Fd
+	if other.waiting(pid) {
+		t.Fatal("expected a different pipe not to be waiting")
+	}
+	if err := other.start(pid); err == nil {
+		t.Fatal("expected an error starting a different pipe, got nil")
+	}
+
+	r.Close()
+	if s.waiting(pid) {
+		t.Fatal("expected a closed start pipe not to be waiting")
+	}
+	if err := s.start(pid); err == nil {
+		t.Fatal("expected an error starting a closed pipe, got nil")
+	}
+}
+
+func TestWaitStartPipe(t *testing.T) {
+	_, r, w := newTestStartPipe(t)
+	fd, err := unix.Dup(int(r.Fd()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	done := make(chan error, 1)
+	go func() {
+		done <- waitStartPipe(fd)
+	}()
+	// Closing the caller's write end must not start the container.
+	w.Close()
+	select {
+	case err := <-done:
+		t.Fatalf("unexpected return from waitStartPipe: %v", err)
+	case <-time.After(100 * time.Millisecond):
+	}
+	s := &StartPipe{Fd: fd}
+	var st unix.Stat_t
+	if err := unix.Fstat(fd, &st); err != nil {
+		t.Fatal(err)
+	}
+	s.Inode = st.Ino
+	if err := s.start(os.Getpid()); err != nil {
+		t.Fatal(err)
+	}
+	if err := <-done; err != nil {
+		t.Fatal(err)
+	}
+}
@@ -25,6 +25,12 @@ type syncType string
 //
 // procSeccomp --> [pick up seccomp fd with pidfd_getfd()]
 //             <-- procSeccompDone
+//
+// The messages are JSON encoded syncT values, so that new fields (such as
+// a message specific Arg) can be added without breaking older peers, which
+// ignore unknown fields. New messages must only be sent by the child if the
+// parent has advertised them, see syncFeatures. Changes which older children
+// can't cope with must bump syncMinVersion.
 const (
 	procError       syncType = "procError"
 	procReady       syncType = "procReady"
@@ -35,9 +41,53 @@ const (
 	procSeccompDone syncType = "procSeccompDone"
 )
 
+// syncVersion is the version of the synchronisation protocol, which the
+// parent sends to the child in initConfig. Version 0 is the protocol used
+// before it was versioned, i.e. the messages above with no Arg.
+const syncVersion = 1
+
+// syncMinVersion is the oldest version of the synchronisation protocol the
+// child may use to talk to the parent, which the parent sends to the child in
+// initConfig along with syncVersion.
+const syncMinVersion = 0
+
+// syncFeatures are the optional synchronisation messages, added after
+// version 1 of the protocol, that the parent knows how to handle. The parent
+// sends them to the child in initConfig, and the child must check that a
+// message is supported (see initConfig.syncSupported) before sending it, as
+// a parent from another runc version fails on an unknown message.
+var syncFeatures = []syncType{}
+
+// checkSyncVersion checks that the child can talk to a parent using the
+// given version of the synchronisation protocol, and requiring the child to
+// use at least minVersion. A parent using a newer version is fine, unless it
+// is incompatible with the version used by the child.
+func checkSyncVersion(version, minVersion int) error {
+	if version < 0 || minVersion < 0 || minVersion > version {
+		return fmt.Errorf("invalid sync protocol version %d (minimum %d)", version, minVersion)
+	}
+	if minVersion > syncVersion {
+		return fmt.Errorf("unsupported sync protocol version %d: version %d or later is required, but only up to %d is supported", version, minVersion, syncVersion)
+	}
+	return nil
+}
+
 type syncT struct {
 	Type syncType `json:"type"`
 	Fd   int      `json:"fd"`
+	// Arg is an optional message specific payload.
+	Arg *json.RawMessage `json:"arg,omitempty"`
+}
+
+// decodeArg decodes the message payload into v.
+func (s *syncT) decodeArg(v interface{}) error {
+	if s.Arg == nil {
+		return fmt.Errorf("syncT %q: missing payload", string(s.Type))
+	}
+	if err := json.Unmarshal(*s.Arg, v); err != nil {
+		return fmt.Errorf("syncT %q: decoding payload: %w", string(s.Type), err)
+	}
+	return nil
 }
 
 // initError is used to wrap errors for passing them via JSON,
@@ -59,7 +109,22 @@ func writeSync(pipe io.Writer, sync syncType) error {
 // writeSyncWithFd is used to write to a synchronisation pipe. An error is
 // returned if there was a problem writing the payload.
 func writeSyncWithFd(pipe io.Writer, sync syncType, fd int) error {
-	if err := utils.WriteJSON(pipe, syncT{sync, fd}); err != nil {
+	if err := utils.WriteJSON(pipe, syncT{Type: sync, Fd: fd}); err != nil {
+		return fmt.Errorf("writing syncT %q: %w", string(sync), err)
+	}
+	return nil
+}
+
+// writeSyncWithArg is used to write to a synchronisation pipe, with arg as
+// the message payload. An error is returned if there was a problem writing
+// the payload.
+func writeSyncWithArg(pipe io.Writer, sync syncType, arg interface{}) error {
+	data, err := json.Marshal(arg)
+	if err != nil {
+		return fmt.Errorf("encoding syncT %q payload: %w", string(sync), err)
+	}
+	raw := json.RawMessage(data)
+	if err := utils.WriteJSON(pipe, syncT{Type: sync, Fd: -1, Arg: &raw}); err != nil {
 		return fmt.Errorf("writing syncT %q: %w", string(sync), err)
 	}
 	return nil
@@ -87,7 +152,7 @@ func readSync(pipe io.Reader, expected syncType) error {
 	}
 
 	if procSync.Type != expected {
-		return errors.New("invalid synchronisation flag from parent")
+		return fmt.Errorf("invalid synchronisation flag from parent: got %q, expected %q", string(procSync.Type), string(expected))
 	}
 	return nil
 }
@@ -0,0 +1,97 @@
+package libcontainer
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestSyncWithArg(t *testing.T) {
+	type payload struct {
+		Path string `json:"path"`
+	}
+	var buf bytes.Buffer
+	if err := writeSyncWithArg(&buf, procHooks, payload{Path: "/foo"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := writeSync(&buf, procReady); err != nil {
+		t.Fatal(err)
+	}
+
+	var got []syncType
+	err := parseSync(&buf, func(sync *syncT) error {
+		got = append(got, sync.Type)
+		switch sync.Type {
+		case procHooks:
+			var p payload
+			if err := sync.decodeArg(&p); err != nil {
+				return err
+			}
+			if p.Path != "/foo" {
+				t.Errorf("expected payload path /foo, got %q", p.Path)
+			}
+		case procReady:
+			var p payload
+			if err := sync.decodeArg(&p); err == nil {
+				t.Error("expected an error decoding a missing payload, got nil")
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 2 || got[0] != procHooks || got[1] != procReady {
+		t.Fatalf("unexpected messages: %v", got)
+	}
+}
+
+func TestSyncUnversioned(t *testing.T) {
+	// A message from a peer predating the protocol versioning.
+	buf := strings.NewReader(`{"type":"procRun","fd":-1}`)
+	if err := readSync(buf, procRun); err != nil {
+		t.Fatal(err)
+	}
+	buf = strings.NewReader(`{"type":"procRun","fd":-1}`)
+	if err := readSync(buf, procResume); err == nil {
+		t.Fatal("expected an error for an unexpected message, got nil")
+	}
+}
+
+func TestSyncSupported(t *testing.T) {
+	const procFoo syncType = "procFoo"
+	// An older parent which has not advertised any optional messages.
+	var c initConfig
+	if c.syncSupported(procFoo) {
+		t.Fatalf("expected %q not to be supported", procFoo)
+	}
+	c.SyncVersion = syncVersion
+	c.SyncFeatures = []syncType{procFoo}
+	if !c.syncSupported(procFoo) {
+		t.Fatalf("expected %q to be supported", procFoo)
+	}
+}
+
+func TestCheckSyncVersion(t *testing.T) {
+	for _, tc := range []struct {
+		version, minVersion int
+		isErr               bool
+	}{
+		// A parent predating the protocol versioning sends no version.
+		{version: 0, minVersion: 0},
+		{version: syncVersion, minVersion: syncMinVersion},
+		// A newer parent, which still supports this child.
+		{version: syncVersion + 1, minVersion: syncVersion},
+		// A newer parent, which requires a newer child.
+		{version: syncVersion + 1, minVersion: syncVersion + 1, isErr: true},
+		{version: -1, minVersion: 0, isErr: true},
+		{version: 1, minVersion: 2, isErr: true},
+	} {
+		err := checkSyncVersion(tc.version, tc.minVersion)
+		if tc.isErr && err == nil {
+			t.Errorf("%+v: expected an error, got nil", tc)
+		} else if !tc.isErr && err != nil {
+			t.Errorf("%+v: expected no error, got %v", tc, err)
+		}
+	}
+}
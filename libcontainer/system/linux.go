@@ -102,3 +102,48 @@ func GetSubreaper() (int, error) {
 
 	return int(i), nil
 }
+
+// SetLinuxPersonality sets the Linux execution domain (personality)
+// of the calling process.
+func SetLinuxPersonality(personality int) error {
+	_, _, errno := unix.Syscall(unix.SYS_PERSONALITY, uintptr(personality), 0, 0)
+	if errno != 0 {
+		return &os.SyscallError{Syscall: "set_personality", Err: errno}
+	}
+	return nil
+}
+
+// atClkTck is the type of the auxiliary vector entry holding the clock tick
+// rate, see getauxval(3).
+const atClkTck = 17
+
+// ClockTicks returns the number of clock ticks per second (USER_HZ), which
+// is the unit of the process times in /proc/[pid]/stat. It is taken from
+// the auxiliary vector of the current process, falling back to 100 (the
+// value used on most architectures) if it can't be read.
+func ClockTicks() uint64 {
+	auxv, err := os.ReadFile("/proc/self/auxv")
+	if err == nil {
+		if v, ok := auxvValue(auxv, atClkTck); ok && v > 0 {
+			return uint64(v)
+		}
+	}
+	return 100
+}
+
+// auxvValue looks up the value of the given type in the auxiliary vector,
+// which is a sequence of native word sized (type, value) pairs, terminated
+// by AT_NULL.
+func auxvValue(auxv []byte, typ uintptr) (uintptr, bool) {
+	const size = int(unsafe.Sizeof(uintptr(0)))
+	for i := 0; i+2*size <= len(auxv); i += 2 * size {
+		t := *(*uintptr)(unsafe.Pointer(&auxv[i]))
+		if t == 0 {
+			break
+		}
+		if t == typ {
+			return *(*uintptr)(unsafe.Pointer(&auxv[i+size])), true
+		}
+	}
+	return 0, false
+}
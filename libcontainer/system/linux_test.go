@@ -0,0 +1,26 @@
+//go:build linux
+// +build linux
+
+package system
+
+import (
+	"testing"
+	"unsafe"
+)
+
+func TestAuxvValue(t *testing.T) {
+	words := []uintptr{6, 4096, atClkTck, 250, 0, 0, 42, 1}
+	auxv := (*[8 * 8]byte)(unsafe.Pointer(&words[0]))[:len(words)*int(unsafe.Sizeof(words[0]))]
+
+	if v, ok := auxvValue(auxv, atClkTck); !ok || v != 250 {
+		t.Errorf("expected 250, got %d (found: %v)", v, ok)
+	}
+	// Entries after AT_NULL are ignored.
+	if _, ok := auxvValue(auxv, 42); ok {
+		t.Error("found an entry after AT_NULL")
+	}
+	// Truncated data.
+	if _, ok := auxvValue(auxv[:len(auxv)/4-1], atClkTck); ok {
+		t.Error("found an entry in truncated data")
+	}
+}
@@ -60,6 +60,9 @@ type Stat_t struct {
 	// State is the state of the process.
 	State State
 
+	// PPid is the PID of the parent of the process.
+	PPid int
+
 	// StartTime is the number of clock ticks after system boot (since
 	// Linux 2.6).
 	StartTime uint64
@@ -83,6 +86,7 @@ func parseStat(data string) (stat Stat_t, err error) {
 	//  * field 2: process name. It is the only field enclosed into
 	//    parenthesis, as it can contain spaces (and parenthesis) inside.
 	//  * field 3: process state, a single character (%c)
+	//  * field 4: parent process PID (%d)
 	//  * field 22: process start time, a long unsigned integer (%llu).
 
 	// 1. Look for the first '(' and the last ')' first, what's in between is Name.
@@ -106,7 +110,17 @@ func parseStat(data string) (stat Stat_t, err error) {
 	data = data[last+2:]
 	stat.State = State(data[0])
 
-	// 3. StartTime is field 22, data is at field 3 now, so we need to skip 19 spaces.
+	// 3. PPid is right after the state and a space.
+	i := strings.IndexByte(data[2:], ' ')
+	if i < 0 {
+		return stat, fmt.Errorf("invalid stat data (too short): %q", data)
+	}
+	stat.PPid, err = strconv.Atoi(data[2 : 2+i])
+	if err != nil {
+		return stat, fmt.Errorf("invalid stat data (bad ppid): %w", err)
+	}
+
+	// 4. StartTime is field 22, data is at field 3 now, so we need to skip 19 spaces.
 	skipSpaces := 22 - 3
 	for first = 0; skipSpaces > 0 && first < len(data); first++ {
 		if data[first] == ' ' {
@@ -114,7 +128,7 @@ func parseStat(data string) (stat Stat_t, err error) {
 		}
 	}
 	// Now first points to StartTime; look for space right after.
-	i := strings.IndexByte(data[first:], ' ')
+	i = strings.IndexByte(data[first:], ' ')
 	if i < 0 {
 		return stat, fmt.Errorf("invalid stat data (too short): %q", data)
 	}
@@ -125,3 +139,13 @@ func parseStat(data string) (stat Stat_t, err error) {
 
 	return stat, nil
 }
+
+// BootID returns the boot ID of the running kernel, a random UUID which is
+// generated anew on each boot.
+func BootID() (string, error) {
+	data, err := os.ReadFile("/proc/sys/kernel/random/boot_id")
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(string(data)), nil
+}
@@ -5,8 +5,10 @@ import (
 	"errors"
 	"fmt"
 	"os"
+	"strings"
 	"syscall"
 	"text/tabwriter"
+	"text/template"
 	"time"
 
 	"github.com/opencontainers/runc/libcontainer"
@@ -17,6 +19,10 @@ import (
 
 const formatOptions = `table or json`
 
+const listFormatOptions = `table, json, or a Go template (e.g. '{{.ID}} {{.Status}}')`
+
+const filterOptions = `status=STATUS or annotation=KEY[=VALUE]`
+
 // containerState represents the platform agnostic pieces relating to a
 // running container's status and state
 type containerState struct {
@@ -54,26 +60,39 @@ To list containers created via the default "--root":
 
 EXAMPLE 2:
 To list containers created using a non-default value for "--root":
-       # runc --root value list`,
+       # runc --root value list
+
+EXAMPLE 3:
+To list the IDs of the running and paused containers:
+       # runc list --filter status=running --filter status=paused --format '{{.ID}}'`,
 	Flags: []cli.Flag{
 		cli.StringFlag{
 			Name:  "format, f",
 			Value: "table",
-			Usage: `select one of: ` + formatOptions,
+			Usage: `select one of: ` + listFormatOptions,
 		},
 		cli.BoolFlag{
 			Name:  "quiet, q",
 			Usage: "display only container IDs",
 		},
+		cli.StringSliceFlag{
+			Name:  "filter",
+			Usage: `display only the containers matching the filter: ` + filterOptions + `; can be specified multiple times`,
+		},
 	},
 	Action: func(context *cli.Context) error {
 		if err := checkArgs(context, 0, exactArgs); err != nil {
 			return err
 		}
+		filter, err := parseListFilter(context.StringSlice("filter"))
+		if err != nil {
+			return err
+		}
 		s, err := getContainers(context)
 		if err != nil {
 			return err
 		}
+		s = filter.apply(s)
 
 		if context.Bool("quiet") {
 			for _, item := range s {
@@ -99,16 +118,109 @@ To list containers created using a non-default value for "--root":
 				return err
 			}
 		case "json":
+			// Always output an array, even if there are no containers.
+			if s == nil {
+				s = []containerState{}
+			}
 			if err := json.NewEncoder(os.Stdout).Encode(s); err != nil {
 				return err
 			}
 		default:
-			return errors.New("invalid format option")
+			format := context.String("format")
+			if !strings.Contains(format, "{{") {
+				return errors.New("invalid format option")
+			}
+			tmpl, err := template.New("list").Parse(format)
+			if err != nil {
+				return fmt.Errorf("invalid format template: %w", err)
+			}
+			for _, item := range s {
+				if err := tmpl.Execute(os.Stdout, item); err != nil {
+					return err
+				}
+				fmt.Println()
+			}
 		}
 		return nil
 	},
 }
 
+// listFilter selects the containers to list. A container matches the filter
+// if, for every key, it matches any of its values.
+type listFilter struct {
+	statuses    []string
+	annotations []string
+}
+
+func parseListFilter(filters []string) (*listFilter, error) {
+	var f listFilter
+	for _, filter := range filters {
+		kv := strings.SplitN(filter, "=", 2)
+		if len(kv) != 2 || kv[1] == "" {
+			return nil, fmt.Errorf("invalid filter %q: expected %s", filter, filterOptions)
+		}
+		switch kv[0] {
+		case "status":
+			switch kv[1] {
+			case "created", "running", "paused", "stopped":
+			default:
+				return nil, fmt.Errorf("invalid filter %q: unknown status %q", filter, kv[1])
+			}
+			f.statuses = append(f.statuses, kv[1])
+		case "annotation":
+			f.annotations = append(f.annotations, kv[1])
+		default:
+			return nil, fmt.Errorf("invalid filter %q: unknown key %q", filter, kv[0])
+		}
+	}
+	return &f, nil
+}
+
+func (f *listFilter) match(c *containerState) bool {
+	if len(f.statuses) > 0 && !f.matchStatus(c.Status) {
+		return false
+	}
+	if len(f.annotations) > 0 && !f.matchAnnotations(c.Annotations) {
+		return false
+	}
+	return true
+}
+
+func (f *listFilter) matchStatus(status string) bool {
+	for _, s := range f.statuses {
+		if s == status {
+			return true
+		}
+	}
+	return false
+}
+
+// matchAnnotations checks whether any of the annotation filters, in the
+// KEY or KEY=VALUE format, matches the container annotations.
+func (f *listFilter) matchAnnotations(annotations map[string]string) bool {
+	for _, a := range f.annotations {
+		kv := strings.SplitN(a, "=", 2)
+		v, ok := annotations[kv[0]]
+		if ok && (len(kv) == 1 || kv[1] == v) {
+			return true
+		}
+	}
+	return false
+}
+
+func (f *listFilter) apply(s []containerState) []containerState {
+	if len(f.statuses) == 0 && len(f.annotations) == 0 {
+		return s
+	}
+	var ret []containerState
+	for i := range s {
+		if f.match(&s[i]) {
+			ret = append(ret, s[i])
+		}
+	}
+	return ret
+}
+
 func getContainers(context *cli.Context) ([]containerState, error) {
 	root := context.GlobalString("root")
 	list, err := os.ReadDir(root)
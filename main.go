@@ -108,6 +108,14 @@ func main() {
 			Name:  "systemd-cgroup",
 			Usage: "enable systemd cgroup support, expects cgroupsPath to be of form \"slice:prefix:name\" for e.g. \"system.slice:runc:434234\"",
 		},
+		cli.StringFlag{
+			Name:  "systemd-default-slice",
+			Usage: "slice to use with the systemd cgroup driver if cgroupsPath does not specify one (default is system.slice, or user.slice for rootless containers)",
+		},
+		cli.StringFlag{
+			Name:  "cgroup-driver",
+			Usage: "cgroup driver to use ('cgroupfs', 'systemd', or 'auto'); default is 'systemd' if --systemd-cgroup is set, 'cgroupfs' otherwise",
+		},
 		cli.StringFlag{
 			Name:  "rootless",
 			Value: "auto",
@@ -2,6 +2,8 @@ package main
 
 import (
 	"bytes"
+	"errors"
+	"io"
 	"net"
 	"os"
 	"path"
@@ -11,7 +13,9 @@ import (
 
 	"github.com/opencontainers/runc/libcontainer"
 	"github.com/opencontainers/runtime-spec/specs-go"
+	"github.com/sirupsen/logrus"
 	"github.com/urfave/cli"
+	"golang.org/x/sys/unix"
 )
 
 type notifySocket struct {
@@ -99,6 +103,16 @@ func (s *notifySocket) waitForContainer(container libcontainer.Container) error
 	return s.run(state.InitProcessPid)
 }
 
+// notifyMsg is a datagram received on the container notify socket.
+type notifyMsg struct {
+	data []byte
+	fds  []int
+}
+
+// run forwards sd_notify messages from the container to the host notify
+// socket. If pid1 is not 0, it returns after the READY= message is
+// forwarded, informing systemd that pid1 is the main pid, or when pid1
+// exits. Otherwise, all messages are forwarded until the socket is closed.
 func (n *notifySocket) run(pid1 int) error {
 	if n.socket == nil {
 		return nil
@@ -108,62 +122,181 @@ func (n *notifySocket) run(pid1 int) error {
 	if err != nil {
 		return err
 	}
+	defer client.Close()
 
 	ticker := time.NewTicker(time.Millisecond * 100)
 	defer ticker.Stop()
 
-	fileChan := make(chan []byte)
+	msgChan := make(chan notifyMsg)
+	done := make(chan struct{})
+	defer close(done)
 	go func() {
 		for {
 			buf := make([]byte, 4096)
-			r, err := n.socket.Read(buf)
+			oob := make([]byte, unix.CmsgSpace(4*notifyMaxFds))
+			r, oobn, _, _, err := n.socket.ReadMsgUnix(buf, oob)
 			if err != nil {
 				return
 			}
-			got := buf[0:r]
-			// systemd-ready sends a single datagram with the state string as payload,
-			// so we don't need to worry about partial messages.
-			for _, line := range bytes.Split(got, []byte{'\n'}) {
-				if bytes.HasPrefix(got, []byte("READY=")) {
-					fileChan <- line
-					return
-				}
+			msg := notifyMsg{data: buf[:r], fds: parseRights(oob[:oobn])}
+			select {
+			case msgChan <- msg:
+			case <-done:
+				closeFds(msg.fds)
+				return
+			}
+			if pid1 != 0 && isReady(msg.data) {
+				return
 			}
-
 		}
 	}()
 
+	var tickerC <-chan time.Time
+	if pid1 != 0 {
+		tickerC = ticker.C
+	}
 	for {
 		select {
-		case <-ticker.C:
+		case <-tickerC:
 			_, err := os.Stat(filepath.Join("/proc", strconv.Itoa(pid1)))
 			if err != nil {
 				return nil
 			}
-		case b := <-fileChan:
-			var out bytes.Buffer
-			_, err = out.Write(b)
+		case msg := <-msgChan:
+			ready, err := forwardNotifyMsg(client, msg, pid1)
 			if err != nil {
 				return err
 			}
-
-			_, err = out.Write([]byte{'\n'})
-			if err != nil {
-				return err
+			if pid1 != 0 && ready {
+				return nil
 			}
+		}
+	}
+}
 
-			_, err = client.Write(out.Bytes())
-			if err != nil {
-				return err
-			}
+// notifyMaxFds is the maximum number of file descriptors accepted
+// with a single notify message.
+const notifyMaxFds = 16
 
-			// now we can inform systemd to use pid1 as the pid to monitor
-			newPid := "MAINPID=" + strconv.Itoa(pid1)
-			_, err := client.Write([]byte(newPid + "\n"))
-			if err != nil {
-				return err
-			}
-			return nil
+// forwardNotifyMsg forwards a single message received from the container
+// to the host, and reports whether it contained READY=1.
+//
+// Since the container has its own PID namespace, a MAINPID= sent by the
+// container is meaningless for the host and is dropped; instead, when
+// mainPid is not 0, MAINPID=mainPid is sent along with READY=1.
+//
+// BARRIER=1 (see sd_notify_barrier(3)) is handled by performing a barrier
+// with the host, and once it is done, closing the file descriptor received
+// from the container, to let it know all its previous messages have been
+// processed. Other file descriptors (e.g. FDSTORE=1) are not passed on.
+func forwardNotifyMsg(client *net.UnixConn, msg notifyMsg, mainPid int) (bool, error) {
+	defer closeFds(msg.fds)
+
+	var (
+		out     bytes.Buffer
+		ready   bool
+		barrier bool
+	)
+	for _, line := range bytes.Split(msg.data, []byte{'\n'}) {
+		switch {
+		case len(line) == 0:
+			continue
+		case bytes.HasPrefix(line, []byte("MAINPID=")):
+			continue
+		case bytes.Equal(line, []byte("BARRIER=1")):
+			barrier = true
+			continue
+		case bytes.HasPrefix(line, []byte("READY=")):
+			ready = true
+		}
+		out.Write(line)
+		out.WriteByte('\n')
+	}
+	if ready && mainPid != 0 {
+		// Inform systemd to use mainPid as the pid to monitor.
+		out.WriteString("MAINPID=" + strconv.Itoa(mainPid) + "\n")
+	}
+	if out.Len() > 0 {
+		if _, err := client.Write(out.Bytes()); err != nil {
+			return ready, err
+		}
+	}
+	if barrier || (ready && mainPid != 0) {
+		// Make sure systemd has processed the messages sent so far.
+		if err := sdNotifyBarrier(client); err != nil {
+			return ready, err
+		}
+	}
+	return ready, nil
+}
+
+func isReady(data []byte) bool {
+	for _, line := range bytes.Split(data, []byte{'\n'}) {
+		if bytes.HasPrefix(line, []byte("READY=")) {
+			return true
+		}
+	}
+	return false
+}
+
+// parseRights returns the file descriptors passed in SCM_RIGHTS messages.
+func parseRights(oob []byte) []int {
+	scms, err := unix.ParseSocketControlMessage(oob)
+	if err != nil {
+		return nil
+	}
+	var fds []int
+	for i := range scms {
+		rights, err := unix.ParseUnixRights(&scms[i])
+		if err != nil {
+			continue
 		}
+		fds = append(fds, rights...)
+	}
+	return fds
+}
+
+func closeFds(fds []int) {
+	for _, fd := range fds {
+		_ = unix.Close(fd)
+	}
+}
+
+var errUnexpectedRead = errors.New("unexpected read from synchronization pipe")
+
+// sdNotifyBarrier performs synchronization with systemd by means of the
+// sd_notify_barrier protocol: the write end of a pipe is sent along with
+// BARRIER=1, and systemd closes it once all previous messages are processed.
+func sdNotifyBarrier(client *net.UnixConn) error {
+	pipeR, pipeW, err := os.Pipe()
+	if err != nil {
+		return err
+	}
+	defer pipeR.Close()
+
+	fdRights := unix.UnixRights(int(pipeW.Fd()))
+	_, _, err = client.WriteMsgUnix([]byte("BARRIER=1"), fdRights, nil)
+	// Close our copy of pipeW.
+	pipeW.Close()
+	if err != nil {
+		return err
+	}
+
+	// Expect the read end of the pipe to be closed within 30 seconds.
+	if err := pipeR.SetReadDeadline(time.Now().Add(30 * time.Second)); err != nil {
+		return nil
+	}
+	var buf [1]byte
+	n, err := pipeR.Read(buf[:])
+	switch {
+	case n != 0 || err == nil:
+		return errUnexpectedRead
+	case errors.Is(err, os.ErrDeadlineExceeded):
+		// Probably the other end doesn't support the barrier protocol.
+		logrus.Warn("Timeout after waiting 30s for barrier. Ignored.")
+		return nil
+	case errors.Is(err, io.EOF):
+		return nil
 	}
+	return err
 }
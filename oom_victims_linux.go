@@ -0,0 +1,185 @@
+package main
+
+import (
+	"errors"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/opencontainers/runc/libcontainer"
+	"github.com/opencontainers/runc/libcontainer/cgroups"
+	"github.com/opencontainers/runc/libcontainer/cgroups/fs2"
+	"github.com/opencontainers/runc/types"
+	"github.com/sirupsen/logrus"
+	"golang.org/x/sys/unix"
+)
+
+// initCgroupNsIno is the inode number of the initial cgroup namespace
+// (PROC_CGROUP_INIT_INO).
+const initCgroupNsIno = 0xEFFFFFFB
+
+// oomVictims finds out the processes killed by the OOM killer in the
+// container cgroup, from the reports in the kernel log.
+type oomVictims struct {
+	fd    int
+	memcg string
+	// cgroupns is true if runc runs in a non-initial cgroup namespace.
+	cgroupns bool
+}
+
+// newOOMVictims starts reading the kernel log. If it can't be read (which
+// requires CAP_SYSLOG or kernel.dmesg_restrict=0), the victims are unknown.
+func newOOMVictims(container libcontainer.Container) *oomVictims {
+	o := &oomVictims{fd: -1}
+	memcg, err := memoryCgroup(container)
+	if err != nil {
+		logrus.Debugf("unable to get memory cgroup: %v", err)
+		return o
+	}
+	fd, err := unix.Open("/dev/kmsg", unix.O_RDONLY|unix.O_NONBLOCK|unix.O_CLOEXEC, 0)
+	if err != nil {
+		logrus.Debugf("unable to open kernel log: %v", err)
+		return o
+	}
+	// Only the reports from now on are of interest.
+	if _, err := unix.Seek(fd, 0, unix.SEEK_END); err != nil {
+		unix.Close(fd)
+		logrus.Debugf("unable to seek kernel log: %v", err)
+		return o
+	}
+	o.fd, o.memcg = fd, memcg
+	var st unix.Stat_t
+	if err := unix.Stat("/proc/self/ns/cgroup", &st); err == nil {
+		o.cgroupns = st.Ino != initCgroupNsIno
+	}
+	return o
+}
+
+// oomReportTimeout is how long to wait for the OOM killer report. With
+// cgroup v1, the OOM notification comes before the victim is chosen.
+const oomReportTimeout = time.Second
+
+// next returns the next OOM kill in the container cgroup, with an unknown
+// victim if there's no such report in the kernel log.
+func (o *oomVictims) next() *types.OOMKill {
+	if o.fd == -1 {
+		return &types.OOMKill{}
+	}
+	deadline := time.Now().Add(oomReportTimeout)
+	buf := make([]byte, 8192)
+	for {
+		n, err := unix.Read(o.fd, buf)
+		if errors.Is(err, unix.EPIPE) || errors.Is(err, unix.EINTR) {
+			// EPIPE means some records were overwritten.
+			continue
+		}
+		if errors.Is(err, unix.EAGAIN) {
+			timeout := time.Until(deadline)
+			if timeout <= 0 {
+				return &types.OOMKill{}
+			}
+			fds := []unix.PollFd{{Fd: int32(o.fd), Events: unix.POLLIN}}
+			if _, err := unix.Poll(fds, int(timeout.Milliseconds())+1); err != nil && !errors.Is(err, unix.EINTR) {
+				logrus.Debugf("unable to poll kernel log: %v", err)
+				return &types.OOMKill{}
+			}
+			continue
+		}
+		if err != nil {
+			logrus.Debugf("unable to read kernel log: %v", err)
+			return &types.OOMKill{}
+		}
+		if ev := parseOOMKillRecord(string(buf[:n]), o.memcg, o.cgroupns); ev != nil {
+			return ev
+		}
+	}
+}
+
+func (o *oomVictims) Close() {
+	if o.fd != -1 {
+		unix.Close(o.fd)
+		o.fd = -1
+	}
+}
+
+// parseOOMKillRecord parses a kernel log record such as
+//
+//	3,1234,5678,-;oom-kill:constraint=CONSTRAINT_MEMCG,...,task_memcg=/foo,task=sh,pid=42,uid=0
+//
+// and returns its victim, if it was killed in memcg (or a descendant).
+//
+// The kernel reports the cgroup paths relative to the hierarchy root even
+// in a cgroup namespace, so with cgroupns memcg only needs to match the
+// end of the reported path.
+func parseOOMKillRecord(record, memcg string, cgroupns bool) *types.OOMKill {
+	i := strings.IndexByte(record, ';')
+	if i == -1 {
+		return nil
+	}
+	msg := strings.TrimSuffix(record[i+1:], "\n")
+	// The record may be followed by its continuation lines.
+	msg = strings.SplitN(msg, "\n", 2)[0]
+	if !strings.HasPrefix(msg, "oom-kill:") {
+		return nil
+	}
+	fields := make(map[string]string)
+	for _, f := range strings.Split(strings.TrimPrefix(msg, "oom-kill:"), ",") {
+		kv := strings.SplitN(f, "=", 2)
+		if len(kv) == 2 {
+			fields[kv[0]] = kv[1]
+		}
+	}
+	if !inCgroup(fields["task_memcg"], memcg, cgroupns) {
+		return nil
+	}
+	pid, err := strconv.Atoi(fields["pid"])
+	if err != nil {
+		return nil
+	}
+	return &types.OOMKill{Pid: pid, Comm: fields["task"]}
+}
+
+// inCgroup checks whether the cgroup path is cg or its descendant. With
+// cgroupns, cg is relative to an unknown cgroup namespace root, so a path
+// ending with cg matches too.
+func inCgroup(path, cg string, cgroupns bool) bool {
+	// Make sure cg starts with a "/", so that a suffix match is on a path
+	// component boundary ("/a/b" must not match "/xa/b").
+	cg = filepath.Join("/", cg)
+	if cg == "/" {
+		return true
+	}
+	for cur := filepath.Clean(path); cur != "/" && cur != "."; cur = filepath.Dir(cur) {
+		if cur == cg || (cgroupns && strings.HasSuffix(cur, cg)) {
+			return true
+		}
+	}
+	return false
+}
+
+// memoryCgroup returns the container memory cgroup path, relative to the
+// hierarchy root, as reported by the kernel.
+func memoryCgroup(container libcontainer.Container) (string, error) {
+	state, err := container.State()
+	if err != nil {
+		return "", err
+	}
+	var path, root string
+	if cgroups.IsCgroup2UnifiedMode() {
+		path, root = state.CgroupPaths[""], fs2.UnifiedMountpoint
+	} else {
+		path = state.CgroupPaths["memory"]
+		if root, err = cgroups.FindCgroupMountpoint("", "memory"); err != nil {
+			return "", err
+		}
+	}
+	if path == "" {
+		return "", errors.New("no memory cgroup")
+	}
+	rel, err := filepath.Rel(root, path)
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join("/", rel), nil
+}
@@ -19,7 +19,7 @@ Use runc list to identify instances of containers and their current status.`,
 		if err := checkArgs(context, 1, exactArgs); err != nil {
 			return err
 		}
-		rootlessCg, err := shouldUseRootlessCgroupManager(context)
+		rootlessCg, err := shouldUseRootlessCgroupManager(context, nil)
 		if err != nil {
 			return err
 		}
@@ -48,7 +48,7 @@ Use runc list to identify instances of containers and their current status.`,
 		if err := checkArgs(context, 1, exactArgs); err != nil {
 			return err
 		}
-		rootlessCg, err := shouldUseRootlessCgroupManager(context)
+		rootlessCg, err := shouldUseRootlessCgroupManager(context, nil)
 		if err != nil {
 			return err
 		}
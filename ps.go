@@ -1,6 +1,8 @@
 package main
 
 import (
+	"bufio"
+	"bytes"
 	"encoding/json"
 	"errors"
 	"fmt"
@@ -8,11 +10,35 @@ import (
 	"os/exec"
 	"strconv"
 	"strings"
+	"time"
 
+	"github.com/opencontainers/runc/libcontainer/cgroups"
+	"github.com/opencontainers/runc/libcontainer/system"
 	"github.com/sirupsen/logrus"
 	"github.com/urfave/cli"
 )
 
+// psProcess represents a container process, as shown by
+// "runc ps --format json".
+type psProcess struct {
+	// Pid is the process id in the host PID namespace.
+	Pid int `json:"pid"`
+	// PPid is the parent process id in the host PID namespace.
+	PPid int `json:"ppid"`
+	// StartTime is the time the process was started at.
+	StartTime time.Time `json:"startTime"`
+	// Command is the process name, as in /proc/[pid]/comm.
+	Command string `json:"command"`
+	// Args is the process command line, empty for zombies.
+	Args []string `json:"args,omitempty"`
+	// Cgroup is the path of the process cgroup in the cgroup v2 hierarchy,
+	// relative to the runc cgroup namespace.
+	Cgroup string `json:"cgroup,omitempty"`
+	// Cgroups are the paths of the process cgroups in the cgroup v1
+	// hierarchies, by controller, relative to the runc cgroup namespace.
+	Cgroups map[string]string `json:"cgroups,omitempty"`
+}
+
 var psCommand = cli.Command{
 	Name:      "ps",
 	Usage:     "ps displays the processes running inside a container",
@@ -28,7 +54,7 @@ var psCommand = cli.Command{
 		if err := checkArgs(context, 1, minArgs); err != nil {
 			return err
 		}
-		rootlessCg, err := shouldUseRootlessCgroupManager(context)
+		rootlessCg, err := shouldUseRootlessCgroupManager(context, nil)
 		if err != nil {
 			return err
 		}
@@ -49,7 +75,11 @@ var psCommand = cli.Command{
 		switch context.String("format") {
 		case "table":
 		case "json":
-			return json.NewEncoder(os.Stdout).Encode(pids)
+			procs, err := getProcesses(pids)
+			if err != nil {
+				return err
+			}
+			return json.NewEncoder(os.Stdout).Encode(procs)
 		default:
 			return errors.New("invalid format option")
 		}
@@ -110,3 +140,80 @@ func getPidIndex(title string) (int, error) {
 
 	return pidIndex, errors.New("couldn't find PID field in ps output")
 }
+
+// getProcesses returns the details of the processes with the given pids,
+// skipping the ones that have exited in the meantime.
+func getProcesses(pids []int) ([]psProcess, error) {
+	bootTime, err := getBootTime()
+	if err != nil {
+		return nil, err
+	}
+	clockTicks := system.ClockTicks()
+	procs := []psProcess{}
+	for _, pid := range pids {
+		p, err := getProcessInfo(pid, bootTime, clockTicks)
+		if err != nil {
+			if errors.Is(err, os.ErrNotExist) {
+				continue
+			}
+			return nil, err
+		}
+		procs = append(procs, *p)
+	}
+	return procs, nil
+}
+
+func getProcessInfo(pid int, bootTime time.Time, clockTicks uint64) (*psProcess, error) {
+	stat, err := system.Stat(pid)
+	if err != nil {
+		return nil, err
+	}
+	dir := "/proc/" + strconv.Itoa(pid)
+	cmdline, err := os.ReadFile(dir + "/cmdline")
+	if err != nil {
+		return nil, err
+	}
+	cgs, err := cgroups.ParseCgroupFile(dir + "/cgroup")
+	if err != nil {
+		return nil, err
+	}
+	p := &psProcess{
+		Pid:       pid,
+		PPid:      stat.PPid,
+		StartTime: bootTime.Add(time.Duration(stat.StartTime) * time.Second / time.Duration(clockTicks)),
+		Command:   stat.Name,
+		Cgroup:    cgs[""],
+	}
+	if cmdline = bytes.TrimSuffix(cmdline, []byte{0}); len(cmdline) > 0 {
+		p.Args = strings.Split(string(cmdline), "\x00")
+	}
+	delete(cgs, "")
+	if len(cgs) > 0 {
+		p.Cgroups = cgs
+	}
+	return p, nil
+}
+
+// getBootTime returns the system boot time, as reported by /proc/stat.
+func getBootTime() (time.Time, error) {
+	f, err := os.Open("/proc/stat")
+	if err != nil {
+		return time.Time{}, err
+	}
+	defer f.Close()
+
+	s := bufio.NewScanner(f)
+	for s.Scan() {
+		if v := strings.TrimPrefix(s.Text(), "btime "); len(v) < len(s.Text()) {
+			btime, err := strconv.ParseInt(v, 10, 64)
+			if err != nil {
+				return time.Time{}, fmt.Errorf("invalid btime in /proc/stat: %w", err)
+			}
+			return time.Unix(btime, 0), nil
+		}
+	}
+	if err := s.Err(); err != nil {
+		return time.Time{}, err
+	}
+	return time.Time{}, errors.New("no btime in /proc/stat")
+}
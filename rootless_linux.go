@@ -5,11 +5,15 @@ import (
 
 	"github.com/opencontainers/runc/libcontainer/cgroups/systemd"
 	"github.com/opencontainers/runc/libcontainer/userns"
+	"github.com/opencontainers/runtime-spec/specs-go"
 	"github.com/sirupsen/logrus"
 	"github.com/urfave/cli"
 )
 
-func shouldUseRootlessCgroupManager(context *cli.Context) (bool, error) {
+// shouldUseRootlessCgroupManager decides whether the rootless cgroup manager
+// is to be used. The spec is only needed to choose the cgroup driver when
+// creating a container, and is nil otherwise.
+func shouldUseRootlessCgroupManager(context *cli.Context, spec *specs.Spec) (bool, error) {
 	if context != nil {
 		b, err := parseBoolOrAuto(context.GlobalString("rootless"))
 		if err != nil {
@@ -36,7 +40,7 @@ func shouldUseRootlessCgroupManager(context *cli.Context) (bool, error) {
 	//
 	// On error, we assume we are root. An error may happen during shelling out to `busctl` CLI,
 	// mostly when $DBUS_SESSION_BUS_ADDRESS is unset.
-	if context.GlobalBool("systemd-cgroup") {
+	if sd, _ := useSystemdCgroup(context, spec); sd {
 		ownerUID, err := systemd.DetectUID()
 		if err != nil {
 			logrus.WithError(err).Debug("failed to get the OwnerUID value, assuming the value to be 0")
@@ -51,6 +55,19 @@ func shouldUseRootlessCgroupManager(context *cli.Context) (bool, error) {
 	return true, nil
 }
 
+// isRootless returns the --rootless value or, if it is auto, whether runc
+// is run by a non-root user.
+func isRootless(context *cli.Context) (bool, error) {
+	b, err := parseBoolOrAuto(context.GlobalString("rootless"))
+	if err != nil {
+		return false, err
+	}
+	if b != nil {
+		return *b, nil
+	}
+	return os.Geteuid() != 0, nil
+}
+
 func shouldHonorXDGRuntimeDir() bool {
 	if os.Geteuid() != 0 {
 		return true
@@ -48,6 +48,10 @@ command(s) that get executed on start, edit the args parameter of the spec. See
 			Value: "",
 			Usage: "specify the file to write the process id to",
 		},
+		cli.StringFlag{
+			Name:  "pidfd-socket",
+			Usage: "path to an AF_UNIX socket which will receive a file descriptor referencing the container process (a pidfd)",
+		},
 		cli.BoolFlag{
 			Name:  "no-subreaper",
 			Usage: "disable the use of the subreaper used to reap reparented processes",
@@ -41,6 +41,40 @@ function teardown() {
 	testcontainer test_busybox running
 }
 
+@test "runc create --start-pipe-socket" {
+	# recvtty accepts (and ignores) any fd sent to it, not only terminals.
+	runc create --console-socket "$CONSOLE_SOCKET" --start-pipe-socket "$CONSOLE_SOCKET" test_busybox
+	[ "$status" -eq 0 ]
+
+	testcontainer test_busybox created
+	[ ! -e "$ROOT/state/test_busybox/exec.fifo" ]
+
+	runc start test_busybox
+	[ "$status" -eq 0 ]
+
+	testcontainer test_busybox running
+}
+
+@test "runc create --start-pipe-socket ({u,g}id != 0)" {
+	# cannot start containers as another user in rootless setup without idmap
+	[[ "$ROOTLESS" -ne 0 ]] && requires rootless_idmap
+
+	# The container init reopens the start pipe after switching to the
+	# container user.
+	update_config ' (.. | select(.uid? == 0)) .uid |= 1000
+		| (.. | select(.gid? == 0)) .gid |= 100'
+
+	runc create --console-socket "$CONSOLE_SOCKET" --start-pipe-socket "$CONSOLE_SOCKET" test_busybox
+	[ "$status" -eq 0 ]
+
+	testcontainer test_busybox created
+
+	runc start test_busybox
+	[ "$status" -eq 0 ]
+
+	testcontainer test_busybox running
+}
+
 @test "runc create --pid-file" {
 	runc create --pid-file pid.txt --console-socket "$CONSOLE_SOCKET" test_busybox
 	[ "$status" -eq 0 ]
@@ -45,6 +45,27 @@ function teardown() {
 	[ "$status" -ne 0 ]
 }
 
+@test "runc delete --force [forking workload, host pidns]" {
+	requires root
+	init_cgroup_paths
+
+	# Without a PID namespace, killing the init does not kill the rest.
+	update_config '	  .linux.namespaces -= [{"type": "pid"}]
+			| .process.args |= ["sh", "-c", "while :; do sleep 10 & sleep 0.01; done"]'
+	runc run -d --console-socket "$CONSOLE_SOCKET" test_busybox
+	[ "$status" -eq 0 ]
+	testcontainer test_busybox running
+
+	runc delete --force test_busybox
+	[ "$status" -eq 0 ]
+
+	runc state test_busybox
+	[ "$status" -ne 0 ]
+
+	# check the cgroup was removed
+	[ ! -d "$CGROUP_PATH" ]
+}
+
 @test "runc delete --force ignore not exist" {
 	runc delete --force notexists
 	[ "$status" -eq 0 ]
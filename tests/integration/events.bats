@@ -99,4 +99,62 @@ function test_events() {
 	wait # wait for the above sub shells to finish
 
 	grep -q '{"type":"oom","id":"test_busybox"}' events.log
+	grep -q '{"type":"oom-kill","id":"test_busybox"' events.log
+}
+
+@test "events frozen" {
+	requires root cgroups_v2
+	init_cgroup_paths
+
+	runc run -d --console-socket "$CONSOLE_SOCKET" test_busybox
+	[ "$status" -eq 0 ]
+
+	(__runc events --interval 1h test_busybox >events.log) &
+	(
+		sleep 1
+		__runc pause test_busybox
+		__runc resume test_busybox
+		retry 10 1 grep -q thawed events.log
+		__runc delete -f test_busybox
+	) &
+	wait # wait for the above sub shells to finish
+
+	grep -q '{"type":"frozen","id":"test_busybox"}' events.log
+	grep -q '{"type":"thawed","id":"test_busybox"}' events.log
+}
+
+@test "events --pressure" {
+	requires root cgroups_v2 psi
+	init_cgroup_paths
+
+	runc run -d --console-socket "$CONSOLE_SOCKET" test_busybox
+	[ "$status" -eq 0 ]
+
+	runc events --pressure memory:full:1ms test_busybox
+	[ "$status" -ne 0 ]
+	[[ "$output" == *"invalid PSI trigger"* ]]
+
+	runc events --pressure foo:some:10ms:1s test_busybox
+	[ "$status" -ne 0 ]
+	[[ "$output" == *"invalid PSI resource"* ]]
+}
+
+@test "events unit-stopped" {
+	requires root systemd
+	set_cgroups_path
+	# Container init ignores SIGTERM, so systemd kills it once the stop
+	# timeout expires, while the unit is still being stopped.
+	update_config '.annotations += {"org.systemd.property.TimeoutStopUSec": "uint64 2000000"}'
+
+	runc run -d --console-socket "$CONSOLE_SOCKET" test_busybox
+	[ "$status" -eq 0 ]
+
+	(__runc events --interval 1h test_busybox >events.log) &
+	(
+		sleep 1
+		systemctl stop "$SD_UNIT_NAME"
+	) &
+	wait # wait for the above sub shells to finish
+
+	grep -q '{"type":"unit-stopped","id":"test_busybox"}' events.log
 }
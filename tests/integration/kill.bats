@@ -29,3 +29,26 @@ function teardown() {
 	runc delete test_busybox
 	[ "$status" -eq 0 ]
 }
+
+@test "kill KILL [host pidns]" {
+	requires root
+	init_cgroup_paths
+
+	update_config '	  .linux.namespaces -= [{"type": "pid"}]
+			| .process.args |= ["sh", "-c", "sleep 1h & sleep 1h"]'
+	runc run -d --console-socket "$CONSOLE_SOCKET" test_busybox
+	[ "$status" -eq 0 ]
+	testcontainer test_busybox running
+
+	# Without --all, all the processes must be killed, not just the init.
+	runc kill test_busybox KILL
+	[ "$status" -eq 0 ]
+	wait_for_container 10 1 test_busybox stopped
+
+	local procs
+	procs=$(cat "$CGROUP_PATH"/cgroup.procs 2>/dev/null || true)
+	[ -z "$procs" ]
+
+	runc delete test_busybox
+	[ "$status" -eq 0 ]
+}
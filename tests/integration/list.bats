@@ -53,3 +53,43 @@ function teardown() {
 	[[ "${lines[0]}" == *[,][\{]"\"ociVersion\""[:]"\""*[0-9][\.]*[0-9][\.]*[0-9]*"\""[,]"\"id\""[:]"\"test_box2\""[,]"\"pid\""[:]*[0-9][,]"\"status\""[:]*"\"running\""[,]"\"bundle\""[:]*$bundle*[,]"\"rootfs\""[:]"\""*"\""[,]"\"created\""[:]*[0-9]*[\}]* ]]
 	[[ "${lines[0]}" == *[,][\{]"\"ociVersion\""[:]"\""*[0-9][\.]*[0-9][\.]*[0-9]*"\""[,]"\"id\""[:]"\"test_box3\""[,]"\"pid\""[:]*[0-9][,]"\"status\""[:]*"\"running\""[,]"\"bundle\""[:]*$bundle*[,]"\"rootfs\""[:]"\""*"\""[,]"\"created\""[:]*[0-9]*[\}][\]] ]]
 }
+
+@test "list --filter and --format template" {
+	ROOT=$ALT_ROOT runc list --format json
+	[ "$status" -eq 0 ]
+	[ "$output" = "[]" ]
+
+	update_config '.annotations += {"tier": "frontend"}'
+	ROOT=$ALT_ROOT runc run -d --console-socket "$CONSOLE_SOCKET" test_box1
+	[ "$status" -eq 0 ]
+
+	update_config '.annotations.tier = "backend"'
+	ROOT=$ALT_ROOT runc run -d --console-socket "$CONSOLE_SOCKET" test_box2
+	[ "$status" -eq 0 ]
+	ROOT=$ALT_ROOT runc pause test_box2
+	[ "$status" -eq 0 ]
+
+	ROOT=$ALT_ROOT runc list --format '{{.ID}} {{.Status}}'
+	[ "$status" -eq 0 ]
+	[ "${lines[0]}" = "test_box1 running" ]
+	[ "${lines[1]}" = "test_box2 paused" ]
+
+	ROOT=$ALT_ROOT runc list --filter status=paused -q
+	[ "$status" -eq 0 ]
+	[ "$output" = "test_box2" ]
+
+	ROOT=$ALT_ROOT runc list --filter status=paused --filter status=running -q
+	[ "$status" -eq 0 ]
+	[ "${#lines[@]}" -eq 2 ]
+
+	ROOT=$ALT_ROOT runc list --filter annotation=tier=frontend -q
+	[ "$status" -eq 0 ]
+	[ "$output" = "test_box1" ]
+
+	ROOT=$ALT_ROOT runc list --filter annotation=tier --filter status=running -q
+	[ "$status" -eq 0 ]
+	[ "$output" = "test_box1" ]
+
+	ROOT=$ALT_ROOT runc list --filter status=foo
+	[ "$status" -ne 0 ]
+}
@@ -23,6 +23,40 @@ function teardown() {
 	[[ "${lines[0]}" == *'/tmp/bind/config.json'* ]]
 }
 
+@test "runc run [ro bind mount]" {
+	update_config '	  .mounts += [{
+					source: ".",
+					destination: "/tmp/bind",
+					options: ["bind", "ro"]
+				}]
+			| .process.args |= ["touch", "/tmp/bind/foo"]'
+
+	runc run test_busybox
+	[ "$status" -ne 0 ]
+	[[ "$output" == *"Read-only file system"* ]]
+}
+
+@test "runc run [bind mount with source under an earlier mount]" {
+	# The source is under the tmpfs mounted first, so the tmpfs is
+	# what should be bind mounted, not the rootfs directory beneath it.
+	mkdir -p rootfs/opt rootfs/srv
+	touch rootfs/opt/hidden
+	update_config '	  .mounts += [{
+					source: "tmpfs",
+					destination: "/opt",
+					type: "tmpfs"
+				}, {
+					source: "rootfs/opt",
+					destination: "/srv",
+					options: ["bind"]
+				}]
+			| .process.args |= ["ls", "-A", "/srv"]'
+
+	runc run test_busybox
+	[ "$status" -eq 0 ]
+	[ "$output" = "" ]
+}
+
 # https://github.com/opencontainers/runc/issues/2246
 @test "runc run [ro tmpfs mount]" {
 	update_config '	  .mounts += [{
@@ -30,6 +30,7 @@ function teardown() {
 @test "ps -f json" {
 	# ps is not supported, it requires cgroups
 	requires root
+	init_cgroup_paths
 
 	# start busybox detached
 	runc run -d --console-socket "$CONSOLE_SOCKET" test_busybox
@@ -38,9 +39,20 @@ function teardown() {
 	# check state
 	testcontainer test_busybox running
 
+	local pid
+	pid=$(__runc state test_busybox | jq '.pid')
+
 	runc ps -f json test_busybox
 	[ "$status" -eq 0 ]
-	[[ ${lines[0]} =~ [0-9]+ ]]
+	[ "$(echo "$output" | jq '.[0].pid')" -eq "$pid" ]
+	[ "$(echo "$output" | jq -r '.[0].command')" = "sh" ]
+	[ "$(echo "$output" | jq -r '.[0].args[0]')" = "sh" ]
+	[[ "$(echo "$output" | jq -r '.[0].startTime')" =~ ^[0-9]{4}-[0-9]{2}-[0-9]{2}T ]]
+	if [ -v CGROUP_V2 ]; then
+		[ "$(echo "$output" | jq -r '.[0].cgroup')" = "$REL_CGROUPS_PATH" ]
+	else
+		[ "$(echo "$output" | jq -r '.[0].cgroups.pids')" = "$REL_CGROUPS_PATH" ]
+	fi
 }
 
 @test "ps -e -x" {
@@ -79,6 +79,12 @@ function setup() {
 		check_cgroup_value "cpuset.cpus" 1
 	fi
 
+	# a failed update must not change the cpuset
+	cpus=$(get_cgroup_value "cpuset.cpus")
+	runc update test_update --cpuset-cpus "$cpu_count"
+	[ "$status" -ne 0 ]
+	check_cgroup_value "cpuset.cpus" "$cpus"
+
 	# update memory limit
 	runc update test_update --memory 67108864
 	[ "$status" -eq 0 ]
@@ -456,6 +462,23 @@ EOF
 	check_systemd_value "TasksMax" 10
 }
 
+@test "update cgroup v2 resources via --unified" {
+	[[ "$ROOTLESS" -ne 0 ]] && requires rootless_cgroup
+	requires cgroups_v2
+
+	runc run -d --console-socket "$CONSOLE_SOCKET" test_update
+	[ "$status" -eq 0 ]
+
+	runc update --unified cpu.weight=16 --unified pids.max=10 test_update
+	[ "$status" -eq 0 ]
+	check_cpu_weight 16
+	check_systemd_value "TasksMax" 10
+
+	runc update --unified pids.max test_update
+	[ "$status" -ne 0 ]
+	[[ "$output" == *"expected KEY=VALUE"* ]]
+}
+
 @test "update cpuset parameters via resources.CPU" {
 	[[ "$ROOTLESS" -ne 0 ]] && requires rootless_cgroup
 	requires smp cgroups_cpuset
@@ -704,3 +727,34 @@ EOF
 	runc resume test_update
 	[ "$status" -eq 0 ]
 }
+
+@test "update rlimits" {
+	runc run -d --console-socket "$CONSOLE_SOCKET" test_update
+	[ "$status" -eq 0 ]
+
+	runc update --rlimit RLIMIT_NOFILE=512:1024 test_update
+	[ "$status" -eq 0 ]
+
+	runc exec test_update sh -c 'grep "open files" /proc/1/limits'
+	[ "$status" -eq 0 ]
+	[[ "$output" == *" 512 "*" 1024 "* ]]
+
+	# The new limits also apply to processes started afterwards.
+	runc exec test_update sh -c 'ulimit -n'
+	[ "$status" -eq 0 ]
+	[[ "$output" == "512" ]]
+
+	runc update --rlimit RLIMIT_NOFILE=2048:1024 test_update
+	[ "$status" -ne 0 ]
+}
+
+@test "update --memory-reclaim [invalid value]" {
+	runc run -d --console-socket "$CONSOLE_SOCKET" test_update
+	[ "$status" -eq 0 ]
+
+	for val in -1 foo 99999999999999999999; do
+		runc update --memory-reclaim="$val" test_update
+		[ "$status" -ne 0 ]
+		[[ "$output" == *"invalid value for memory-reclaim"* ]]
+	done
+}
@@ -64,3 +64,50 @@ function teardown() {
 	runc exec test_busybox stat /tmp/mount-1/foo.txt /tmp/mount-2/foo.txt
 	[ "$status" -eq 0 ]
 }
+
+@test "userns with id-mapped mount" {
+	requires root
+	requires_kernel 5.12
+
+	update_config ' .process.args += ["-c", "stat -c %u:%g /tmp/mount-1/foo.txt && touch /tmp/mount-1/bar.txt"]
+		| .mounts += [{"source": "source-accessible/dir", "destination": "/tmp/mount-1", "options": ["bind", "idmap"]}] '
+
+	# Without idmap, foo.txt (owned by the host root) would be shown as
+	# owned by the overflow uid inside the container.
+	runc run test_busybox
+	[ "$status" -eq 0 ]
+	[[ "$output" == *"0:0"* ]]
+
+	# A file created by the container root must be owned by the host root.
+	[ "$(stat -c %u:%g source-accessible/dir/bar.txt)" = "0:0" ]
+}
+
+@test "userns with id-mapped and regular mounts" {
+	requires root
+	requires_kernel 5.12
+
+	update_config ' .process.args += ["-c", "stat -c %u:%g /tmp/mount-1/foo.txt /tmp/mount-2/foo.txt"]
+		| .mounts += [	{ "source": "source-accessible/dir", "destination": "/tmp/mount-1", "options": ["bind", "idmap"] },
+				{ "source": "source-inaccessible-1/dir", "destination": "/tmp/mount-2", "options": ["bind"] }
+			     ]'
+
+	# Only the id-mapped mount is cloned by runc, the other one must be
+	# bind mounted as usual.
+	runc run test_busybox
+	[ "$status" -eq 0 ]
+	[[ "${lines[0]}" == "0:0"* ]]
+	[[ "${lines[1]}" != "0:0"* ]]
+}
+
+@test "userns with read-only id-mapped mount" {
+	requires root
+	requires_kernel 5.12
+
+	update_config ' .process.args += ["-c", "stat -c %u:%g /tmp/mount-1/foo.txt && ! touch /tmp/mount-1/bar.txt"]
+		| .mounts += [{"source": "source-accessible/dir", "destination": "/tmp/mount-1", "options": ["bind", "ro", "idmap"]}] '
+
+	runc run test_busybox
+	[ "$status" -eq 0 ]
+	[[ "$output" == *"0:0"* ]]
+	[[ "$output" == *"Read-only file system"* ]]
+}
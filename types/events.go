@@ -9,6 +9,29 @@ type Event struct {
 	Data interface{} `json:"data,omitempty"`
 }
 
+// MemoryPressure is the data of a "memory_pressure" event.
+type MemoryPressure struct {
+	Level string `json:"level"`
+}
+
+// OOMKill is the data of an "oom-kill" event.
+type OOMKill struct {
+	// Pid and Comm identify the killed process, if it is known (this
+	// requires the kernel log to be readable).
+	Pid  int    `json:"pid,omitempty"`
+	Comm string `json:"comm,omitempty"`
+}
+
+// Pressure is the data of a "pressure" event, sent when a PSI trigger fires.
+type Pressure struct {
+	// Resource is one of "cpu", "memory", or "io".
+	Resource string `json:"resource"`
+	// Kind is either "some" or "full".
+	Kind      string `json:"kind"`
+	Threshold string `json:"threshold"`
+	Window    string `json:"window"`
+}
+
 // stats is the runc specific stats structure for stability when encoding and decoding stats.
 type Stats struct {
 	CPU               Cpu                 `json:"cpu"`
@@ -17,6 +40,9 @@ type Stats struct {
 	Pids              Pids                `json:"pids"`
 	Blkio             Blkio               `json:"blkio"`
 	Hugetlb           map[string]Hugetlb  `json:"hugetlb"`
+	Rdma              Rdma                `json:"rdma"`
+	Misc              map[string]Misc     `json:"misc,omitempty"`
+	Cgroup            Cgroup              `json:"cgroup"`
 	IntelRdt          IntelRdt            `json:"intel_rdt"`
 	NetworkInterfaces []*NetworkInterface `json:"network_interfaces"`
 }
@@ -27,6 +53,23 @@ type Hugetlb struct {
 	Failcnt uint64 `json:"failcnt"`
 }
 
+type Misc struct {
+	Usage  uint64 `json:"usage,omitempty"`
+	Limit  uint64 `json:"limit,omitempty"`
+	Events uint64 `json:"events,omitempty"`
+}
+
+type RdmaEntry struct {
+	Device     string `json:"device,omitempty"`
+	HcaHandles uint32 `json:"hca_handles,omitempty"`
+	HcaObjects uint32 `json:"hca_objects,omitempty"`
+}
+
+type Rdma struct {
+	Limit   []RdmaEntry `json:"limit,omitempty"`
+	Current []RdmaEntry `json:"current,omitempty"`
+}
+
 type BlkioEntry struct {
 	Major uint64 `json:"major,omitempty"`
 	Minor uint64 `json:"minor,omitempty"`
@@ -43,17 +86,29 @@ type Blkio struct {
 	IoMergedRecursive       []BlkioEntry `json:"ioMergedRecursive,omitempty"`
 	IoTimeRecursive         []BlkioEntry `json:"ioTimeRecursive,omitempty"`
 	SectorsRecursive        []BlkioEntry `json:"sectorsRecursive,omitempty"`
+	ThrottleIoServiceBytes  []BlkioEntry `json:"throttleIoServiceBytes,omitempty"`
+	ThrottleIoServiced      []BlkioEntry `json:"throttleIoServiced,omitempty"`
+	PSI                     *PSIStats    `json:"psi,omitempty"`
 }
 
 type Pids struct {
 	Current uint64 `json:"current,omitempty"`
 	Limit   uint64 `json:"limit,omitempty"`
+	Failcnt uint64 `json:"failcnt,omitempty"`
+	Peak    uint64 `json:"peak,omitempty"`
+}
+
+type Cgroup struct {
+	NrDescendants      uint64 `json:"nr_descendants,omitempty"`
+	NrDyingDescendants uint64 `json:"nr_dying_descendants,omitempty"`
 }
 
 type Throttling struct {
 	Periods          uint64 `json:"periods,omitempty"`
 	ThrottledPeriods uint64 `json:"throttledPeriods,omitempty"`
 	ThrottledTime    uint64 `json:"throttledTime,omitempty"`
+	BurstPeriods     uint64 `json:"burstPeriods,omitempty"`
+	BurstTime        uint64 `json:"burstTime,omitempty"`
 }
 
 type CpuUsage struct {
@@ -66,9 +121,22 @@ type CpuUsage struct {
 	User         uint64   `json:"user"`
 }
 
+type PSIData struct {
+	Avg10  float64 `json:"avg10"`
+	Avg60  float64 `json:"avg60"`
+	Avg300 float64 `json:"avg300"`
+	Total  uint64  `json:"total"`
+}
+
+type PSIStats struct {
+	Some PSIData `json:"some,omitempty"`
+	Full PSIData `json:"full,omitempty"`
+}
+
 type Cpu struct {
 	Usage      CpuUsage   `json:"usage,omitempty"`
 	Throttling Throttling `json:"throttling,omitempty"`
+	PSI        *PSIStats  `json:"psi,omitempty"`
 }
 
 type CPUSet struct {
@@ -99,6 +167,8 @@ type Memory struct {
 	Kernel    MemoryEntry       `json:"kernel,omitempty"`
 	KernelTCP MemoryEntry       `json:"kernelTCP,omitempty"`
 	Raw       map[string]uint64 `json:"raw,omitempty"`
+	Events    map[string]uint64 `json:"events,omitempty"`
+	PSI       *PSIStats         `json:"psi,omitempty"`
 }
 
 type L3CacheInfo struct {
@@ -41,6 +41,42 @@ type Linux struct {
 	Seccomp  *Seccomp  `json:"seccomp,omitempty"`
 	Apparmor *Apparmor `json:"apparmor,omitempty"`
 	Selinux  *Selinux  `json:"selinux,omitempty"`
+
+	// Host represents the features detected on the host, as opposed to
+	// the ones compiled in.
+	Host *Host `json:"host,omitempty"`
+}
+
+// Host represents the "host" field.
+type Host struct {
+	// Cgroup is the cgroup mode of the host: "v1", "hybrid" or "v2".
+	// Empty value means "unknown".
+	Cgroup string `json:"cgroup,omitempty"`
+
+	// CgroupControllers is the list of the cgroup controllers available on the host, e.g., "memory".
+	// Nil value means "unknown", not "no controller".
+	CgroupControllers []string `json:"cgroupControllers,omitempty"`
+
+	// Systemd is true if the host is running systemd, so the systemd cgroup driver can be used.
+	// Nil value means "unknown", not "false".
+	Systemd *bool `json:"systemd,omitempty"`
+
+	// Apparmor is true if AppArmor is enabled on the host.
+	// Nil value means "unknown", not "false".
+	Apparmor *bool `json:"apparmor,omitempty"`
+
+	// Selinux is true if SELinux is enabled on the host.
+	// Nil value means "unknown", not "false".
+	Selinux *bool `json:"selinux,omitempty"`
+
+	// IDMappedMounts is true if the host kernel supports idmapped mounts (i.e. mount_setattr(2)).
+	// The filesystem of the mount source needs to support them too.
+	// Nil value means "unknown", not "false".
+	IDMappedMounts *bool `json:"idmappedMounts,omitempty"`
+
+	// CriuVersion is the version of CRIU installed on the host, e.g., "3.16.1".
+	// Empty value means CRIU was not found.
+	CriuVersion string `json:"criuVersion,omitempty"`
 }
 
 // Seccomp represents the "seccomp" field.
@@ -6,15 +6,18 @@ import (
 	"fmt"
 	"os"
 	"strconv"
+	"strings"
 
 	"github.com/opencontainers/runc/libcontainer/cgroups"
 	"github.com/sirupsen/logrus"
 
 	"github.com/docker/go-units"
 	"github.com/opencontainers/runc/libcontainer/configs"
+	"github.com/opencontainers/runc/libcontainer/devices"
 	"github.com/opencontainers/runc/libcontainer/intelrdt"
 	"github.com/opencontainers/runtime-spec/specs-go"
 	"github.com/urfave/cli"
+	"golang.org/x/sys/unix"
 )
 
 func i64Ptr(i int64) *int64   { return &i }
@@ -50,6 +53,9 @@ The accepted format is as follow (unchanged values can be omitted):
   },
   "blockIO": {
     "weight": 0
+  },
+  "unified": {
+    "memory.high": ""
   }
 }
 
@@ -112,10 +118,26 @@ other options are ignored.
 			Name:  "memory-swap",
 			Usage: "Total memory usage (memory + swap); set '-1' to enable unlimited swap",
 		},
+		cli.StringFlag{
+			Name:  "memory-reclaim",
+			Usage: "Amount of memory to proactively reclaim from the container (in bytes, cgroup v2 only)",
+		},
 		cli.IntFlag{
 			Name:  "pids-limit",
 			Usage: "Maximum number of pids allowed in the container",
 		},
+		cli.StringSliceFlag{
+			Name:  "unified",
+			Usage: "Set a cgroup v2 file of the container, in the KEY=VALUE format (e.g. memory.high=500M); can be specified multiple times",
+		},
+		cli.StringSliceFlag{
+			Name:  "rlimit",
+			Usage: "Set a resource limit of the container init, in the TYPE=SOFT[:HARD] format (e.g. RLIMIT_NOFILE=1024:4096, or RLIMIT_CORE=unlimited); can be specified multiple times",
+		},
+		cli.BoolFlag{
+			Name:  "rlimit-all",
+			Usage: "Set the resource limits (--rlimit) of all the container processes, not just the init",
+		},
 		cli.StringFlag{
 			Name:  "l3-cache-schema",
 			Usage: "The string of Intel RDT/CAT L3 cache schema",
@@ -160,6 +182,48 @@ other options are ignored.
 		}
 
 		config := container.Config()
+		// The config is a shallow copy, so copy the parts to be modified,
+		// as the container's own config is used to revert the cgroup
+		// settings in case the update fails.
+		cg := *config.Cgroups
+		res := *cg.Resources
+		if res.Unified != nil {
+			res.Unified = make(map[string]string, len(cg.Resources.Unified))
+			for k, v := range cg.Resources.Unified {
+				res.Unified[k] = v
+			}
+		}
+		res.Devices = make([]*devices.Rule, len(cg.Resources.Devices))
+		for i, d := range cg.Resources.Devices {
+			rule := *d
+			res.Devices[i] = &rule
+		}
+		cg.Resources = &res
+		config.Cgroups = &cg
+		if config.IntelRdt != nil {
+			rdt := *config.IntelRdt
+			config.IntelRdt = &rdt
+		}
+
+		var reclaim int64
+		if val := context.String("memory-reclaim"); val != "" {
+			reclaim, err = units.RAMInBytes(val)
+			if err != nil {
+				return fmt.Errorf("invalid value for memory-reclaim: %w", err)
+			}
+			if reclaim < 0 {
+				return fmt.Errorf("invalid value for memory-reclaim: %s", val)
+			}
+		}
+
+		var rlimits []configs.Rlimit
+		for _, val := range context.StringSlice("rlimit") {
+			rl, err := parseRlimit(val)
+			if err != nil {
+				return fmt.Errorf("invalid value for rlimit: %w", err)
+			}
+			rlimits = append(rlimits, rl)
+		}
 
 		if in := context.String("resources"); in != "" {
 			var (
@@ -247,6 +311,17 @@ other options are ignored.
 			}
 
 			r.Pids.Limit = int64(context.Int("pids-limit"))
+
+			for _, val := range context.StringSlice("unified") {
+				kv := strings.SplitN(val, "=", 2)
+				if len(kv) != 2 || kv[0] == "" {
+					return fmt.Errorf("invalid value for unified: %q: expected KEY=VALUE", val)
+				}
+				if r.Unified == nil {
+					r.Unified = make(map[string]string)
+				}
+				r.Unified[kv[0]] = kv[1]
+			}
 		}
 
 		if *r.Memory.Kernel != 0 || *r.Memory.KernelTCP != 0 {
@@ -290,10 +365,17 @@ other options are ignored.
 		config.Cgroups.Resources.CpuRtRuntime = *r.CPU.RealtimeRuntime
 		config.Cgroups.Resources.CpusetCpus = r.CPU.Cpus
 		config.Cgroups.Resources.CpusetMems = r.CPU.Mems
-		config.Cgroups.Resources.Memory = *r.Memory.Limit
+		// The swap limit is relative to the memory limit, so if only
+		// the former is updated, the current memory limit is used.
+		if *r.Memory.Limit != 0 || *r.Memory.Swap == 0 {
+			config.Cgroups.Resources.Memory = *r.Memory.Limit
+		}
 		config.Cgroups.Resources.MemoryReservation = *r.Memory.Reservation
 		config.Cgroups.Resources.MemorySwap = *r.Memory.Swap
 		config.Cgroups.Resources.PidsLimit = r.Pids.Limit
+		if len(r.Unified) > 0 && !cgroups.IsCgroup2UnifiedMode() {
+			return errors.New("unified resources are only supported on cgroup v2")
+		}
 		config.Cgroups.Resources.Unified = r.Unified
 
 		// Update Intel RDT
@@ -323,8 +405,14 @@ other options are ignored.
 					return err
 				}
 			}
-			config.IntelRdt.L3CacheSchema = l3CacheSchema
-			config.IntelRdt.MemBwSchema = memBwSchema
+			// Only the given schemas are written to the schemata file,
+			// the kernel keeps the others as they are.
+			if l3CacheSchema != "" {
+				config.IntelRdt.L3CacheSchema = l3CacheSchema
+			}
+			if memBwSchema != "" {
+				config.IntelRdt.MemBwSchema = memBwSchema
+			}
 		}
 
 		// XXX(kolyshkin@): currently "runc update" is unable to change
@@ -334,6 +422,54 @@ other options are ignored.
 		// Note this field is not saved into container's state.json.
 		config.Cgroups.SkipDevices = true
 
-		return container.Set(config)
+		if err := container.Set(config); err != nil {
+			return err
+		}
+
+		if len(rlimits) > 0 {
+			if err := container.SetRlimits(rlimits, context.Bool("rlimit-all")); err != nil {
+				return err
+			}
+		}
+
+		if reclaim > 0 {
+			return container.Reclaim(uint64(reclaim))
+		}
+		return nil
 	},
 }
+
+// parseRlimit parses a resource limit in the TYPE=SOFT[:HARD] format.
+// If HARD is omitted, it is the same as SOFT.
+func parseRlimit(val string) (configs.Rlimit, error) {
+	kv := strings.SplitN(val, "=", 2)
+	if len(kv) != 2 {
+		return configs.Rlimit{}, fmt.Errorf("%q: expected TYPE=SOFT[:HARD]", val)
+	}
+	rl, err := strToRlimit(kv[0])
+	if err != nil {
+		return configs.Rlimit{}, err
+	}
+	limits := strings.SplitN(kv[1], ":", 2)
+	soft, hard := limits[0], limits[0]
+	if len(limits) == 2 {
+		hard = limits[1]
+	}
+	parse := func(s string) (uint64, error) {
+		if s == "unlimited" {
+			return unix.RLIM_INFINITY, nil
+		}
+		return strconv.ParseUint(s, 10, 64)
+	}
+	r := configs.Rlimit{Type: rl}
+	if r.Soft, err = parse(soft); err != nil {
+		return configs.Rlimit{}, fmt.Errorf("%q: %w", val, err)
+	}
+	if r.Hard, err = parse(hard); err != nil {
+		return configs.Rlimit{}, fmt.Errorf("%q: %w", val, err)
+	}
+	if r.Soft > r.Hard {
+		return configs.Rlimit{}, fmt.Errorf("%q: soft limit is greater than hard limit", val)
+	}
+	return r, nil
+}
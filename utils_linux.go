@@ -7,6 +7,7 @@ import (
 	"os"
 	"path/filepath"
 	"strconv"
+	"strings"
 
 	"github.com/coreos/go-systemd/v22/activation"
 	"github.com/opencontainers/runtime-spec/specs-go"
@@ -16,6 +17,8 @@ import (
 	"golang.org/x/sys/unix"
 
 	"github.com/opencontainers/runc/libcontainer"
+	"github.com/opencontainers/runc/libcontainer/cgroups/manager"
+	"github.com/opencontainers/runc/libcontainer/cgroups/systemd"
 	"github.com/opencontainers/runc/libcontainer/configs"
 	"github.com/opencontainers/runc/libcontainer/specconv"
 	"github.com/opencontainers/runc/libcontainer/utils"
@@ -58,6 +61,7 @@ func newProcess(p specs.Process) (*libcontainer.Process, error) {
 		Label:           p.SelinuxLabel,
 		NoNewPrivileges: &p.NoNewPrivileges,
 		AppArmorProfile: p.ApparmorProfile,
+		Umask:           p.User.Umask,
 	}
 
 	if p.ConsoleSize != nil {
@@ -166,19 +170,92 @@ func createPidFile(path string, process *libcontainer.Process) error {
 	return os.Rename(tmpName, path)
 }
 
+// sendPidfd sends a pidfd of the process to the AF_UNIX socket at path.
+func sendPidfd(path string, process *libcontainer.Process) error {
+	pidfd, err := process.Pidfd()
+	if err != nil {
+		return err
+	}
+	defer pidfd.Close()
+	return sendFile(path, pidfd)
+}
+
+// sendFile sends the file descriptor of f to the AF_UNIX socket at path.
+func sendFile(path string, f *os.File) error {
+	conn, err := net.Dial("unix", path)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	uc, ok := conn.(*net.UnixConn)
+	if !ok {
+		return errors.New("casting to UnixConn failed")
+	}
+	socket, err := uc.File()
+	if err != nil {
+		return err
+	}
+	defer socket.Close()
+	return utils.SendFd(socket, f.Name(), f.Fd())
+}
+
+// useSystemdCgroup returns whether the systemd cgroup driver is to be used,
+// as set by --cgroup-driver or --systemd-cgroup.
+//
+// The auto driver only chooses systemd if the spec (unless nil) cgroupsPath
+// is either empty or in the "slice:prefix:name" form, as a cgroup path can't
+// be used with systemd.
+func useSystemdCgroup(context *cli.Context, spec *specs.Spec) (bool, error) {
+	sd := context.GlobalBool("systemd-cgroup")
+	if !context.GlobalIsSet("cgroup-driver") {
+		return sd, nil
+	}
+	driver, err := manager.ParseDriver(context.GlobalString("cgroup-driver"))
+	if err != nil {
+		return false, err
+	}
+	if driver == manager.DriverAuto {
+		rootless, err := isRootless(context)
+		if err != nil {
+			return false, err
+		}
+		driver = manager.DetectDriver(rootless)
+		if driver == manager.DriverSystemd && spec != nil && spec.Linux != nil &&
+			spec.Linux.CgroupsPath != "" && len(strings.Split(spec.Linux.CgroupsPath, ":")) != 3 {
+			logrus.Debugf("cgroupsPath %q is not in the slice:prefix:name form, using cgroupfs cgroup driver", spec.Linux.CgroupsPath)
+			driver = manager.DriverCgroupfs
+		}
+	}
+	if sd && driver != manager.DriverSystemd {
+		return false, errors.New("--systemd-cgroup conflicts with --cgroup-driver=" + context.GlobalString("cgroup-driver"))
+	}
+	return driver == manager.DriverSystemd, nil
+}
+
 func createContainer(context *cli.Context, id string, spec *specs.Spec) (libcontainer.Container, error) {
-	rootlessCg, err := shouldUseRootlessCgroupManager(context)
+	systemdCgroup, err := useSystemdCgroup(context, spec)
+	if err != nil {
+		return nil, err
+	}
+	rootlessCg, err := shouldUseRootlessCgroupManager(context, spec)
 	if err != nil {
 		return nil, err
 	}
+	slice := context.GlobalString("systemd-default-slice")
+	if slice != "" {
+		if _, err := systemd.ExpandSlice(slice); err != nil {
+			return nil, fmt.Errorf("invalid --systemd-default-slice: %w", err)
+		}
+	}
 	config, err := specconv.CreateLibcontainerConfig(&specconv.CreateOpts{
-		CgroupName:       id,
-		UseSystemdCgroup: context.GlobalBool("systemd-cgroup"),
-		NoPivotRoot:      context.Bool("no-pivot"),
-		NoNewKeyring:     context.Bool("no-new-keyring"),
-		Spec:             spec,
-		RootlessEUID:     os.Geteuid() != 0,
-		RootlessCgroups:  rootlessCg,
+		CgroupName:          id,
+		UseSystemdCgroup:    systemdCgroup,
+		NoPivotRoot:         context.Bool("no-pivot"),
+		NoNewKeyring:        context.Bool("no-new-keyring"),
+		Spec:                spec,
+		RootlessEUID:        os.Geteuid() != 0,
+		RootlessCgroups:     rootlessCg,
+		DefaultSystemdSlice: slice,
 	})
 	if err != nil {
 		return nil, err
@@ -200,6 +277,8 @@ type runner struct {
 	listenFDs       []*os.File
 	preserveFDs     int
 	pidFile         string
+	pidfdSocket     string
+	startPipeSocket string
 	consoleSocket   string
 	container       libcontainer.Container
 	action          CtAct
@@ -257,6 +336,15 @@ func (r *runner) run(config *specs.Process) (int, error) {
 	}
 	defer tty.Close()
 
+	var startPipe *os.File
+	if r.startPipeSocket != "" {
+		if process.StartPipe, startPipe, err = os.Pipe(); err != nil {
+			return -1, err
+		}
+		defer process.StartPipe.Close()
+		defer startPipe.Close()
+	}
+
 	switch r.action {
 	case CT_ACT_CREATE:
 		err = r.container.Start(process)
@@ -281,6 +369,18 @@ func (r *runner) run(config *specs.Process) (int, error) {
 			return -1, err
 		}
 	}
+	if r.pidfdSocket != "" {
+		if err = sendPidfd(r.pidfdSocket, process); err != nil {
+			r.terminate(process)
+			return -1, err
+		}
+	}
+	if startPipe != nil {
+		if err = sendFile(r.startPipeSocket, startPipe); err != nil {
+			r.terminate(process)
+			return -1, err
+		}
+	}
 	status, err := handler.forward(process, tty, detach)
 	if err != nil {
 		r.terminate(process)
@@ -394,6 +494,8 @@ func startContainer(context *cli.Context, action CtAct, criuOpts *libcontainer.C
 		consoleSocket:   context.String("console-socket"),
 		detach:          context.Bool("detach"),
 		pidFile:         context.String("pid-file"),
+		pidfdSocket:     context.String("pidfd-socket"),
+		startPipeSocket: context.String("start-pipe-socket"),
 		preserveFDs:     context.Int("preserve-fds"),
 		action:          action,
 		criuOpts:        criuOpts,